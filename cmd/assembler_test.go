@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"github.com/go-miya/gorsx/internal"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// newAssemblerGenerate returns a Generate of the service Users, which declares the query Get and the
+// command Delete.
+func newAssemblerGenerate() *Generate {
+	pkg := types.NewPackage("example.com/users", "users")
+	pointer := func(name string) types.Type {
+		return types.NewPointer(types.NewNamed(types.NewTypeName(0, pkg, name, nil), types.NewStruct(nil, nil), nil))
+	}
+	getReq, _ := internal.NewParam(pointer("GetReq"))
+	user, _ := internal.NewResult(pointer("User"))
+	deleteReq, _ := internal.NewParam(types.Typ[types.String])
+	get := &internal.FuncInfo{
+		FuncName: "Get",
+		CQRS:     &internal.CQRSFile{Type: "query", Service: "Users", Endpoint: "Get"},
+		Assembler: internal.NewAssemblerCore(true, "Get", getReq,
+			&internal.Result{ObjectArgs: &internal.ObjectArgs{Name: "GetQuery", GoImportPath: "example.com/users/app/query"}},
+			&internal.Param{ObjectArgs: &internal.ObjectArgs{Name: "GetResult", GoImportPath: "example.com/users/app/query"}},
+			user),
+	}
+	del := &internal.FuncInfo{
+		FuncName: "Delete",
+		CQRS:     &internal.CQRSFile{Type: "command", Service: "Users", Endpoint: "Delete"},
+		Assembler: internal.NewAssemblerCore(false, "Delete", deleteReq,
+			&internal.Result{ObjectArgs: &internal.ObjectArgs{Name: "DeleteCmd", GoImportPath: "example.com/users/app/command"}},
+			nil, nil),
+	}
+	return &Generate{
+		Buf:           &bytes.Buffer{},
+		HeaderBuf:     &bytes.Buffer{},
+		ImportsBuf:    &bytes.Buffer{},
+		FunctionBuf:   &bytes.Buffer{},
+		Imports:       make(map[string]*internal.GoImport),
+		SrvName:       "Users",
+		pkgImportPath: "example.com/users",
+		Funcs:         []*internal.FuncInfo{get, del},
+		CQRSList:      CQRSList{get.CQRS, del.CQRS},
+	}
+}
+
+// checkGolden compares got with the golden file of testdata, which -update rewrites.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s:\n%s", name, golden, got)
+	}
+}
+
+func TestGenerateAssembler(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		golden string
+	}{
+		{name: "create", golden: "assembler/create.golden"},
+		{name: "append", input: "assembler/append.input", golden: "assembler/append.golden"},
+		// the functions of the assembler generated above are all declared
+		{name: "no-op", input: "assembler/append.golden", golden: "assembler/append.golden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			filename := filepath.Join(outDir, "assembler", "users.go")
+			if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.input != "" {
+				input, err := os.ReadFile(filepath.Join("testdata", tt.input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filename, input, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, _ := os.Stat(filename)
+			newAssemblerGenerate().generateAssembler(outDir, "example.com/users", &internal.Path{AssemblerPath: "assembler"})
+			if tt.name == "no-op" {
+				if after, _ := os.Stat(filename); !after.ModTime().Equal(before.ModTime()) {
+					t.Error("the up-to-date assembler was rewritten")
+				}
+			}
+			got, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, got)
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/go-miya/gorsx/internal"
+	"go/types"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// hasRoutes reports whether any method of the service declares a @GORS route.
+func (g *Generate) hasRoutes() bool {
+	for _, info := range g.Funcs {
+		if info.Route != nil && info.Signature != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// generateClient generates <Service>Client, which implements the service interface by sending the
+// requests of the @GORS routes.
+func (g *Generate) generateClient(outDir string) {
+	filename := filepath.Join(outDir, fmt.Sprintf("%s_client.go", strings.ToLower(g.SrvName)))
+	f := newGoFile(g.PkgName, g.pkgImportPath)
+	clientName := g.SrvName + "Client"
+	f.P("// ", clientName, " is the HTTP client of ", g.SrvName, ".")
+	f.P("type ", clientName, " struct {")
+	f.P("client *", gorsPackage.Ident("Client"))
+	f.P("}")
+	f.P()
+	f.P("var _ ", g.SrvName, " = (*", clientName, ")(nil)")
+	f.P()
+	f.P("// New", clientName, " returns a ", g.SrvName, " client sending requests to baseURL.")
+	f.P("func New", clientName, "(baseURL string, opts ...", gorsPackage.Ident("ClientOption"), ") *", clientName, " {")
+	f.P("return &", clientName, "{client: ", gorsPackage.Ident("NewClient"), "(baseURL, opts...)}")
+	f.P("}")
+	for _, info := range g.Funcs {
+		if info.Signature == nil {
+			continue
+		}
+		f.P()
+		g.printClientMethod(f, clientName, info)
+	}
+	if g.JobRoute() != nil {
+		f.P()
+		g.printClientJob(f, clientName)
+	}
+	src, err := f.Content()
+	if err != nil {
+		log.Fatalf("generateClient.Content failed, %v", err)
+	}
+	if err := writeContent(filename, src); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+	log.Printf("%s.%s wrote client %s", g.pkgImportPath, g.SrvName, filename)
+}
+
+func (g *Generate) printClientMethod(f *goFile, clientName string, info *internal.FuncInfo) {
+	reqType := info.Signature.Params().At(1).Type()
+	var respType types.Type
+	if info.Result1 != nil {
+		respType = info.Signature.Results().At(0).Type()
+		f.P("func (c *", clientName, ") ", info.FuncName, "(ctx ", contextPackage.Ident("Context"), ", req ", reqType, ") (", respType, ", error) {")
+	} else {
+		f.P("func (c *", clientName, ") ", info.FuncName, "(ctx ", contextPackage.Ident("Context"), ", req ", reqType, ") error {")
+	}
+	route := info.Route
+	if route == nil {
+		if respType != nil {
+			f.P("return ", f.zero(respType), ", ", gorsPackage.Ident("ErrNoRoute"))
+		} else {
+			f.P("return ", gorsPackage.Ident("ErrNoRoute"))
+		}
+		f.P("}")
+		return
+	}
+
+	f.P("r := ", gorsPackage.Ident("NewRequest"), "(", httpMethodIdent(route.Method), ", ", pathName(g.SrvName, info), ")")
+	g.printClientRequest(f, info, reqType)
+	if header, ok := idempotencyHeader(info); ok {
+		f.P("if key, ok := ", cqrsxPackage.Ident("IdempotencyKey"), "(ctx); ok {")
+		f.P("r.AddHeader(", fmt.Sprintf("%q", header), ", key)")
+		f.P("}")
+	}
+	if isAsync(info) {
+		// the job of an @Async command is reported to the AcceptedJob carried by ctx
+		f.P("job, ok := ", cqrsxPackage.Ident("AcceptedJobFrom"), "(ctx)")
+		f.P("if !ok {")
+		f.P("job = new(", cqrsxPackage.Ident("AcceptedJob"), ")")
+		f.P("}")
+		decoder := []any{gorsPackage.Ident("DecodeBody"), "(", gorsPackage.Ident("JSONCodec"), ", job)"}
+		if respType == nil {
+			f.P(append(append([]any{"return c.client.Do(ctx, r, "}, decoder...), ")")...)
+		} else {
+			f.P(append(append([]any{"return ", f.zero(respType), ", c.client.Do(ctx, r, "}, decoder...), ")")...)
+		}
+		f.P("}")
+		return
+	}
+
+	if respType == nil {
+		f.P("return c.client.Do(ctx, r, nil)")
+		f.P("}")
+		return
+	}
+	var decoder string
+	switch {
+	case info.Result1.Bytes:
+		f.P("var resp []byte")
+		decoder = f.ident(gorsPackage.Ident("DecodeBytes")) + "(&resp)"
+	case info.Result1.String:
+		f.P("var resp string")
+		decoder = f.ident(gorsPackage.Ident("DecodeString")) + "(&resp)"
+	case info.Result1.Reader:
+		// the streamed result is not limited to the max response bytes
+		f.P("var resp ", ioPackage.Ident("Reader"))
+		f.P("if err := c.client.Stream(ctx, r, &resp); err != nil {")
+		f.P("return nil, err")
+		f.P("}")
+		f.P("return resp, nil")
+		f.P("}")
+		return
+	default:
+		target := "resp"
+		if pointer, ok := respType.(*types.Pointer); ok {
+			f.P("resp := new(", pointer.Elem(), ")")
+		} else {
+			f.P("var resp ", respType)
+			target = "&resp"
+		}
+		decoder = g.clientDecoder(f, route, target)
+	}
+	f.P("if err := c.client.Do(ctx, r, ", decoder, "); err != nil {")
+	f.P("return ", f.zero(respType), ", err")
+	f.P("}")
+	f.P("return resp, nil")
+	f.P("}")
+}
+
+// printClientJob prints the Job method of the client, which gets the status of the job of an @Async
+// command.
+func (g *Generate) printClientJob(f *goFile, clientName string) {
+	f.P("// Job returns the job of id of an @Async command.")
+	f.P("func (c *", clientName, ") Job(ctx ", contextPackage.Ident("Context"), ", id string) (*", cqrsxPackage.Ident("Job"), ", error) {")
+	f.P("r := ", gorsPackage.Ident("NewRequest"), "(", httpPackage.Ident("MethodGet"), ", ", g.jobPathName(), ")")
+	f.P("r.SetPathParam(\"id\", id)")
+	f.P("job := new(", cqrsxPackage.Ident("Job"), ")")
+	f.P("if err := c.client.Do(ctx, r, ", gorsPackage.Ident("DecodeBody"), "(", gorsPackage.Ident("JSONCodec"), ", job)); err != nil {")
+	f.P("return nil, err")
+	f.P("}")
+	f.P("return job, nil")
+	f.P("}")
+}
+
+// printClientRequest prints the statements setting the path parameters, query, headers and body of r
+// from req.
+func (g *Generate) printClientRequest(f *goFile, info *internal.FuncInfo, reqType types.Type) {
+	route := info.Route
+	switch {
+	case info.Param2.Bytes:
+		f.P("r.SetRawBody(\"application/octet-stream\", ", internal.GoImportPath("bytes").Ident("NewReader"), "(req))")
+		return
+	case info.Param2.String:
+		f.P("r.SetRawBody(\"text/plain; charset=utf-8\", ", internal.GoImportPath("strings").Ident("NewReader"), "(req))")
+		return
+	case info.Param2.Reader:
+		f.P("r.SetRawBody(\"application/octet-stream\", req)")
+		return
+	}
+
+	pathParams := make(map[string]bool)
+	for _, name := range route.PathParams() {
+		pathParams[name] = true
+	}
+	// GET and HEAD requests have no body, the form is sent in the query.
+	formInBody := route.BindsFormBody() && route.Method != http.MethodGet && route.Method != http.MethodHead
+	formInQuery := (route.HasBinding(internal.QueryBinding) && !route.BindsFormBody()) || (route.BindsFormBody() && !formInBody)
+	if formInBody && route.HasBinding(internal.FormMultipartBinding) {
+		f.P("r.SetMultipart()")
+	}
+	for _, field := range internal.StructFields(reqType) {
+		if name, ok := field.Tag("uri"); ok && pathParams[name] {
+			f.P("r.SetPathParam(", fmt.Sprintf("%q", name), ", req.", field.GoName, ")")
+		}
+		if name, ok := field.Tag("form"); ok {
+			switch {
+			case formInBody:
+				f.P("r.AddForm(", fmt.Sprintf("%q", name), ", req.", field.GoName, ")")
+			case formInQuery:
+				f.P("r.AddQuery(", fmt.Sprintf("%q", name), ", req.", field.GoName, ")")
+			}
+		}
+		if name, ok := field.Tag("header"); ok && route.HasBinding(internal.HeaderBinding) {
+			f.P("r.AddHeader(", fmt.Sprintf("%q", name), ", req.", field.GoName, ")")
+		}
+	}
+	if codec := bodyCodec(route); codec != nil {
+		f.P("r.SetBody(", codec, ", req)")
+	}
+}
+
+// bodyCodec returns the codec of the first body binding of the route.
+func bodyCodec(route *internal.Route) *internal.GoIdent {
+	for _, binding := range route.Bindings {
+		switch binding {
+		case internal.JSONBinding:
+			return gorsPackage.Ident("JSONCodec")
+		case internal.XMLBinding:
+			return gorsPackage.Ident("XMLCodec")
+		case internal.YAMLBinding:
+			return gorsPackage.Ident("YAMLCodec")
+		case internal.TOMLBinding:
+			return gorsPackage.Ident("TOMLCodec")
+		case internal.MsgPackBinding:
+			return gorsPackage.Ident("MsgPackCodec")
+		case internal.ProtoBufBinding:
+			return gorsPackage.Ident("ProtoBufCodec")
+		}
+	}
+	return nil
+}
+
+// clientDecoder returns the decoder of the response rendered by the route.
+func (g *Generate) clientDecoder(f *goFile, route *internal.Route, target string) string {
+	switch route.Render {
+	case internal.SecureJSONRender:
+		return f.ident(gorsPackage.Ident("DecodeSecureJSON")) + "(" + target + ")"
+	case internal.JsonpJSONRender:
+		return f.ident(gorsPackage.Ident("DecodeJSONP")) + "(" + target + ")"
+	}
+	return f.ident(gorsPackage.Ident("DecodeBody")) + "(" + f.ident(renderCodec(route)) + ", " + target + ")"
+}
+
+// renderCodec returns the codec of the response rendered by the route, JSON by default.
+func renderCodec(route *internal.Route) *internal.GoIdent {
+	switch route.Render {
+	case internal.XMLRender:
+		return gorsPackage.Ident("XMLCodec")
+	case internal.YAMLRender:
+		return gorsPackage.Ident("YAMLCodec")
+	case internal.TOMLRender:
+		return gorsPackage.Ident("TOMLCodec")
+	case internal.MsgPackRender:
+		return gorsPackage.Ident("MsgPackCodec")
+	case internal.ProtoBufRender:
+		return gorsPackage.Ident("ProtoBufCodec")
+	}
+	return gorsPackage.Ident("JSONCodec")
+}
+
+// httpMethodIdent returns the net/http constant of an HTTP method, such as http.MethodGet.
+func httpMethodIdent(method string) *internal.GoIdent {
+	return httpPackage.Ident("Method" + method[:1] + strings.ToLower(method[1:]))
+}
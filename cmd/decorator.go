@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/go-miya/gorsx/internal"
+	"github.com/samber/lo"
+	"log"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const cqrsxPackage = internal.GoImportPath("github.com/go-miya/gorsx/cqrsx")
+
+// busQueueField is the field of the command bus enqueuing the @Async commands.
+const busQueueField = "Queue"
+
+// generateBusDecorators generates New<Bus> next to the bus file, such as bus/query_gors.go, which
+// decorates the handlers of the bus with the cqrsx options, such as tracing, metrics and logging.
+func (g *Generate) generateBusDecorators(outDir string, cqrsPath *internal.Path, isQuery bool) {
+	busPath, busImportPath, tp := cqrsPath.BusQuery, g.busQueryImportPath, "Queries"
+	cqrsList := g.CQRSList.GetQueries()
+	if !isQuery {
+		busPath, busImportPath, tp = cqrsPath.BusCommand, g.busCommandImportPath, "Commands"
+		cqrsList = g.CQRSList.GetCommands()
+	}
+	if busPath == "" || len(cqrsList) == 0 {
+		return
+	}
+	filename := filepath.Join(outDir, strings.TrimSuffix(busPath, ".go")+"_gors.go")
+	f := newGoFile(path.Base(string(busImportPath)), string(busImportPath))
+	recv := strings.ToLower(tp[:1])
+	f.P("// New", tp, " returns ", recv, " with its handlers decorated by opts, such as ", cqrsxPackage.Ident("WithTracerProvider"), ", ", cqrsxPackage.Ident("WithRegisterer"), " or ", cqrsxPackage.Ident("WithLogger"), ".")
+	f.P("func New", tp, "(", recv, " ", tp, ", opts ...", cqrsxPackage.Ident("Option"), ") *", tp, " {")
+	f.P("o := ", cqrsxPackage.Ident("NewOptions"), "(opts...)")
+	for _, file := range cqrsList {
+		handlerPath := file.GoImportPath(g.pkgImportPath)
+		kind, decorate := cqrsxPackage.Ident("KindCommand"), []any{cqrsxPackage.Ident("DecorateCommand"), "[*", handlerPath.Ident(file.GetReqName()), "]"}
+		if isQuery {
+			kind, decorate = cqrsxPackage.Ident("KindQuery"), []any{cqrsxPackage.Ident("DecorateQuery"), "[*", handlerPath.Ident(file.GetReqName()), ", *", handlerPath.Ident(file.GetRespName()), "]"}
+		}
+		info := []any{cqrsxPackage.Ident("Info"), fmt.Sprintf("{Service: %q, Endpoint: %q, Kind: ", g.SrvName, file.Endpoint), kind}
+		if file.Log {
+			info = append(info, ", LogPayload: true")
+		}
+		if len(file.Redact) > 0 {
+			quoted := make([]string, 0, len(file.Redact))
+			for _, field := range file.Redact {
+				quoted = append(quoted, strconv.Quote(field))
+			}
+			info = append(info, ", Redact: []string{", strings.Join(quoted, ", "), "}")
+		}
+		if file.Transactional {
+			info = append(info, ", Transactional: true")
+		}
+		if file.Cache != nil {
+			info = append(info, g.cacheInfo(file, handlerPath)...)
+		}
+		if len(file.Invalidates) > 0 {
+			info = append(info, g.invalidates(file, handlerPath)...)
+		}
+		if len(file.Emits) > 0 {
+			quoted := make([]string, 0, len(file.Emits))
+			for _, event := range file.Emits {
+				quoted = append(quoted, strconv.Quote(event))
+			}
+			info = append(info, ", Emits: []string{", strings.Join(quoted, ", "), "}")
+		}
+		if file.Idempotent != nil {
+			info = append(info, ", Idempotency: &", cqrsxPackage.Ident("IdempotencyInfo"), "{TTL: ")
+			info = append(append(info, durationExpr(file.Idempotent.TTL)...), "}")
+		}
+		field := recv + "." + file.Endpoint
+		args := append([]any{field, " = "}, decorate...)
+		args = append(append(append(args, "(", field, ", "), info...), "}, o)")
+		f.P(args...)
+	}
+	f.P("return &", recv)
+	f.P("}")
+	if !isQuery {
+		g.printNewWorker(f, cqrsList)
+	}
+	src, err := f.Content()
+	if err != nil {
+		log.Fatalf("generateBusDecorators.Content failed, %v", err)
+	}
+	if err := writeContent(filename, src); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+	log.Printf("%s.%s wrote decorators %s", g.pkgImportPath, g.SrvName, filename)
+}
+
+// printNewWorker prints NewWorker, which registers the handlers of the @Async commands on a worker of
+// the queue of the command bus.
+func (g *Generate) printNewWorker(f *goFile, cqrsList []*internal.CQRSFile) {
+	asyncList := lo.Filter(cqrsList, func(x *internal.CQRSFile, _ int) bool { return x.Async })
+	if len(asyncList) == 0 {
+		return
+	}
+	f.P()
+	f.P("// NewWorker returns the worker handling the @Async commands enqueued in c.", busQueueField, " with the handlers of c,")
+	f.P("// such as the ones returned by NewCommands.")
+	f.P("func NewWorker(c *Commands, opts ...", cqrsxPackage.Ident("WorkerOption"), ") *", cqrsxPackage.Ident("Worker"), " {")
+	f.P("w := ", cqrsxPackage.Ident("NewWorker"), "(c.", busQueueField, ", opts...)")
+	for _, file := range asyncList {
+		handlerPath := file.GoImportPath(g.pkgImportPath)
+		f.P(cqrsxPackage.Ident("RegisterCommand"), "[*", handlerPath.Ident(file.GetReqName()), "](w, ", strconv.Quote(file.JobName()), ", c.", file.Endpoint, ")")
+	}
+	f.P("return w")
+	f.P("}")
+}
+
+// cacheInfo returns the Cache field of the Info of a @Cache query, whose key is derived from the key
+// fields of the query, or the whole query.
+func (g *Generate) cacheInfo(file *internal.CQRSFile, handlerPath internal.GoImportPath) []any {
+	name := strconv.Quote(g.SrvName + "." + file.Endpoint)
+	key := []any{"return ", cqrsxPackage.Ident("CacheKey"), "(", name, ", req)"}
+	if len(file.Cache.Key) > 0 {
+		key = append([]any{"x := req.(*", handlerPath.Ident(file.GetReqName()), ")\n", "return ", cqrsxPackage.Ident("CacheKey"), "(", name}, keyFields("x", file.Cache.Key)...)
+		key = append(key, ")")
+	}
+	info := []any{", Cache: &", cqrsxPackage.Ident("CacheInfo"), "{TTL: "}
+	info = append(info, durationExpr(file.Cache.TTL)...)
+	info = append(info, ", Key: func(req any) string {\n")
+	info = append(info, key...)
+	return append(info, "\n}}")
+}
+
+// invalidates returns the Invalidates field of the Info of a @Invalidate command, deriving the keys of
+// the results of the queries from the fields of the command.
+func (g *Generate) invalidates(file *internal.CQRSFile, handlerPath internal.GoImportPath) []any {
+	keys := []any{"x := req.(*", handlerPath.Ident(file.GetReqName()), ")\n", "return []string{"}
+	for i, invalidation := range file.Invalidates {
+		query := g.cachedQuery(file, invalidation)
+		fields := invalidation.Key
+		if len(fields) == 0 {
+			fields = query.Cache.Key
+		}
+		if len(fields) == 0 {
+			log.Fatalf("error: func %s %s(%s) requires a key, since %s caches its results by the whole query",
+				file.Endpoint, internal.Invalidate, invalidation.Endpoint, invalidation.Endpoint)
+		}
+		if i > 0 {
+			keys = append(keys, ", ")
+		}
+		keys = append(keys, cqrsxPackage.Ident("CacheKey"), "(", strconv.Quote(g.SrvName+"."+query.Endpoint))
+		keys = append(keys, keyFields("x", fields)...)
+		keys = append(keys, ")")
+	}
+	info := []any{", Invalidates: func(req any) []string {\n"}
+	info = append(info, keys...)
+	return append(info, "}\n}")
+}
+
+// cachedQuery returns the @Cache query a @Invalidate command invalidates.
+func (g *Generate) cachedQuery(file *internal.CQRSFile, invalidation *internal.Invalidation) *internal.CQRSFile {
+	for _, query := range g.CQRSList.GetQueries() {
+		if query.Endpoint == invalidation.Endpoint {
+			if query.Cache == nil {
+				log.Fatalf("error: func %s %s(%s) invalid, %s has no %s", file.Endpoint, internal.Invalidate, invalidation.Endpoint, invalidation.Endpoint, internal.Cache)
+			}
+			return query
+		}
+	}
+	log.Fatalf("error: func %s %s(%s) invalid, %s is not a @Query", file.Endpoint, internal.Invalidate, invalidation.Endpoint, invalidation.Endpoint)
+	return nil
+}
+
+// keyFields returns the arguments of cqrsx.CacheKey selecting the fields of x.
+func keyFields(x string, fields []string) []any {
+	var args []any
+	for _, field := range fields {
+		args = append(args, ", ", x, ".", field)
+	}
+	return args
+}
+
+// durationExpr returns the expression of d, such as 30 * time.Second.
+func durationExpr(d time.Duration) []any {
+	units := []struct {
+		d    time.Duration
+		name string
+	}{{time.Hour, "Hour"}, {time.Minute, "Minute"}, {time.Second, "Second"}, {time.Millisecond, "Millisecond"}}
+	for _, unit := range units {
+		if d%unit.d == 0 {
+			return []any{strconv.FormatInt(int64(d/unit.d), 10), " * ", internal.GoImportPath("time").Ident(unit.name)}
+		}
+	}
+	return []any{internal.GoImportPath("time").Ident("Duration"), "(", strconv.FormatInt(int64(d), 10), ")"}
+}
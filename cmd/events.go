@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-miya/gorsx/internal"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// emittedEvent is an event of the @Emits commands.
+type emittedEvent struct {
+	Name string
+	// Commands are the commands emitting the event.
+	Commands []string
+	// GoImportPath is the import path of the package declaring the event, the one of the commands.
+	GoImportPath internal.GoImportPath
+	// Dir is the directory of the package declaring the event.
+	Dir string
+	// Package is the name of the package declaring the event.
+	Package string
+}
+
+// emittedEvents returns the events of the @Emits commands, in the order they are declared.
+func (g *Generate) emittedEvents() []*emittedEvent {
+	var events []*emittedEvent
+	byName := make(map[string]*emittedEvent)
+	for _, file := range g.CQRSList.GetCommands() {
+		for _, name := range file.Emits {
+			event, ok := byName[name]
+			if !ok {
+				event = &emittedEvent{
+					Name:         name,
+					GoImportPath: file.GoImportPath(g.pkgImportPath),
+					Dir:          filepath.Dir(file.AbsFilename),
+					Package:      file.Package,
+				}
+				byName[name] = event
+				events = append(events, event)
+			}
+			event.Commands = append(event.Commands, file.Endpoint)
+		}
+	}
+	return events
+}
+
+// generateEvents declares the structs of the events of the @Emits commands missing from the command
+// package in its events.go, which is left to the user to fill in afterwards.
+func (g *Generate) generateEvents(namePrefix string) {
+	events := g.emittedEvents()
+	if len(events) == 0 {
+		return
+	}
+	dir, pkg := events[0].Dir, events[0].Package
+	declared := declaredTypes(dir)
+	var decls bytes.Buffer
+	for _, event := range events {
+		if declared[event.Name] {
+			continue
+		}
+		_, _ = fmt.Fprintf(&decls, "\n// %s is emitted once %s succeeds.\ntype %s struct {\n}\n",
+			event.Name, strings.Join(event.Commands, " or "), event.Name)
+	}
+	if decls.Len() == 0 {
+		return
+	}
+	fn := "events.go"
+	if namePrefix != "" {
+		fn = namePrefix + "_" + fn
+	}
+	filename := filepath.Join(dir, fn)
+	var src []byte
+	if _, err := os.Stat(filename); err != nil {
+		src = append([]byte("package "+pkg+"\n"), decls.Bytes()...)
+	} else {
+		editor, err := g.newGoEditor(filename)
+		if err != nil {
+			log.Fatalf("generateEvents.NewGoEditor failed, %v", err)
+		}
+		editor.Append(decls.String())
+		src = editor.Bytes()
+	}
+	src, err := format.Source(src)
+	if err != nil {
+		log.Fatalf("generateEvents.Source failed, %v", err)
+	}
+	if err := writeContent(filename, src); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+	log.Printf("%s.%s wrote events %s", g.pkgImportPath, g.SrvName, filename)
+}
+
+// declaredTypes returns the names of the types declared by the package in dir.
+func declaredTypes(dir string) map[string]bool {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		log.Fatalf("declaredTypes.Glob failed, %v", err)
+	}
+	declared := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, filename := range filenames {
+		file, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			log.Printf("warning: events: skip %s, %v", filename, err)
+			continue
+		}
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				declared[spec.(*ast.TypeSpec).Name.Name] = true
+			}
+		}
+	}
+	return declared
+}
+
+// generateEventBus generates bus/events_gors.go next to the command bus, constraining the subscriptions
+// of the in-process event bus to the events of the @Emits commands, and decoding them from the outbox.
+func (g *Generate) generateEventBus(outDir string, cqrsPath *internal.Path) {
+	events := g.emittedEvents()
+	if cqrsPath.BusCommand == "" || len(events) == 0 {
+		return
+	}
+	filename := filepath.Join(outDir, filepath.Dir(cqrsPath.BusCommand), "events_gors.go")
+	f := newGoFile(path.Base(string(g.busCommandImportPath)), string(g.busCommandImportPath))
+	f.P("// Event constrains the subscriptions to the events emitted by the commands of ", g.SrvName, ".")
+	f.P("type Event interface {")
+	terms := make([]any, 0, 2*len(events))
+	for i, event := range events {
+		if i > 0 {
+			terms = append(terms, " | ")
+		}
+		terms = append(terms, event.GoImportPath.Ident(event.Name))
+	}
+	f.P(terms...)
+	f.P("}")
+	f.P()
+	f.P("// NewEventBus returns the in-process event bus publishing the events of the commands, which is")
+	f.P("// injected into their handlers as their ", cqrsxPackage.Ident("EventPublisher"), ".")
+	f.P("func NewEventBus() *", cqrsxPackage.Ident("EventBus"), " {")
+	f.P("return ", cqrsxPackage.Ident("NewEventBus"), "()")
+	f.P("}")
+	f.P()
+	f.P("// Subscribe registers handler for the events of type E published on bus, such as")
+	f.P("// Subscribe[", events[0].GoImportPath.Ident(events[0].Name), "](bus, handler).")
+	f.P("func Subscribe[E Event](bus *", cqrsxPackage.Ident("EventBus"), ", handler func(ctx ", contextPackage.Ident("Context"), ", event E) error) {")
+	f.P(cqrsxPackage.Ident("Subscribe"), "(bus, handler)")
+	f.P("}")
+	f.P()
+	f.P("// DecodeEvent decodes the payload of an event of the outbox by its type, such as for")
+	f.P("// ", cqrsxPackage.Ident("EventBusPublisher"), ".")
+	f.P("func DecodeEvent(eventType string, payload []byte) (any, error) {")
+	f.P("switch eventType {")
+	for _, event := range events {
+		f.P("case ", strconv.Quote(event.Name), ":")
+		f.P("var event ", event.GoImportPath.Ident(event.Name))
+		f.P("err := ", internal.GoImportPath("encoding/json").Ident("Unmarshal"), "(payload, &event)")
+		f.P("return event, err")
+	}
+	f.P("}")
+	f.P("return nil, ", internal.GoImportPath("fmt").Ident("Errorf"), "(\"", path.Base(string(g.busCommandImportPath)), ": unknown event %s\", eventType)")
+	f.P("}")
+	src, err := f.Content()
+	if err != nil {
+		log.Fatalf("generateEventBus.Content failed, %v", err)
+	}
+	if err := writeContent(filename, src); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+	log.Printf("%s.%s wrote event bus %s", g.pkgImportPath, g.SrvName, filename)
+}
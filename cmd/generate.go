@@ -7,10 +7,10 @@ import (
 	"github.com/samber/lo"
 	"go/ast"
 	"go/format"
-	"go/token"
 	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -19,26 +19,46 @@ import (
 const (
 	contextPackage = internal.GoImportPath("context")
 	ioPackage      = internal.GoImportPath("io")
+	stringsPackage = internal.GoImportPath("strings")
 )
 
 type Generate struct {
-	Buf              *bytes.Buffer
-	HeaderBuf        *bytes.Buffer
-	ImportsBuf       *bytes.Buffer
-	FunctionBuf      *bytes.Buffer
-	pkgImpl          string
-	pkgAssembler     string
-	pkgBus           string
-	pkgImportPath    string // 源文件
-	implDeclFuncs    []*ast.FuncDecl
-	implDeclImports  []*ast.GenDecl
-	implRemainDecls  []ast.Decl
-	Imports          map[string]*internal.GoImport
-	SrvName          string
-	SrvTypeShort     string
-	UsedPackageNames map[string]bool
-	Funcs            []*internal.FuncInfo
-	CQRSList         CQRSList
+	Buf                  *bytes.Buffer
+	HeaderBuf            *bytes.Buffer
+	ImportsBuf           *bytes.Buffer
+	FunctionBuf          *bytes.Buffer
+	pkgImpl              string
+	pkgAssembler         string
+	pkgBus               string
+	pkgImportPath        string // 源文件
+	assemblerImportPath  internal.GoImportPath
+	busQueryImportPath   internal.GoImportPath
+	busCommandImportPath internal.GoImportPath
+	Imports              map[string]*internal.GoImport
+	PkgName              string
+	SrvName              string
+	SrvTypeShort         string
+	UsedPackageNames     map[string]bool
+	Funcs                []*internal.FuncInfo
+	CQRSList             CQRSList
+	// Bus generates the query and command buses of @QueryBusPath and @CommandBusPath, and inserts the
+	// missing handler fields into the existing ones.
+	Bus bool
+	// Prune removes the untouched generated code of methods deleted from the service interface,
+	// and marks the customized code as deprecated.
+	Prune bool
+	// Router is the router Register<Service> registers the @GORS routes on, one of RouterStd,
+	// RouterGin, RouterEcho and RouterChi.
+	Router string
+	// RouteGroup is the routing shared by the @GORS routes of the service.
+	RouteGroup *internal.RouteGroup
+	// Auth is the authorization declared by @Auth and @Permission on the service, the default of its
+	// endpoints, nil when it declares none.
+	Auth *internal.AuthAnnotation
+	// PackageNames are the names of the loaded packages by their import paths, which the imports of the
+	// edited files are resolved with.
+	PackageNames map[string]string
+	keptOrphans  map[string]bool
 }
 
 type CQRSList []*internal.CQRSFile
@@ -56,43 +76,78 @@ func (l CQRSList) GetCommands() []*internal.CQRSFile {
 }
 
 func (g *Generate) Generate(outDir, pkgPath, ImplPath string, carsPath *internal.Path) {
-	g.generateServiceImpl(outDir, pkgPath, ImplPath)
+	g.generateServiceImpl(outDir, pkgPath, ImplPath, carsPath)
 	g.generateAssembler(outDir, pkgPath, carsPath)
-	g.generateBus(outDir, pkgPath, carsPath, true)
-	g.generateBus(outDir, pkgPath, carsPath, false)
+	if (g.Bus || g.Prune) && carsPath != nil {
+		g.generateBus(outDir, pkgPath, carsPath, true)
+		g.generateBus(outDir, pkgPath, carsPath, false)
+	}
+	if carsPath != nil {
+		g.generateBusDecorators(outDir, carsPath, true)
+		g.generateBusDecorators(outDir, carsPath, false)
+		g.generateEvents(carsPath.NamePrefix)
+		g.generateEventBus(outDir, carsPath)
+	}
+	if g.Prune && carsPath != nil {
+		var dirs []string
+		if carsPath.Query != "" {
+			dirs = append(dirs, filepath.Join(outDir, carsPath.Query))
+		}
+		if carsPath.Command != "" {
+			dirs = append(dirs, filepath.Join(outDir, carsPath.Command))
+		}
+		g.pruneCQRS(dirs...)
+	}
+	if g.hasRoutes() {
+		g.generateHandlers(outDir)
+		g.generateClient(outDir)
+	}
 }
 
-// 暂时还未解决bus的增量补充问题，待解决
+// GenerateProto generates the service implementation and assembler of a protobuf service.
 func (g *Generate) GenerateProto(outDir, pkgPath, ImplPath string, carsPath *internal.Path) {
-	g.generateServiceImpl(outDir, pkgPath, ImplPath)
+	g.generateServiceImpl(outDir, pkgPath, ImplPath, carsPath)
 	g.generateAssembler(outDir, pkgPath, carsPath)
 }
 
-func (g *Generate) generateServiceImpl(outDir, pkgPath, ImplPath string) {
+func (g *Generate) generateServiceImpl(outDir, pkgPath, ImplPath string, cqrsPath *internal.Path) {
 	// gen service impl
 	implOutputPath := filepath.Join(outDir, ImplPath, fmt.Sprintf("%s.go", strings.ToLower(g.SrvName)))
 	g.pkgImportPath = pkgPath
+	if cqrsPath != nil {
+		if cqrsPath.AssemblerPath != "" {
+			g.assemblerImportPath = internal.GoImportPath(path.Join(pkgPath, cqrsPath.AssemblerPath))
+		}
+		if cqrsPath.BusQuery != "" {
+			g.busQueryImportPath = internal.GoImportPath(path.Join(pkgPath, path.Dir(cqrsPath.BusQuery)))
+		}
+		if cqrsPath.BusCommand != "" {
+			g.busCommandImportPath = internal.GoImportPath(path.Join(pkgPath, path.Dir(cqrsPath.BusCommand)))
+		}
+	}
 	_, g.pkgImpl = filepath.Split(ImplPath)
 	g.pkgImpl = fmt.Sprintf("package %s", g.pkgImpl)
 
-	var content []byte
+	var src []byte
 	if _, err := os.Stat(implOutputPath); err != nil {
-		content = g.contentImpl()
+		content := g.contentImpl()
+		// Format the output.
+		src, err = format.Source(content)
+		if err != nil {
+			log.Printf("warning: internal error: invalid Go generated: %s", err)
+			log.Printf("warning: compile the package to analyze the error")
+			src = content
+		}
 	} else {
-		astFile, err := internal.ParserGoFile(implOutputPath)
+		editor, err := g.newGoEditor(implOutputPath)
 		if err != nil {
-			log.Fatalf("generateServiceImpl.ParserGoFile failed, %v", err)
+			log.Fatalf("generateServiceImpl.NewGoEditor failed, %v", err)
+		}
+		src = g.contentImplAppend(editor)
+		if src == nil {
+			log.Printf("%s.%s impl %s is up to date", pkgPath, g.SrvName, implOutputPath)
+			return
 		}
-		g.implDeclImports, g.implRemainDecls, g.implDeclFuncs = internal.InspectAstFile(astFile)
-		content = g.contentImplAppend()
-	}
-
-	// Format the output.
-	src, err := format.Source(content)
-	if err != nil {
-		log.Printf("warning: internal error: invalid Go generated: %s", err)
-		log.Printf("warning: compile the package to analyze the error")
-		src = content
 	}
 	if err := writeContent(implOutputPath, src); err != nil {
 		log.Fatalf("writing output: %s", err)
@@ -100,41 +155,47 @@ func (g *Generate) generateServiceImpl(outDir, pkgPath, ImplPath string) {
 	log.Printf("%s.%s wrote impl %s", pkgPath, g.SrvName, implOutputPath)
 }
 
+// generateBus generates the bus of the queries or the commands. Without Bus, it only prunes the
+// existing bus file.
 func (g *Generate) generateBus(outDir, pkgPath string, cqrsPath *internal.Path, isQuery bool) {
-	if isQuery && len(g.CQRSList.GetQueries()) == 0 {
-		return
-	}
-	if !isQuery && len(g.CQRSList.GetCommands()) == 0 {
-		return
-	}
-	var content []byte
 	g.Reset()
-	path := cqrsPath.BusQuery
+	busPath, handlerPath := cqrsPath.BusQuery, cqrsPath.Query
 	if !isQuery {
-		path = cqrsPath.BusCommand
+		busPath, handlerPath = cqrsPath.BusCommand, cqrsPath.Command
 	}
-	tarFilePath := filepath.Join(outDir, path)
+	if busPath == "" {
+		return
+	}
+	handlerImportPath := internal.GoImportPath(path.Join(pkgPath, handlerPath))
+	tarFilePath := filepath.Join(outDir, busPath)
 	g.pkgBus = fmt.Sprintf("package %s", filepath.Base(filepath.Dir(tarFilePath)))
+	var src []byte
 	if _, err := os.Stat(tarFilePath); err != nil {
-		content = g.contentBus(nil, isQuery)
+		if !g.Bus {
+			return
+		}
+		content := g.contentBus(nil, isQuery, handlerImportPath)
+		if content == nil {
+			return
+		}
+		src, err = format.Source(content)
+		if err != nil {
+			log.Printf("warning: internal error: invalid Go generated: %s", err)
+			log.Printf("warning: compile the package to analyze the error")
+			src = content
+		}
 	} else {
-		busQueryFile, err := internal.ParserGoFile(tarFilePath)
+		editor, err := g.newGoEditor(tarFilePath)
 		if err != nil {
-			log.Fatalf("generateBus.ParserGoFile failed, %v", err)
+			log.Fatalf("generateBus.NewGoEditor failed, %v", err)
+		}
+		src = g.contentBus(editor, isQuery, handlerImportPath)
+		if src == nil {
+			log.Printf("%s.%s cqrs %s is up to date", pkgPath, g.SrvName, tarFilePath)
+			return
 		}
-		content = g.contentBus(busQueryFile, isQuery)
-	}
-	if content == nil {
-		return
-	}
-	src, err := format.Source(content)
-	if err != nil {
-		log.Printf("warning: internal error: invalid Go generated: %s", err)
-		log.Printf("warning: compile the package to analyze the error")
-		src = content
 	}
-	err = writeContent(tarFilePath, src)
-	if err != nil {
+	if err := writeContent(tarFilePath, src); err != nil {
 		log.Fatalf("writing output: %s", err)
 	}
 	log.Printf("%s.%s wrote cqrs %s", pkgPath, g.SrvName, tarFilePath)
@@ -144,241 +205,48 @@ func (g *Generate) generateAssembler(outDir, pkgPath string, cqrsPath *internal.
 	if len(g.CQRSList) == 0 {
 		return
 	}
-	var content []byte
 	g.Reset()
 	assemblerOPath := filepath.Join(outDir, cqrsPath.AssemblerPath, fmt.Sprintf("%s.go", strings.ToLower(g.SrvName)))
 	_, g.pkgAssembler = filepath.Split(cqrsPath.AssemblerPath)
 	g.pkgAssembler = fmt.Sprintf("package %s", g.pkgAssembler)
-	isAppend := false
+	var src []byte
 	if _, err := os.Stat(assemblerOPath); err != nil {
-		content = g.contentAssembler(isAppend)
-	} else {
-		isAppend = true
-		astFile, err := internal.ParserGoFile(assemblerOPath)
+		content := g.contentAssembler(nil)
+		src, err = format.Source(content)
 		if err != nil {
-			log.Fatalf("generateAssembler.ParserGoFile failed, %v", err)
+			log.Printf("warning: internal error: invalid Go generated: %s", err)
+			log.Printf("warning: compile the package to analyze the error")
+			src = content
 		}
-		g.implDeclImports, g.implRemainDecls, g.implDeclFuncs = internal.InspectAstFile(astFile)
-		content = g.contentAssembler(isAppend)
-	}
-	src, err := format.Source(content)
-	if err != nil {
-		log.Printf("warning: internal error: invalid Go generated: %s", err)
-		log.Printf("warning: compile the package to analyze the error")
-		src = content
-	}
-	if !isAppend {
-		err = writeContent(assemblerOPath, src)
 	} else {
-		err = appendContent(assemblerOPath, src)
+		editor, err := g.newGoEditor(assemblerOPath)
+		if err != nil {
+			log.Fatalf("generateAssembler.NewGoEditor failed, %v", err)
+		}
+		src = g.contentAssembler(editor)
+		if src == nil {
+			log.Printf("%s.%s assembler %s is up to date", pkgPath, g.SrvName, assemblerOPath)
+			return
+		}
 	}
-	if err != nil {
+	if err := writeContent(assemblerOPath, src); err != nil {
 		log.Fatalf("writing output: %s", err)
 	}
 	log.Printf("%s.%s wrote assembler %s", pkgPath, g.SrvName, assemblerOPath)
 }
 
-func writeContent(path string, content []byte) error {
-	return os.WriteFile(path, content, 0644)
-}
-
-func appendContent(path string, content []byte) error {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+// newGoEditor returns the GoEditor of the existing file filename.
+func (g *Generate) newGoEditor(filename string) (*internal.GoEditor, error) {
+	editor, err := internal.NewGoEditor(filename)
 	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = f.Write(content)
-	if err != nil {
-		return err
-	}
-	return f.Close()
-}
-
-func (g *Generate) checkResult2MustBeError(rpcType *ast.FuncType, methodName *ast.Ident) {
-	result2 := rpcType.Results.List[1]
-	result2Iden, ok := result2.Type.(*ast.Ident)
-	if !ok {
-		log.Fatalf("error: func %s 2th result is not error", methodName)
-	}
-	if result2Iden.Name != "error" {
-		log.Fatalf("error: func %s 2th result is not error", methodName)
-	}
-}
-
-func (g *Generate) CheckAndGetResult1(rpcType *ast.FuncType, methodName *ast.Ident) *internal.Result {
-	result1 := rpcType.Results.List[0]
-	switch r1 := result1.Type.(type) {
-	case *ast.ArrayType:
-		ident, ok := r1.Elt.(*ast.Ident)
-		if !ok {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		if ident.Name != "byte" {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		return &internal.Result{Bytes: true}
-	case *ast.Ident:
-		if r1.Name != "string" {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		return &internal.Result{String: true}
-	case *ast.StarExpr:
-		switch x := r1.X.(type) {
-		case *ast.Ident:
-			name := x.Name
-			return &internal.Result{ObjectArgs: &internal.ObjectArgs{Name: name}}
-		case *ast.SelectorExpr:
-			ident, ok := x.X.(*ast.Ident)
-			if !ok {
-				log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-			}
-			for importPath, goImport := range g.Imports {
-				if goImport.PackageName == ident.Name {
-					return &internal.Result{ObjectArgs: &internal.ObjectArgs{Name: x.Sel.Name, GoImportPath: internal.GoImportPath(importPath)}}
-				}
-			}
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-			return nil
-		default:
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-			return nil
-		}
-	case *ast.SelectorExpr:
-		if r1.Sel == nil {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		if r1.Sel.Name != "Reader" {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		ident, ok := r1.X.(*ast.Ident)
-		if !ok {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		ioImport, ok := g.Imports["io"]
-		if !ok {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		if ioImport.PackageName != ident.Name {
-			log.Fatalf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		return &internal.Result{Reader: true}
-	default:
-
-	}
-	return nil
-}
-
-func (g *Generate) getParamsAndResults(rpcType *ast.FuncType) (*ast.FieldList, *ast.FieldList) {
-	params := rpcType.Params
-	param1, param2 := params.List[0], params.List[1]
-	if len(param1.Names) == 0 {
-		param1.Names = append(param1.Names, ast.NewIdent("c"))
-	}
-	if len(param2.Names) == 0 {
-		param2.Names = append(param2.Names, ast.NewIdent("req"))
-	}
-
-	results := rpcType.Results
-	result1, result2 := results.List[0], results.List[1]
-	if len(result1.Names) == 0 {
-		result1.Names = append(result1.Names, ast.NewIdent("res"))
-	}
-	if len(result2.Names) == 0 {
-		result2.Names = append(result2.Names, ast.NewIdent("err"))
-	}
-	return rpcType.Params, rpcType.Results
-}
-
-func (g *Generate) CheckAndGetParam2(rpcType *ast.FuncType, methodName *ast.Ident) *internal.Param {
-	param2 := rpcType.Params.List[1]
-	switch p2 := param2.Type.(type) {
-	case *ast.ArrayType:
-		ident, ok := p2.Elt.(*ast.Ident)
-		if !ok {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		if ident.Name != "byte" {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		return &internal.Param{Bytes: true}
-	case *ast.Ident:
-		if p2.Name != "string" {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		return &internal.Param{String: true}
-	case *ast.StarExpr:
-		switch x := p2.X.(type) {
-		case *ast.Ident:
-			name := x.Name
-			return &internal.Param{ObjectArgs: &internal.ObjectArgs{Name: name}}
-		case *ast.SelectorExpr:
-			ident, ok := x.X.(*ast.Ident)
-			if !ok {
-				log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-			}
-			for importPath, goImport := range g.Imports {
-				if goImport.PackageName == ident.Name {
-					return &internal.Param{ObjectArgs: &internal.ObjectArgs{Name: x.Sel.Name, GoImportPath: internal.GoImportPath(importPath)}}
-				}
-			}
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-			return nil
-		default:
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-			return nil
-		}
-
-	case *ast.SelectorExpr:
-		if p2.Sel == nil {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		if p2.Sel.Name != "Reader" {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		ident, ok := p2.X.(*ast.Ident)
-		if !ok {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		ioImport, ok := g.Imports["io"]
-		if !ok {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		if ioImport.PackageName != ident.Name {
-			log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		}
-		return &internal.Param{Reader: true}
-	default:
-		log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or *struct{}", methodName)
-		return nil
+		return nil, err
 	}
+	editor.PackageNames = g.PackageNames
+	return editor, nil
 }
 
-func (g *Generate) checkParam1MustBeContext(rpcType *ast.FuncType, methodName *ast.Ident) {
-	param1 := rpcType.Params.List[0]
-	param0SelectorExpr, ok := param1.Type.(*ast.SelectorExpr)
-	if !ok {
-		log.Fatalf("error: func %s 1th param is not context.Context", methodName)
-	}
-	if param0SelectorExpr.Sel.Name != "Context" {
-		log.Fatalf("error: func %s 1th param is not context.Context", methodName)
-	}
-	param0SelectorExprX, ok := param0SelectorExpr.X.(*ast.Ident)
-	if !ok {
-		log.Fatalf("error: func %s 1th param is not context.Context", methodName)
-	}
-	if param0SelectorExprX.Name != "context" {
-		log.Fatalf("error: func %s 1th param is not context.Context", methodName)
-	}
-}
-
-func (g *Generate) checkParams(rpcType *ast.FuncType, methodName *ast.Ident) {
-	if rpcType.Params == nil {
-		log.Fatalf("error: func %s params is empty", methodName)
-	}
-	if len(rpcType.Params.List) != 2 {
-		log.Fatalf("error: func %s params count is not equal 2", methodName)
-	}
-	g.checkParam1MustBeContext(rpcType, methodName)
+func writeContent(path string, content []byte) error {
+	return os.WriteFile(path, content, 0644)
 }
 
 func (g *Generate) P(w io.Writer, v ...any) {
@@ -411,42 +279,70 @@ func (g *Generate) contentImpl() []byte {
 
 }
 
-func (g *Generate) contentImplAppend() []byte {
-	g.appendFuncs()
-	g.appendImports()
-	buffer := bytes.NewBuffer([]byte(""))
-	buffer.Write([]byte(g.pkgImpl))
-	var decls []ast.Decl
-	for _, decl := range g.implDeclImports {
-		decls = append(decls, decl)
+// contentImplAppend inserts the missing methods and imports into the existing implementation,
+// leaving the rest of the file untouched. It returns nil when nothing is missing.
+func (g *Generate) contentImplAppend(editor *internal.GoEditor) []byte {
+	if g.Prune {
+		g.pruneImpl(editor, buildTypeName(g.SrvName))
 	}
-	for _, decl := range g.implRemainDecls {
-		decls = append(decls, decl)
+	g.appendFuncs(editor)
+	if g.FunctionBuf.Len() == 0 {
+		if editor.Changed() {
+			return editor.Bytes()
+		}
+		return nil
 	}
-	for _, decl := range g.implDeclFuncs {
-		decls = append(decls, decl)
-
+	funcs, err := internal.FormatDecls(g.FunctionBuf.Bytes())
+	if err != nil {
+		log.Printf("warning: internal error: invalid Go generated: %s", err)
+		log.Printf("warning: compile the package to analyze the error")
+		funcs = g.FunctionBuf.Bytes()
 	}
-	for _, decl := range decls {
-		err := internal.AstToGo(buffer, decl)
-		if err != nil {
-			log.Fatal(err)
+	var imports []*internal.GoImport
+	for _, imp := range g.Imports {
+		if imp.Enable {
+			imports = append(imports, imp)
 		}
 	}
-	_, _ = io.Copy(buffer, g.FunctionBuf)
-	return buffer.Bytes()
+	editor.AddImports(imports)
+	editor.Append(string(funcs))
+	return editor.Bytes()
 }
 
-func (g *Generate) contentAssembler(isAppend bool) []byte {
-	if !isAppend {
+// contentAssembler returns a new assembler file when editor is nil. Otherwise, it inserts the
+// missing functions and imports into the existing file and returns nil when nothing is missing.
+func (g *Generate) contentAssembler(editor *internal.GoEditor) []byte {
+	imports := g.printAssemblerFunc(editor)
+	if editor == nil {
 		g.P(g.HeaderBuf, g.pkgAssembler)
+		g.printImportsOf(imports)
+		g.combine()
+		return g.Buf.Bytes()
 	}
-	g.printAssemblerFunc()
-	g.combine()
-	return g.Buf.Bytes()
+	if g.Prune {
+		g.pruneAssembler(editor)
+	}
+	if g.FunctionBuf.Len() == 0 {
+		if editor.Changed() {
+			return editor.Bytes()
+		}
+		return nil
+	}
+	funcs, err := internal.FormatDecls(g.FunctionBuf.Bytes())
+	if err != nil {
+		log.Printf("warning: internal error: invalid Go generated: %s", err)
+		log.Printf("warning: compile the package to analyze the error")
+		funcs = g.FunctionBuf.Bytes()
+	}
+	editor.AddImports(lo.Values(imports))
+	editor.Append(string(funcs))
+	return editor.Bytes()
 }
 
-func (g *Generate) contentBus(existFile *ast.File, isQuery bool) []byte {
+// contentBus returns a new bus file when editor is nil. Otherwise, it prunes the existing bus struct
+// with Prune, inserts its missing handler fields and imports with Bus, and returns nil when nothing
+// changed. handlerPath is the import path of the handler package.
+func (g *Generate) contentBus(editor *internal.GoEditor, isQuery bool, handlerPath internal.GoImportPath) []byte {
 	var cqrsList []*internal.CQRSFile
 	var tp string
 	if isQuery {
@@ -456,67 +352,113 @@ func (g *Generate) contentBus(existFile *ast.File, isQuery bool) []byte {
 		cqrsList = g.CQRSList.GetCommands()
 		tp = "Commands"
 	}
-	if len(cqrsList) == 0 {
+	if len(cqrsList) == 0 && editor == nil {
 		return nil
 	}
 
-	g.P(g.HeaderBuf, g.pkgBus)
-
-	importSpecs, busFields := internal.InspectBus(existFile, tp)
-	if len(importSpecs) != 0 {
-		g.P(g.ImportsBuf, "import (")
-		for _, spec := range importSpecs {
-			var name string
-			if spec.Name != nil {
-				name = spec.Name.Name + " "
+	var busStruct *ast.StructType
+	existName := make(map[string]struct{})
+	if editor != nil {
+		busStruct = editor.FindStruct(tp)
+		if busStruct == nil {
+			log.Fatalf("error: %s struct not found in bus file", tp)
+		}
+		for _, field := range busStruct.Fields.List {
+			for _, name := range field.Names {
+				existName[name.Name] = struct{}{}
 			}
-			g.P(g.ImportsBuf, name, spec.Path.Value)
 		}
-		g.P(g.ImportsBuf, ")")
-	}
-
-	g.P(g.FunctionBuf, fmt.Sprintf(`type %s struct {`, tp))
-	existName := make(map[string]struct{})
-	if len(busFields) != 0 {
-		for _, field := range busFields {
-			ft := field.Type.(*ast.SelectorExpr)
-			g.P(g.FunctionBuf, field.Names[0].Name, " ", ft.X.(*ast.Ident).Name, ".", ft.Sel.Name)
-			existName[field.Names[0].Name] = struct{}{}
+		if g.Prune {
+			g.pruneBus(editor, busStruct, handlerPath, cqrsList)
+		}
+		if !g.Bus {
+			if !editor.Changed() {
+				return nil
+			}
+			return editor.Bytes()
 		}
 	}
 
+	imports := make(map[string]*internal.GoImport)
+	var fields bytes.Buffer
 	for _, file := range cqrsList {
 		if _, ok := existName[file.Endpoint]; ok {
 			continue
 		}
-		g.P(g.FunctionBuf, fmt.Sprintf("%s %s.%s", file.Endpoint, g.CQRSList[0].Package, file.Endpoint))
+		ident := file.GoImportPath(g.pkgImportPath).Ident(file.Endpoint)
+		ident.GoImport.Enable = true
+		imports[ident.GoImport.ImportPath] = ident.GoImport
+		g.P(&fields, "\t", file.Endpoint, " ", ident.Qualify())
+	}
+	_, hasQueue := existName[busQueueField]
+	if !isQuery && !hasQueue && lo.ContainsBy(cqrsList, func(x *internal.CQRSFile) bool { return x.Async }) {
+		ident := cqrsxPackage.Ident("CommandQueue")
+		ident.GoImport.Enable = true
+		imports[ident.GoImport.ImportPath] = ident.GoImport
+		g.P(&fields, "\t// ", busQueueField, " enqueues the @Async commands, which the worker of NewWorker handles.")
+		g.P(&fields, "\t", busQueueField, " ", ident.Qualify())
+	}
+
+	if editor == nil {
+		g.P(g.HeaderBuf, g.pkgBus)
+		g.printImportsOf(imports)
+		g.P(g.FunctionBuf, fmt.Sprintf(`type %s struct {`, tp))
+		_, _ = io.Copy(g.FunctionBuf, &fields)
+		g.P(g.FunctionBuf, `}`)
+		g.combine()
+		return g.Buf.Bytes()
+	}
+	if fields.Len() > 0 {
+		editor.AddImports(lo.Values(imports))
+		editor.InsertLines(busStruct.Fields.Closing, fields.String())
+	}
+	if !editor.Changed() {
+		return nil
 	}
-	g.P(g.FunctionBuf, `}`)
-	g.combine()
-	return g.Buf.Bytes()
+	return editor.Bytes()
 }
 
-func (g *Generate) printAssemblerFunc() {
+// printAssemblerFunc prints the assembler functions that editor does not declare yet, or all of
+// them when editor is nil, and returns the imports they require.
+func (g *Generate) printAssemblerFunc(editor *internal.GoEditor) map[string]*internal.GoImport {
+	imports := make(map[string]*internal.GoImport)
+	exist := func(name string) bool {
+		return editor != nil && editor.HasFunc("", name)
+	}
+	printFunc := func(text string, funcImports []*internal.GoImport) {
+		for _, imp := range funcImports {
+			imp.Enable = true
+			imports[imp.ImportPath] = imp
+		}
+		g.P(g.FunctionBuf, text)
+	}
 	for _, info := range g.Funcs {
 		if info.Assembler == nil {
 			continue
 		}
-		if g.isExistFunc(info.Assembler.GetFuncNameTo()) || g.isExistFunc(info.Assembler.GetFuncNameFrom()) {
-			continue
+		if objectArgs := info.Assembler.ToParamsIdent.ObjectArgs; objectArgs != nil && objectArgs.Type == nil && objectArgs.GoImportPath == "" {
+			objectArgs.GoImportPath = internal.GoImportPath(g.pkgImportPath)
+		}
+		if result := info.Assembler.FromResultIdent; result != nil && result.ObjectArgs != nil && result.ObjectArgs.Type == nil && result.ObjectArgs.GoImportPath == "" {
+			result.ObjectArgs.GoImportPath = internal.GoImportPath(g.pkgImportPath)
 		}
-		if info.Assembler.ToParamsIdent.ObjectArgs.GoImportPath == "" {
-			info.Assembler.ToParamsIdent.ObjectArgs.GoImportPath = internal.GoImportPath(g.pkgImportPath)
+		if !exist(info.Assembler.GetFuncNameTo()) {
+			printFunc(info.Assembler.GenTextTo(), info.Assembler.ImportsTo())
 		}
-		if info.Assembler.FromResultIdent.ObjectArgs.GoImportPath == "" {
-			info.Assembler.FromResultIdent.ObjectArgs.GoImportPath = internal.GoImportPath(g.pkgImportPath)
+		if info.Assembler.IsQuery && !exist(info.Assembler.GetFuncNameFrom()) {
+			printFunc(info.Assembler.GenTextFrom(), info.Assembler.ImportsFrom())
 		}
-		g.P(g.FunctionBuf, info.Assembler.Gen())
 	}
+	return imports
 }
 
 func (g *Generate) printImports() {
+	g.printImportsOf(g.Imports)
+}
+
+func (g *Generate) printImportsOf(imports map[string]*internal.GoImport) {
 	g.P(g.ImportsBuf, "import (")
-	for _, imp := range g.Imports {
+	for _, imp := range imports {
 		if !imp.Enable {
 			continue
 		}
@@ -541,10 +483,14 @@ func (g *Generate) printHeaderImpl() {
 
 func (g *Generate) printFunctionImpl() {
 	typeName := buildTypeName(g.SrvName)
-	g.P(g.FunctionBuf, "type ", typeName, " struct", `{
-	queries    *bus.Queries
-	commands   *bus.Commands
-}`)
+	g.P(g.FunctionBuf, "type ", typeName, " struct {")
+	if g.busQueryImportPath != "" {
+		g.P(g.FunctionBuf, "queries *", g.busQueryImportPath.Ident("Queries"))
+	}
+	if g.busCommandImportPath != "" {
+		g.P(g.FunctionBuf, "commands *", g.busCommandImportPath.Ident("Commands"))
+	}
+	g.P(g.FunctionBuf, "}")
 	g.P(g.FunctionBuf)
 	g.P(g.FunctionBuf)
 	for _, info := range g.Funcs {
@@ -563,178 +509,64 @@ func (g *Generate) printRouterInfoImpl(typeName string, info *internal.FuncInfo)
 	if info.Param2 == nil {
 		return
 	}
-	if info.Result1 == nil {
-		return
-	}
 
 	typeShort := "provider"
 	if g.SrvTypeShort != "" {
 		typeShort = g.SrvTypeShort
 	}
-	builds := []any{fmt.Sprintf("func(%s *", typeShort), typeName, ") ", info.FuncName, "(ctx ", contextPackage.Ident("Context"), ","}
+	builds := []any{fmt.Sprintf("func(%s *", typeShort), typeName, ") ", info.FuncName, "(", info.CtxName, " ", contextPackage.Ident("Context"), ", "}
 
-	if info.Param2.Bytes {
-		builds = append(builds, "req []byte")
-	} else if info.Param2.String {
-		builds = append(builds, "req string")
-	} else if info.Param2.Reader {
-		builds = append(builds, "req ", ioPackage.Ident("Reader"))
-	} else if objectArgs := info.Param2.ObjectArgs; objectArgs != nil {
-		paramObj := *info.Param2.ObjectArgs
-		if paramObj.GoImportPath == "" {
-			paramObj.GoImportPath = internal.GoImportPath(g.pkgImportPath)
-		}
-		builds = append(builds, "req *", paramObj.GoImportPath.Ident(objectArgs.Name))
-	} else {
-		log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or *struct{}", info.FuncName)
+	if objectArgs := info.Param2.ObjectArgs; objectArgs != nil && objectArgs.Type == nil && objectArgs.GoImportPath == "" {
+		objectArgs.GoImportPath = internal.GoImportPath(g.pkgImportPath)
 	}
+	builds = append(builds, info.ReqName, " ", g.qualify(info.Param2.GoType()))
 
 	builds = append(builds, ") (")
 
-	if info.Result1.Bytes {
-		builds = append(builds, "res []byte")
-	} else if info.Result1.String {
-		builds = append(builds, "res string")
-	} else if info.Result1.Reader {
-		builds = append(builds, "res ", ioPackage.Ident("Reader"))
-	} else if objectArgs := info.Result1.ObjectArgs; objectArgs != nil {
-		resultObj := *info.Result1.ObjectArgs
-		if resultObj.GoImportPath == "" {
-			resultObj.GoImportPath = internal.GoImportPath(g.pkgImportPath)
-		}
-		builds = append(builds, "res *", resultObj.GoImportPath.Ident(objectArgs.Name))
-	} else {
-		log.Fatalf("error: func %s 2th param is invalid, must be []byte or string or *struct{}", info.FuncName)
+	if info.Result1 != nil {
+		if objectArgs := info.Result1.ObjectArgs; objectArgs != nil && objectArgs.Type == nil && objectArgs.GoImportPath == "" {
+			objectArgs.GoImportPath = internal.GoImportPath(g.pkgImportPath)
+		}
+		builds = append(builds, info.ResName, " ", g.qualify(info.Result1.GoType()), ", ")
 	}
 
-	builds = append(builds, ", err error)", " {")
+	builds = append(builds, info.ErrName, " error)", " {")
 
 	g.P(g.FunctionBuf)
 	g.P(g.FunctionBuf, builds...)
-	g.P(g.FunctionBuf, info.GenBody())
+	assemblerPkg, cqrsxPkg := "", ""
+	if info.CQRS != nil {
+		ident := g.assemblerImportPath.Ident("")
+		assemblerPkg = g.qualify(ident.GoImport.PackageName, []*internal.GoImport{ident.GoImport})
+	}
+	if info.CQRS != nil && info.CQRS.Async {
+		ident := cqrsxPackage.Ident("")
+		cqrsxPkg = g.qualify(ident.GoImport.PackageName, []*internal.GoImport{ident.GoImport})
+	}
+	g.P(g.FunctionBuf, info.GenBody(typeShort, assemblerPkg, cqrsxPkg))
 	g.P(g.FunctionBuf, "}")
 	g.P(g.FunctionBuf)
 }
 
-func (g *Generate) appendImports() {
-	for _, imp := range g.Imports {
-		if g.isExistImport(imp.ImportPath) {
-			continue
-		}
-		if !imp.Enable {
-			continue
-		}
-		if imp.ImportPath == "" {
-			continue
-		}
-		spec := g.buildImportSpec(imp)
-		if len(g.implDeclImports) == 0 {
-			g.implDeclImports = append(g.implDeclImports, &ast.GenDecl{
-				Tok: token.IMPORT,
-			})
-		}
-		g.implDeclImports[0].Specs = append(g.implDeclImports[0].Specs, spec)
+// qualify enables the imports a type expression depends on and returns the expression.
+func (g *Generate) qualify(expr string, imports []*internal.GoImport) string {
+	for _, imp := range imports {
+		imp.Enable = true
+		g.Imports[imp.ImportPath] = imp
 	}
+	return expr
 }
 
-func (g *Generate) appendFuncs() {
+func (g *Generate) appendFuncs(editor *internal.GoEditor) {
 	typeName := buildTypeName(g.SrvName)
 	for _, info := range g.Funcs {
-		if g.isExistFunc(info.FuncName) {
-			continue
-		}
 		if info.CQRS != nil {
 			g.CQRSList = append(g.CQRSList, info.CQRS)
 		}
-		//g.implDeclFuncs = append(g.implDeclFuncs, g.buildFuncDecl(g.pkgImportPath, typeName, info))
-		g.buildFuncDecl(g.pkgImportPath, typeName, info)
-	}
-}
-
-func (g *Generate) isExistFunc(name string) bool {
-	for _, info := range g.implDeclFuncs {
-		if info.Name.Name == name {
-			return true
-		}
-	}
-	return false
-}
-
-func (g *Generate) isExistImport(name string) bool {
-	if len(g.implDeclImports) == 0 {
-		return false
-	}
-	for _, spec := range g.implDeclImports[0].Specs {
-		importSpec, ok := spec.(*ast.ImportSpec)
-		if !ok {
+		if editor.HasFunc(typeName, info.FuncName) {
 			continue
 		}
-		if strings.Trim(importSpec.Path.Value, `"`) == name {
-			return true
-		}
-	}
-	return false
-}
-
-func (g *Generate) buildFuncDecl(importPath, typeName string, info *internal.FuncInfo) *ast.FuncDecl {
-	g.printRouterInfoImpl(typeName, info)
-	//decl := &ast.FuncDecl{
-	//	Recv: &ast.FieldList{
-	//		List: []*ast.Field{
-	//			{
-	//				Names: []*ast.Ident{ast.NewIdent("provider")},
-	//				Type:  &ast.StarExpr{X: &ast.Ident{Name: typeName}},
-	//			},
-	//		},
-	//	},
-	//	Name: ast.NewIdent(info.FuncName),
-	//	Body: &ast.BlockStmt{List: []ast.Stmt{
-	//		&ast.ReturnStmt{},
-	//	}},
-	//}
-	//
-	//// params
-	//params := info.Params.List
-	//param2 := params[1]
-	//if objectArgs := info.Param2.ObjectArgs; objectArgs != nil {
-	//	paramObj := *info.Param2.ObjectArgs
-	//	if paramObj.GoImportPath == "" {
-	//		paramObj.GoImportPath = internal.GoImportPath(importPath)
-	//	}
-	//	gp := paramObj.GoImportPath.Ident(paramObj.Name)
-	//	gp.GoImport.Enable = true
-	//	g.imports[gp.GoImport.ImportPath] = gp.GoImport
-	//	param2.Type = &ast.StarExpr{X: &ast.SelectorExpr{X: ast.NewIdent(gp.GoImport.PackageName), Sel: ast.NewIdent(gp.GoName)}}
-	//}
-	//
-	//// results
-	//results := info.Results.List
-	//result1 := results[0]
-	//if objectArgs := info.Result1.ObjectArgs; objectArgs != nil {
-	//	resultObj := *info.Result1.ObjectArgs
-	//	if resultObj.GoImportPath == "" {
-	//		resultObj.GoImportPath = internal.GoImportPath(importPath)
-	//	}
-	//	gp := resultObj.GoImportPath.Ident(resultObj.Name)
-	//	gp.GoImport.Enable = true
-	//	g.imports[gp.GoImport.ImportPath] = gp.GoImport
-	//	result1.Type = &ast.StarExpr{X: &ast.SelectorExpr{X: ast.NewIdent(gp.GoImport.PackageName), Sel: ast.NewIdent(gp.GoName)}}
-	//}
-	//decl.Type = &ast.FuncType{
-	//	Params:  &ast.FieldList{List: params},
-	//	Results: &ast.FieldList{List: results},
-	//}
-	//return decl
-	return nil
-}
-
-func (g *Generate) buildImportSpec(info *internal.GoImport) *ast.ImportSpec {
-	return &ast.ImportSpec{
-		Name: ast.NewIdent(info.PackageName),
-		Path: &ast.BasicLit{
-			Kind:  token.STRING,
-			Value: `"` + info.ImportPath + `"`,
-		},
+		g.printRouterInfoImpl(typeName, info)
 	}
 }
 
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-miya/gorsx/internal"
+	"go/format"
+	"go/types"
+	"sort"
+	"strconv"
+)
+
+const (
+	gorsPackage = internal.GoImportPath("github.com/go-miya/gorsx/gors")
+	httpPackage = internal.GoImportPath("net/http")
+)
+
+// goFile is a Go file generated as a whole, such as the handlers of the service or the decorators of a
+// bus, which gorsx rewrites on every run.
+type goFile struct {
+	pkgName string
+	pkgPath string
+	imports map[string]*internal.GoImport
+	buf     bytes.Buffer
+}
+
+func newGoFile(pkgName string, pkgPath string) *goFile {
+	return &goFile{pkgName: pkgName, pkgPath: pkgPath, imports: make(map[string]*internal.GoImport)}
+}
+
+// P prints a line, qualifying identifiers and types with the imports they need.
+func (f *goFile) P(v ...any) {
+	for _, x := range v {
+		switch x := x.(type) {
+		case *internal.GoIdent:
+			_, _ = fmt.Fprint(&f.buf, f.ident(x))
+		case types.Type:
+			_, _ = fmt.Fprint(&f.buf, f.typeString(x))
+		default:
+			_, _ = fmt.Fprint(&f.buf, x)
+		}
+	}
+	_, _ = fmt.Fprintln(&f.buf)
+}
+
+func (f *goFile) ident(x *internal.GoIdent) string {
+	if x.GoImport.ImportPath == "" || x.GoImport.ImportPath == f.pkgPath {
+		return x.GoName
+	}
+	f.imports[x.GoImport.ImportPath] = x.GoImport
+	return x.Qualify()
+}
+
+func (f *goFile) typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		if pkg.Path() == f.pkgPath {
+			return ""
+		}
+		f.imports[pkg.Path()] = &internal.GoImport{PackageName: pkg.Name(), ImportPath: pkg.Path()}
+		return pkg.Name()
+	})
+}
+
+// zero returns the zero value expression of t.
+func (f *goFile) zero(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return f.typeString(t) + "{}"
+	}
+	return "nil"
+}
+
+// Content returns the formatted source of the file.
+func (f *goFile) Content() ([]byte, error) {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintln(&buf, "// Code generated by gorsx. DO NOT EDIT.")
+	_, _ = fmt.Fprintln(&buf)
+	_, _ = fmt.Fprintln(&buf, "package", f.pkgName)
+	_, _ = fmt.Fprintln(&buf)
+	if len(f.imports) > 0 {
+		paths := make([]string, 0, len(f.imports))
+		for importPath := range f.imports {
+			paths = append(paths, importPath)
+		}
+		sort.Strings(paths)
+		_, _ = fmt.Fprintln(&buf, "import (")
+		for _, importPath := range paths {
+			_, _ = fmt.Fprintln(&buf, f.imports[importPath].PackageName, strconv.Quote(importPath))
+		}
+		_, _ = fmt.Fprintln(&buf, ")")
+		_, _ = fmt.Fprintln(&buf)
+	}
+	_, _ = buf.Write(f.buf.Bytes())
+	return format.Source(buf.Bytes())
+}
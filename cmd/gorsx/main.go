@@ -8,8 +8,10 @@ import (
 	"github.com/go-leo/gox/slicex"
 	"github.com/go-miya/gorsx/cmd"
 	"github.com/go-miya/gorsx/internal"
+	"github.com/samber/lo"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"golang.org/x/tools/go/packages"
 	"log"
 	"os"
@@ -21,6 +23,10 @@ import (
 var (
 	serviceName = flag.String("service", "", "service interface Name; must be set")
 	ImplPath    = flag.String("impl", "", "service implementation Path")
+	bus         = flag.Bool("bus", false, "generate the query and command buses of @QueryBusPath and @CommandBusPath")
+	prune       = flag.Bool("prune", false, "delete untouched generated code of methods removed from the service, and mark customized code as orphan")
+	router      = flag.String("router", cmd.RouterStd, "router the generated Register<Service> registers the @GORS routes on: std, gin, echo or chi")
+	openapi     = flag.String("openapi", "", "OpenAPI 3.1 document output file, generated from the @GORS annotations")
 )
 
 // Usage is a replacement usage function for the flags package.
@@ -32,6 +38,14 @@ func Usage() {
 	fmt.Fprintf(os.Stderr, "Flags:\n")
 	fmt.Fprintf(os.Stderr, "\tgorsx -assembled S\n")
 	fmt.Fprintf(os.Stderr, "Flags:\n")
+	fmt.Fprintf(os.Stderr, "\tgorsx -bus\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	fmt.Fprintf(os.Stderr, "\tgorsx -prune\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	fmt.Fprintf(os.Stderr, "\tgorsx -router gin|echo|chi|std\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	fmt.Fprintf(os.Stderr, "\tgorsx -openapi out.yaml\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
 	flag.PrintDefaults()
 }
 
@@ -50,6 +64,13 @@ func main() {
 		flag.Usage()
 		os.Exit(2)
 	}
+	switch *router {
+	case cmd.RouterStd, cmd.RouterGin, cmd.RouterEcho, cmd.RouterChi:
+	default:
+		log.Printf("error: unknown router %q, one of std, gin, echo and chi is required", *router)
+		flag.Usage()
+		os.Exit(2)
+	}
 	// We accept either one directory or a list of files. Which do we have?
 	args := flag.Args()
 	if len(args) == 0 {
@@ -73,14 +94,22 @@ func main() {
 		ImportsBuf:       &bytes.Buffer{},
 		FunctionBuf:      &bytes.Buffer{},
 		Imports:          imports,
+		PkgName:          pack.Name,
 		SrvName:          *serviceName,
 		SrvTypeShort:     "ctrl",
 		Funcs:            nil,
 		UsedPackageNames: make(map[string]bool),
+		Bus:              *bus,
+		Prune:            *prune,
+		Router:           *router,
+		PackageNames:     internal.PackageNames(pack),
 	}
+	// the params and results are qualified as in the service file, whose imports tell same-named packages apart
+	importNames := internal.ImportNames(g.PackageNames, serviceFile)
 
 	var files []*internal.CQRSFile
 	var cqrsPath *internal.Path
+	var serviceComments []string
 	outDir, err := detectOutputDir(pack.GoFiles)
 	if err != nil {
 		log.Fatal(err)
@@ -92,11 +121,13 @@ func main() {
 			log.Println("not found", serviceName, "annotation:", `"@CQRS @QueryPath() @CommandPath()"`)
 			os.Exit(2)
 		}
-		var comments []string
 		for _, comment := range serviceDecl.Doc.List {
-			comments = append(comments, comment.Text)
+			serviceComments = append(serviceComments, comment.Text)
 		}
-		cqrsPath = internal.NewPath(comments)
+		cqrsPath = internal.NewPath(serviceComments)
+		serviceAuth := internal.NewAuthAnnotation(serviceName, serviceComments)
+		g.RouteGroup = internal.NewRouteGroup(serviceComments)
+		g.Auth = serviceAuth
 		queryAbs := filepath.Join(outDir, cqrsPath.Query)
 		commandAbs := filepath.Join(outDir, cqrsPath.Command)
 
@@ -113,43 +144,86 @@ func main() {
 				log.Fatalf("error: func %s not convert to *ast.FuncType", methodName)
 			}
 
-			funcInfo := internal.NewMethodInfo(methodName.Name, funcType)
+			methodObj, ok := pack.TypesInfo.Defs[methodName].(*types.Func)
+			if !ok {
+				log.Fatalf("error: func %s type info not found", methodName)
+			}
+
+			funcInfo := internal.NewMethodInfo(methodName.Name, funcType, methodObj.Type().(*types.Signature))
+			funcInfo.ImportNames = importNames
 			err := funcInfo.Check()
 			if err != nil {
 				log.Fatal(err)
 			}
-
-			funcInfo.Param2 = g.CheckAndGetParam2(funcType, methodName)
-			funcInfo.Result1 = g.CheckAndGetResult1(funcType, methodName)
 			g.Funcs = append(g.Funcs, funcInfo)
 
 			// cqrs
-			if method.Doc == nil {
-				continue
+			var comments []string
+			if method.Doc != nil {
+				comments = slicex.Map[[]*ast.Comment, []string](
+					method.Doc.List,
+					func(i int, e1 *ast.Comment) string { return e1.Text },
+				)
+			}
+			funcInfo.Comments = comments
+			funcInfo.Auth = serviceAuth.Override(methodName.Name, comments)
+			funcInfo.Route = internal.NewRoute(methodName.Name, comments)
+			if funcInfo.Route != nil {
+				funcInfo.Route.Path = g.RouteGroup.FullPath(funcInfo.Route.Path)
+				funcInfo.Route.Pos = pack.Fset.Position(method.Pos())
+			}
+			if funcInfo.Auth != nil && funcInfo.Route == nil {
+				log.Fatalf("error: func %s requires %s or %s, which only the handlers of @GORS methods enforce, mark it %s otherwise",
+					methodName, internal.Auth, internal.Permission, internal.Public)
 			}
-			comments := slicex.Map[[]*ast.Comment, []string](
-				method.Doc.List,
-				func(i int, e1 *ast.Comment) string { return e1.Text },
-			)
 			cqrsFile := internal.NewFileFromComment(
 				methodName.Name, queryAbs, commandAbs, cqrsPath.Query, cqrsPath.Command, comments, cqrsPath.NamePrefix)
 			if cqrsFile == nil {
 				continue
 			}
+			cqrsFile.Service = g.SrvName
+			if err := funcInfo.CheckCQRS(cqrsFile); err != nil {
+				log.Fatal(err)
+			}
 			files = append(files, cqrsFile)
 			funcInfo.CQRS = cqrsFile
 			funcInfo.Assembler = internal.NewAssemblerCore(
 				cqrsFile.IsQuery(),
 				methodName.Name,
 				funcInfo.Param2,
-				&internal.Result{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetReqName(), GoImportPath: internal.GoImportPath(cqrsFile.Package)}},
-				&internal.Param{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetRespName(), GoImportPath: internal.GoImportPath(cqrsFile.Package)}},
+				&internal.Result{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetReqName(), GoImportPath: cqrsFile.GoImportPath(pack.PkgPath)}},
+				&internal.Param{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetRespName(), GoImportPath: cqrsFile.GoImportPath(pack.PkgPath)}},
 				funcInfo.Result1,
 			)
 		}
 	}
-	// gen service implementation
-	g.GenerateProto(outDir, pack.PkgPath, *ImplPath, cqrsPath)
+	// declare the key fields of the @Cache queries and the @Invalidate commands in their structs
+	for _, method := range serviceMethods {
+		if slicex.IsEmpty(method.Names) {
+			continue
+		}
+		funcInfo, ok := lo.Find(g.Funcs, func(info *internal.FuncInfo) bool { return info.FuncName == method.Names[0].Name })
+		if !ok || funcInfo.CQRS == nil {
+			continue
+		}
+		if err := funcInfo.ResolveKeyFields(files, pack.Fset.Position(method.Pos())); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// check the route table before writing anything
+	routes := g.Funcs
+	if route := g.JobRoute(); route != nil {
+		route.Route.Pos = pack.Fset.Position(serviceSpec.Pos())
+		routes = append(routes[:len(routes):len(routes)], route)
+	}
+	if errs := internal.CheckRoutes(routes); len(errs) > 0 {
+		for _, err := range errs {
+			log.Println(err)
+		}
+		os.Exit(1)
+	}
+	// gen service implementation and assembler, and the bus with -bus
+	g.Generate(outDir, pack.PkgPath, *ImplPath, cqrsPath)
 	// gen cqrs
 	for _, f := range files {
 		if err := f.Gen(); err != nil {
@@ -158,6 +232,14 @@ func main() {
 		}
 		log.Printf("%s.%s.%s wrote %s\n", pack.PkgPath, *serviceName, f.Endpoint, f.AbsFilename)
 	}
+	// gen openapi
+	if len(*openapi) > 0 {
+		spec := internal.NewOpenAPI(*serviceName, serviceComments, g.Funcs)
+		if err := os.WriteFile(*openapi, spec.YAML(), 0o644); err != nil {
+			log.Fatalf("writing openapi: %s", err)
+		}
+		log.Printf("%s.%s wrote %s\n", pack.PkgPath, *serviceName, *openapi)
+	}
 }
 
 func loadPkg(args []string) *packages.Package {
@@ -0,0 +1,473 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/go-miya/gorsx/internal"
+	"go/types"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// generateHandlers generates the net/http handlers of the @GORS routes of the service, which bind the
+// request, call the service and render the response.
+func (g *Generate) generateHandlers(outDir string) {
+	filename := filepath.Join(outDir, fmt.Sprintf("%s_gors.go", strings.ToLower(g.SrvName)))
+	f := newGoFile(g.PkgName, g.pkgImportPath)
+	g.printRoutes(f)
+	if route := g.JobRoute(); route != nil {
+		f.P()
+		g.printJobRoutes(f, route)
+	}
+	for _, info := range g.Funcs {
+		if info.Route == nil || info.Signature == nil {
+			continue
+		}
+		f.P()
+		g.printHandler(f, info)
+		if g.validates(info) {
+			g.printValidate(f, info)
+		}
+	}
+	src, err := f.Content()
+	if err != nil {
+		log.Fatalf("generateHandlers.Content failed, %v", err)
+	}
+	if err := writeContent(filename, src); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+	log.Printf("%s.%s wrote handlers %s", g.pkgImportPath, g.SrvName, filename)
+}
+
+func (g *Generate) printRoutes(f *goFile) {
+	f.P("// Paths of the routes of ", g.SrvName, ", prefixed by the @Path annotations of the service.")
+	f.P("const (")
+	for _, info := range g.Funcs {
+		if info.Route == nil || info.Signature == nil {
+			continue
+		}
+		f.P(pathName(g.SrvName, info), " = ", fmt.Sprintf("%q", info.Route.Path))
+	}
+	f.P(")")
+	f.P()
+	f.P("// ", g.SrvName, "Routes returns the routes of the @GORS methods of ", g.SrvName, ".")
+	f.P("func ", g.SrvName, "Routes(srv ", g.SrvName, ", opts ...", gorsPackage.Ident("Option"), ") []", gorsPackage.Ident("Route"), " {")
+	f.P("o := ", gorsPackage.Ident("NewOptions"), "(opts...)")
+	f.P("return []", gorsPackage.Ident("Route"), "{")
+	for _, info := range g.Funcs {
+		if info.Route == nil || info.Signature == nil {
+			continue
+		}
+		handler := handlerName(g.SrvName, info) + "(srv, o)"
+		var middlewares []string
+		if g.RouteGroup != nil {
+			middlewares = append(middlewares, g.RouteGroup.Middlewares...)
+		}
+		middlewares = append(middlewares, info.Route.Middlewares...)
+		if len(middlewares) > 0 {
+			handler = "o.Wrap(" + handler
+			for _, name := range middlewares {
+				handler += fmt.Sprintf(", %q", name)
+			}
+			handler += ")"
+		}
+		f.P("{Method: ", httpMethodIdent(info.Route.Method), ", Path: ", pathName(g.SrvName, info), ", Handler: ", handler, "},")
+	}
+	f.P("}")
+	f.P("}")
+	f.P()
+	g.printRegister(f)
+}
+
+// Routers the generated Register<Service> function can register the routes on.
+const (
+	RouterStd  = "std"
+	RouterGin  = "gin"
+	RouterEcho = "echo"
+	RouterChi  = "chi"
+)
+
+var (
+	ginPackage  = internal.GoImportPath("github.com/gin-gonic/gin")
+	echoPackage = internal.GoImportPath("github.com/labstack/echo/v4")
+	chiPackage  = internal.GoImportPath("github.com/go-chi/chi/v5")
+)
+
+// printRegister prints Register<Service>, which registers the routes of the service on the router
+// selected by -router, adapting its path syntax and path parameters.
+func (g *Generate) printRegister(f *goFile) {
+	g.printRegisterOf(f, "Register"+g.SrvName, "the routes of "+g.SrvName, []any{"srv ", g.SrvName}, g.SrvName+"Routes(srv, opts...)")
+}
+
+// printRegisterOf prints the function name registering the routes returned by routes, described by
+// what, on the router selected by -router, taking arg, which routes is called with.
+func (g *Generate) printRegisterOf(f *goFile, name string, what string, arg []any, routes string) {
+	params := "func(name string, wildcard bool) string {"
+	signature := func(router ...any) []any {
+		args := append(append([]any{"func ", name, "("}, router...), ", ")
+		return append(append(args, arg...), ", opts ...", gorsPackage.Ident("Option"), ") {")
+	}
+	switch g.Router {
+	case RouterGin:
+		f.P("// ", name, " registers ", what, " on a gin router.")
+		f.P(signature("router ", ginPackage.Ident("IRoutes"))...)
+		f.P("for _, route := range ", routes, " {")
+		f.P("route := route")
+		f.P("router.Handle(route.Method, route.Path, func(c *", ginPackage.Ident("Context"), ") {")
+		f.P("route.Serve(c.Writer, c.Request, ", params)
+		f.P("return c.Param(name)")
+		f.P("})")
+		f.P("})")
+	case RouterEcho:
+		f.P("// ", name, " registers ", what, " on an echo group.")
+		f.P(signature("group *", echoPackage.Ident("Group"))...)
+		f.P("for _, route := range ", routes, " {")
+		f.P("route := route")
+		f.P("group.Add(route.Method, route.EchoPath(), func(c ", echoPackage.Ident("Context"), ") error {")
+		f.P("route.Serve(c.Response(), c.Request(), ", params)
+		f.P("if wildcard {")
+		f.P("return c.Param(\"*\")")
+		f.P("}")
+		f.P("return c.Param(name)")
+		f.P("})")
+		f.P("return nil")
+		f.P("})")
+	case RouterChi:
+		f.P("// ", name, " registers ", what, " on a chi router.")
+		f.P(signature("router ", chiPackage.Ident("Router"))...)
+		f.P("for _, route := range ", routes, " {")
+		f.P("route := route")
+		f.P("router.Method(route.Method, route.ChiPattern(), ", httpPackage.Ident("HandlerFunc"), "(func(w ", httpPackage.Ident("ResponseWriter"), ", r *", httpPackage.Ident("Request"), ") {")
+		f.P("route.Serve(w, r, ", params)
+		f.P("if wildcard {")
+		f.P("return ", chiPackage.Ident("URLParam"), "(r, \"*\")")
+		f.P("}")
+		f.P("return ", chiPackage.Ident("URLParam"), "(r, name)")
+		f.P("})")
+		f.P("}))")
+	default:
+		f.P("// ", name, " registers ", what, " on mux.")
+		f.P(signature("mux *", httpPackage.Ident("ServeMux"))...)
+		f.P("for _, route := range ", routes, " {")
+		f.P("route := route")
+		f.P("mux.Handle(route.ServeMuxPattern(), ", httpPackage.Ident("HandlerFunc"), "(func(w ", httpPackage.Ident("ResponseWriter"), ", r *", httpPackage.Ident("Request"), ") {")
+		f.P("route.Serve(w, r, ", params)
+		f.P("return r.PathValue(name)")
+		f.P("})")
+		f.P("}))")
+	}
+	f.P("}")
+	f.P("}")
+}
+
+// pathName returns the name of the constant of the path of the route of the method.
+func pathName(srvName string, info *internal.FuncInfo) string {
+	return srvName + info.FuncName + "Path"
+}
+
+// idempotencyHeader returns the header carrying the idempotency key of an @Idempotent command.
+func idempotencyHeader(info *internal.FuncInfo) (string, bool) {
+	if info.CQRS == nil || info.CQRS.Idempotent == nil {
+		return "", false
+	}
+	return info.CQRS.Idempotent.Header, true
+}
+
+// isAsync reports whether the method is an @Async command, which responds 202 Accepted with its job.
+func isAsync(info *internal.FuncInfo) bool {
+	return info.CQRS != nil && info.CQRS.Async
+}
+
+// JobRoute returns the route of the status of the jobs of the @Async commands with routes, or nil when
+// the service has none, so that the route table is checked with it.
+func (g *Generate) JobRoute() *internal.FuncInfo {
+	for _, info := range g.Funcs {
+		if info.Route == nil || info.Signature == nil || !isAsync(info) {
+			continue
+		}
+		path := internal.JoinPath("/jobs/:id")
+		if g.RouteGroup != nil {
+			path = g.RouteGroup.FullPath("/jobs/:id")
+		}
+		return &internal.FuncInfo{FuncName: g.SrvName + "JobRoutes", Route: &internal.Route{Method: http.MethodGet, Path: path}}
+	}
+	return nil
+}
+
+// jobPathName returns the name of the constant of the path of the job route.
+func (g *Generate) jobPathName() string {
+	return g.SrvName + "JobPath"
+}
+
+// printJobRoutes prints <Service>JobRoutes and Register<Service>Jobs, which serve the status of the jobs
+// of the @Async commands of the service from their queue, authorized by the @Auth and @Permission of
+// the service.
+func (g *Generate) printJobRoutes(f *goFile, route *internal.FuncInfo) {
+	var middlewares string
+	if g.RouteGroup != nil {
+		for _, name := range g.RouteGroup.Middlewares {
+			middlewares += fmt.Sprintf(", %q", name)
+		}
+	}
+	handler := "_" + g.SrvName + "_Job_Handler"
+	f.P("// ", g.jobPathName(), " is the path of the route of the status of the jobs of the @Async commands.")
+	f.P("const ", g.jobPathName(), " = ", fmt.Sprintf("%q", route.Route.Path))
+	f.P()
+	f.P("// ", g.SrvName, "JobRoutes returns the route of the status of the jobs of the @Async commands of ", g.SrvName, ",")
+	f.P("// read from queue.")
+	f.P("func ", g.SrvName, "JobRoutes(queue ", cqrsxPackage.Ident("CommandQueue"), ", opts ...", gorsPackage.Ident("Option"), ") []", gorsPackage.Ident("Route"), " {")
+	f.P("o := ", gorsPackage.Ident("NewOptions"), "(opts...)")
+	if middlewares != "" {
+		f.P("return []", gorsPackage.Ident("Route"), "{{Method: ", httpPackage.Ident("MethodGet"), ", Path: ", g.jobPathName(), ", Handler: o.Wrap(", handler, "(queue, o)", middlewares, ")}}")
+	} else {
+		f.P("return []", gorsPackage.Ident("Route"), "{{Method: ", httpPackage.Ident("MethodGet"), ", Path: ", g.jobPathName(), ", Handler: ", handler, "(queue, o)}}")
+	}
+	f.P("}")
+	f.P()
+	g.printRegisterOf(f, "Register"+g.SrvName+"Jobs", "the route of the status of the jobs of "+g.SrvName,
+		[]any{"queue ", cqrsxPackage.Ident("CommandQueue")}, g.SrvName+"JobRoutes(queue, opts...)")
+	f.P()
+	f.P("func ", handler, "(queue ", cqrsxPackage.Ident("CommandQueue"), ", o *", gorsPackage.Ident("Options"), ") ", httpPackage.Ident("Handler"), " {")
+	f.P("return ", httpPackage.Ident("HandlerFunc"), "(func(w ", httpPackage.Ident("ResponseWriter"), ", r *", httpPackage.Ident("Request"), ") {")
+	if g.Auth != nil {
+		// the jobs are as private as the endpoints of the service
+		requirement := g.Auth.Literal(f.ident(gorsPackage.Ident("AuthRequirement")), g.SrvName+".Jobs")
+		f.P("if err := ", gorsPackage.Ident("Authorize"), "(r.Context(), o.Authorizer, ", requirement, "); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+	}
+	f.P("job, err := queue.Job(r.Context(), ", gorsPackage.Ident("PathParams"), "(r.Context())[\"id\"])")
+	f.P("// the queue may be shared by the commands of other services")
+	f.P("if err == nil && !", stringsPackage.Ident("HasPrefix"), "(job.Command, ", fmt.Sprintf("%q", g.SrvName+"."), ") {")
+	f.P("err = ", cqrsxPackage.Ident("ErrJobNotFound"))
+	f.P("}")
+	f.P("if err != nil {")
+	f.P("o.ErrorHandler(w, r, err)")
+	f.P("return")
+	f.P("}")
+	f.P("if err := ", gorsPackage.Ident("RenderJSON"), "(w, r, ", httpPackage.Ident("StatusOK"), ", job); err != nil {")
+	f.P("o.ErrorHandler(w, r, err)")
+	f.P("}")
+	f.P("})")
+	f.P("}")
+}
+
+func handlerName(srvName string, info *internal.FuncInfo) string {
+	return fmt.Sprintf("_%s_%s_Handler", srvName, info.FuncName)
+}
+
+func (g *Generate) printHandler(f *goFile, info *internal.FuncInfo) {
+	f.P("func ", handlerName(g.SrvName, info), "(srv ", g.SrvName, ", o *", gorsPackage.Ident("Options"), ") ", httpPackage.Ident("Handler"), " {")
+	f.P("return ", httpPackage.Ident("HandlerFunc"), "(func(w ", httpPackage.Ident("ResponseWriter"), ", r *", httpPackage.Ident("Request"), ") {")
+	if info.Auth != nil {
+		requirement := info.Auth.Literal(f.ident(gorsPackage.Ident("AuthRequirement")), g.SrvName+"."+info.FuncName)
+		f.P("if err := ", gorsPackage.Ident("Authorize"), "(r.Context(), o.Authorizer, ", requirement, "); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+	}
+	g.printHandlerBind(f, info)
+	if g.validates(info) {
+		g.printHandlerValidate(f, info)
+	}
+	ctx := "r.Context()"
+	if header, ok := idempotencyHeader(info); ok {
+		f.P("ctx := ", cqrsxPackage.Ident("WithIdempotencyKey"), "(r.Context(), r.Header.Get(", fmt.Sprintf("%q", header), "))")
+		ctx = "ctx"
+	}
+	if isAsync(info) {
+		f.P("ctx, job := ", cqrsxPackage.Ident("WithAcceptedJob"), "(", ctx, ")")
+		call := "err := srv."
+		if info.Result1 != nil {
+			call = "_, err := srv."
+		}
+		f.P("if ", call, info.FuncName, "(ctx, req); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+		f.P("if err := ", gorsPackage.Ident("RenderJSON"), "(w, r, ", httpPackage.Ident("StatusAccepted"), ", job); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("}")
+	} else if info.Result1 == nil {
+		f.P("if err := srv.", info.FuncName, "(", ctx, ", req); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+		f.P("w.WriteHeader(", httpPackage.Ident("StatusOK"), ")")
+	} else {
+		f.P("resp, err := srv.", info.FuncName, "(", ctx, ", req)")
+		f.P("if err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+		g.printHandlerRender(f, info)
+	}
+	f.P("})")
+	f.P("}")
+}
+
+// printHandlerBind prints the statements binding the request into req, whose body is limited by the
+// options unless it is streamed.
+func (g *Generate) printHandlerBind(f *goFile, info *internal.FuncInfo) {
+	reqType := info.Signature.Params().At(1).Type()
+	if !streamsBody(info) {
+		f.P("o.LimitBody(w, r)")
+	}
+	switch {
+	case info.Param2.Bytes:
+		f.P("req, err := ", gorsPackage.Ident("BindBytes"), "(r)")
+	case info.Param2.String:
+		f.P("req, err := ", gorsPackage.Ident("BindString"), "(r)")
+	case info.Param2.Reader:
+		f.P("var req ", ioPackage.Ident("Reader"), " = r.Body")
+		return
+	default:
+		target := "req"
+		if pointer, ok := reqType.(*types.Pointer); ok {
+			f.P("req := new(", pointer.Elem(), ")")
+		} else {
+			f.P("var req ", reqType)
+			target = "&req"
+		}
+		bindings := bindingFuncs(info.Route)
+		if len(bindings) == 0 {
+			return
+		}
+		args := []any{"if err := ", gorsPackage.Ident("Bind"), "(r, ", target}
+		for _, ident := range bindings {
+			args = append(args, ", ", ident)
+		}
+		f.P(append(args, "); err != nil {")...)
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+		return
+	}
+	f.P("if err != nil {")
+	f.P("o.ErrorHandler(w, r, err)")
+	f.P("return")
+	f.P("}")
+}
+
+// streamsBody reports whether the request body is streamed to the implementation, as an io.Reader param,
+// or to the disk, as a @FormMultipartBinding form, so it is not limited to MaxBodyBytes.
+func streamsBody(info *internal.FuncInfo) bool {
+	return info.Param2.Reader || slices.Contains(info.Route.Bindings, internal.FormMultipartBinding)
+}
+
+// printHandlerRender prints the statements rendering resp with the renderer of the @XxxRender
+// annotation. []byte, string and io.Reader results are written as they are.
+func (g *Generate) printHandlerRender(f *goFile, info *internal.FuncInfo) {
+	route := info.Route
+	contentType := fmt.Sprintf("%q", rawContentType(route, info.Result1))
+	switch {
+	case info.Result1.Bytes:
+		f.P(gorsPackage.Ident("RenderBytes"), "(w, ", httpPackage.Ident("StatusOK"), ", ", contentType, ", resp)")
+	case info.Result1.String:
+		f.P(gorsPackage.Ident("RenderString"), "(w, ", httpPackage.Ident("StatusOK"), ", ", contentType, ", resp)")
+	case info.Result1.Reader:
+		f.P(gorsPackage.Ident("RenderReader"), "(w, ", httpPackage.Ident("StatusOK"), ", ", contentType, ", resp)")
+	default:
+		f.P("if err := ", renderFunc(route), "(w, r, ", httpPackage.Ident("StatusOK"), ", resp); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("}")
+	}
+}
+
+// renderFunc returns the gors renderer of the render annotation of the route, JSON by default.
+func renderFunc(route *internal.Route) *internal.GoIdent {
+	switch route.Render {
+	case internal.IndentedJSONRender:
+		return gorsPackage.Ident("RenderIndentedJSON")
+	case internal.SecureJSONRender:
+		return gorsPackage.Ident("RenderSecureJSON")
+	case internal.JsonpJSONRender:
+		return gorsPackage.Ident("RenderJsonpJSON")
+	case internal.PureJSONRender:
+		return gorsPackage.Ident("RenderPureJSON")
+	case internal.AsciiJSONRender:
+		return gorsPackage.Ident("RenderAsciiJSON")
+	case internal.XMLRender:
+		return gorsPackage.Ident("RenderXML")
+	case internal.YAMLRender:
+		return gorsPackage.Ident("RenderYAML")
+	case internal.TOMLRender:
+		return gorsPackage.Ident("RenderTOML")
+	case internal.MsgPackRender:
+		return gorsPackage.Ident("RenderMsgPack")
+	case internal.ProtoBufRender:
+		return gorsPackage.Ident("RenderProtoBuf")
+	}
+	return gorsPackage.Ident("RenderJSON")
+}
+
+// rawContentType returns the content type of a []byte, string or io.Reader result, the one given to the
+// render annotation, such as @BytesRender(image/png), or else the one of the render.
+func rawContentType(route *internal.Route, result *internal.Result) string {
+	if route.RenderContentType != "" {
+		return route.RenderContentType
+	}
+	switch route.Render {
+	case internal.TextRender, internal.StringRender:
+		return "text/plain; charset=utf-8"
+	case internal.HTMLRender:
+		return "text/html; charset=utf-8"
+	case internal.BytesRender, internal.ReaderRender:
+		return "application/octet-stream"
+	case internal.JSONRender, internal.IndentedJSONRender, internal.SecureJSONRender, internal.PureJSONRender, internal.AsciiJSONRender:
+		return "application/json; charset=utf-8"
+	case internal.JsonpJSONRender:
+		return "application/javascript; charset=utf-8"
+	case internal.XMLRender:
+		return "application/xml; charset=utf-8"
+	case internal.YAMLRender:
+		return "application/x-yaml; charset=utf-8"
+	case internal.TOMLRender:
+		return "application/toml; charset=utf-8"
+	case internal.MsgPackRender:
+		return "application/x-msgpack"
+	case internal.ProtoBufRender:
+		return "application/x-protobuf"
+	}
+	if result.String {
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// bindingFuncs returns the gors bindings of the binding annotations of the route, in declared order.
+func bindingFuncs(route *internal.Route) []*internal.GoIdent {
+	var idents []*internal.GoIdent
+	for _, binding := range route.Bindings {
+		switch binding {
+		case internal.UriBinding:
+			idents = append(idents, gorsPackage.Ident("BindUri"))
+		case internal.QueryBinding:
+			idents = append(idents, gorsPackage.Ident("BindQuery"))
+		case internal.HeaderBinding:
+			idents = append(idents, gorsPackage.Ident("BindHeader"))
+		case internal.JSONBinding:
+			idents = append(idents, gorsPackage.Ident("BindJSON"))
+		case internal.XMLBinding:
+			idents = append(idents, gorsPackage.Ident("BindXML"))
+		case internal.FormBinding:
+			idents = append(idents, gorsPackage.Ident("BindForm"))
+		case internal.FormPostBinding:
+			idents = append(idents, gorsPackage.Ident("BindFormPost"))
+		case internal.FormMultipartBinding:
+			idents = append(idents, gorsPackage.Ident("BindFormMultipart"))
+		case internal.ProtoBufBinding:
+			idents = append(idents, gorsPackage.Ident("BindProtoBuf"))
+		case internal.MsgPackBinding:
+			idents = append(idents, gorsPackage.Ident("BindMsgPack"))
+		case internal.YAMLBinding:
+			idents = append(idents, gorsPackage.Ident("BindYAML"))
+		case internal.TOMLBinding:
+			idents = append(idents, gorsPackage.Ident("BindTOML"))
+		}
+	}
+	return idents
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/go-miya/gorsx/internal"
+	"strings"
+	"testing"
+)
+
+const handlerImplSrc = `package impl
+
+import (
+	"context"
+	"example.com/users"
+	"example.com/users/assembler"
+	"example.com/users/bus"
+)
+
+type Users struct {
+	commands *bus.Commands
+}
+
+func (ctrl *Users) Update(ctx context.Context, req *users.UpdateReq) (err error) {
+	return ctrl.commands.Update.Handle(ctx, assembler.UpdateTo(req))
+}
+`
+
+// TestPrintHandlerAuth adds @Auth to the already generated @CQRS method Update, whose impl method is
+// kept as it is, so the handler authorizes it, and removes it again.
+func TestPrintHandlerAuth(t *testing.T) {
+	comment := "// @GORS @PUT @Path(/users/:id) @UriBinding @JSONBinding @Auth(roles=admin) @Permission(users:write)"
+	info := validateFunc(t, comment)
+	info.CQRS = &internal.CQRSFile{Type: "command", Service: "Users", Endpoint: "Update"}
+	info.Auth = internal.NewAuthAnnotation("Users", nil).Override("Update", []string{comment})
+	g := &Generate{SrvName: "Users", Funcs: []*internal.FuncInfo{info}}
+
+	impl := editFile(t, handlerImplSrc, g.appendFuncs)
+	if impl != handlerImplSrc {
+		t.Errorf("appendFuncs() =\n%s\nwant the impl unchanged\n%s", impl, handlerImplSrc)
+	}
+
+	const authorize = `if err := gors.Authorize(r.Context(), o.Authorizer, &gors.AuthRequirement{Endpoint: "Users.Update", Roles: []string{"admin"}, Permissions: []string{"users:write"}}); err != nil {`
+	if got := printHandler(t, g, info); !strings.Contains(got, authorize) {
+		t.Errorf("printHandler() =\n%s\nwant it to contain\n%s", got, authorize)
+	}
+
+	info.Auth = nil
+	if got := printHandler(t, g, info); strings.Contains(got, "Authorize") {
+		t.Errorf("printHandler() =\n%s\nwant no authorization once @Auth is removed", got)
+	}
+}
+
+// printHandler returns the handler of info printed by g.
+func printHandler(t *testing.T, g *Generate, info *internal.FuncInfo) string {
+	t.Helper()
+	f := newGoFile("users", "example.com/users")
+	g.printHandler(f, info)
+	got, err := f.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
+// TestPrintHandlerStreamsBody checks the bodies of the io.Reader params and of the multipart forms are
+// not limited, unlike the other ones.
+func TestPrintHandlerStreamsBody(t *testing.T) {
+	g := &Generate{SrvName: "Users"}
+	tests := []struct {
+		name    string
+		comment string
+		reader  bool
+		limited bool
+	}{
+		{name: "json", comment: "// @GORS @PUT @Path(/users/:id) @UriBinding @JSONBinding", limited: true},
+		{name: "multipart", comment: "// @GORS @PUT @Path(/users/:id) @UriBinding @FormMultipartBinding"},
+		{name: "reader", comment: "// @GORS @PUT @Path(/users/:id) @ReaderRender", reader: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := validateFunc(t, tt.comment)
+			info.Param2.Reader = tt.reader
+			if got := printHandler(t, g, info); strings.Contains(got, "o.LimitBody(w, r)") != tt.limited {
+				t.Errorf("printHandler() =\n%s\nwant the body limited %t", got, tt.limited)
+			}
+		})
+	}
+}
@@ -59,6 +59,7 @@ func getFileInfo(file *protogen.File, service *protogen.Service) {
 	outDir := filepath.Dir(filepath.Join(cwd, file.Desc.Path()))
 	queryAbs := filepath.Join(outDir, path.Query)
 	commandAbs := filepath.Join(filepath.Dir(filepath.Join(cwd, file.Desc.Path())), path.Command)
+	goImportPath := buildGoImportPath(path.GoBasePath, strings.Trim(string(file.GoImportPath), "\""))
 	var cqrsFiles []*internal.CQRSFile
 	g := &main2.Generate{
 		Buf:              &bytes.Buffer{},
@@ -91,13 +92,13 @@ func getFileInfo(file *protogen.File, service *protogen.Service) {
 				cqrsFile.IsQuery(),
 				methodName,
 				funcInfo.Param2,
-				&internal.Result{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetReqName(), GoImportPath: internal.GoImportPath(cqrsFile.Package)}},
-				&internal.Param{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetRespName(), GoImportPath: internal.GoImportPath(cqrsFile.Package)}},
+				&internal.Result{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetReqName(), GoImportPath: cqrsFile.GoImportPath(goImportPath)}},
+				&internal.Param{ObjectArgs: &internal.ObjectArgs{Name: cqrsFile.GetRespName(), GoImportPath: cqrsFile.GoImportPath(goImportPath)}},
 				funcInfo.Result1,
 			)
 		}
 	}
-	g.GenerateProto(outDir, buildGoImportPath(path.GoBasePath, strings.Trim(string(file.GoImportPath), "\"")), path.ServiceImplPath, path)
+	g.GenerateProto(outDir, goImportPath, path.ServiceImplPath, path)
 	for _, f := range cqrsFiles {
 		if err := f.Gen(); err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "%s.%s error: %s \n", service.Desc.FullName(), f.Endpoint, err)
@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"github.com/go-miya/gorsx/internal"
+	"go/ast"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// orphanMarker marks generated code whose method has been removed from the service interface but
+// which has been customized, so it is kept for the user to remove.
+const orphanMarker = "// Deprecated: gorsx orphan"
+
+// pruneImpl deletes the untouched methods of the service implementation that are no longer declared
+// by the service interface, and marks the customized ones. Methods that do not have the shape of an
+// endpoint, such as Close, are left alone.
+func (g *Generate) pruneImpl(editor *internal.GoEditor, typeName string) {
+	for _, decl := range editor.File.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil || internal.RecvTypeName(funcDecl) != typeName {
+			continue
+		}
+		if !ast.IsExported(funcDecl.Name.Name) || g.hasFunc(funcDecl.Name.Name) {
+			continue
+		}
+		untouched := isGeneratedImplBody(editor, funcDecl, g.assemblerImportPath, g.SrvName)
+		if !untouched && !isEndpointSignature(funcDecl.Type) {
+			// a helper of the implementation, such as Close, not generated by gorsx
+			continue
+		}
+		g.pruneDecl(editor, funcDecl, funcDecl.Doc, funcDecl.Name.Name, untouched, "impl method")
+	}
+}
+
+// pruneAssembler deletes the untouched assembler functions of methods that are no longer declared by
+// the service interface, and marks the customized ones.
+func (g *Generate) pruneAssembler(editor *internal.GoEditor) {
+	for _, decl := range editor.File.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil || funcDecl.Recv != nil {
+			continue
+		}
+		name := funcDecl.Name.Name
+		endpoint := strings.TrimSuffix(strings.TrimSuffix(name, "To"), "From")
+		if endpoint == name || endpoint == "" || g.hasAssembler(endpoint) {
+			continue
+		}
+		untouched := isStubBody(editor, funcDecl.Body, `panic("to implemented")`)
+		if !untouched && !refersToEndpoint(funcDecl.Type, endpoint) {
+			// not generated by gorsx
+			continue
+		}
+		g.pruneDecl(editor, funcDecl, funcDecl.Doc, endpoint, untouched, "assembler func")
+	}
+}
+
+// pruneBus deletes the generated bus fields of handlers that are no longer declared by the service
+// interface, the fields named after their endpoint and typed <handlerpkg>.<Endpoint>, where handlerpkg
+// refers to handlerPath. The other fields are declared by the user and kept.
+func (g *Generate) pruneBus(editor *internal.GoEditor, busStruct *ast.StructType, handlerPath internal.GoImportPath, cqrsList []*internal.CQRSFile) {
+	handlerPkg := editor.ImportName(string(handlerPath))
+	if handlerPkg == "" {
+		return
+	}
+	for _, field := range busStruct.Fields.List {
+		if len(field.Names) != 1 {
+			continue
+		}
+		name := field.Names[0].Name
+		sel, ok := field.Type.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != name {
+			continue
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != handlerPkg {
+			continue
+		}
+		if _, ok := lookupCQRS(cqrsList, name); ok {
+			continue
+		}
+		g.pruneDecl(editor, field, field.Doc, name, true, "bus field")
+	}
+}
+
+// pruneCQRS deletes the untouched query and command handler files in dirs whose endpoint is no longer
+// declared by the service interface, and marks the customized handlers. A file is untouched when it
+// matches the checksum it is generated with, whatever the annotations of its endpoint were.
+func (g *Generate) pruneCQRS(dirs ...string) {
+	seen := make(map[string]struct{})
+	for _, dir := range dirs {
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			log.Fatalf("pruneCQRS.Glob failed, %v", err)
+		}
+		for _, filename := range filenames {
+			g.pruneCQRSFile(filename)
+		}
+	}
+}
+
+func (g *Generate) pruneCQRSFile(filename string) {
+	editor, err := g.newGoEditor(filename)
+	if err != nil {
+		log.Printf("warning: prune: skip %s, %v", filename, err)
+		return
+	}
+	for _, decl := range editor.File.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE || len(genDecl.Specs) != 1 {
+			continue
+		}
+		typeSpec := genDecl.Specs[0].(*ast.TypeSpec)
+		kind := handlerKind(typeSpec.Type)
+		if kind == "" {
+			continue
+		}
+		endpoint := typeSpec.Name.Name
+		if _, ok := lookupCQRS(g.CQRSList, endpoint); ok {
+			continue
+		}
+		if internal.Untouched(editor.Bytes()) && !g.keptOrphans[endpoint] {
+			if err := os.Remove(filename); err != nil {
+				log.Fatalf("pruneCQRS.Remove failed, %v", err)
+			}
+			log.Printf("%s.%s prune: deleted %s %s", g.pkgImportPath, g.SrvName, kind, filename)
+			return
+		}
+		g.pruneDecl(editor, genDecl, genDecl.Doc, endpoint, false, kind)
+		if editor.Changed() {
+			if err := writeContent(filename, editor.Bytes()); err != nil {
+				log.Fatalf("writing output: %s", err)
+			}
+		}
+		return
+	}
+}
+
+// pruneDecl deletes the orphan declaration of endpoint when it is untouched and no customized code
+// of the endpoint, which may depend on it, is kept. Otherwise, it marks the declaration.
+func (g *Generate) pruneDecl(editor *internal.GoEditor, decl ast.Node, doc *ast.CommentGroup, endpoint string, untouched bool, kind string) {
+	name := declName(decl)
+	if untouched && !g.keptOrphans[endpoint] {
+		editor.DeleteNode(decl, doc)
+		log.Printf("%s.%s prune: deleted %s %s", g.pkgImportPath, g.SrvName, kind, name)
+		return
+	}
+	if g.keptOrphans == nil {
+		g.keptOrphans = make(map[string]bool)
+	}
+	g.keptOrphans[endpoint] = true
+	if doc != nil && strings.Contains(doc.Text(), strings.TrimPrefix(orphanMarker, "// ")) {
+		return
+	}
+	marker := orphanMarker + "\n"
+	if doc != nil {
+		marker = "//\n" + marker
+	}
+	if _, ok := decl.(*ast.Field); ok {
+		marker = strings.ReplaceAll(marker, "\n", "\n\t")
+	}
+	editor.Insert(decl.Pos(), marker)
+	log.Printf("%s.%s prune: marked %s %s as orphan", g.pkgImportPath, g.SrvName, kind, name)
+}
+
+func (g *Generate) hasFunc(name string) bool {
+	for _, info := range g.Funcs {
+		if info.FuncName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Generate) hasAssembler(endpoint string) bool {
+	for _, info := range g.Funcs {
+		if info.Assembler != nil && info.Assembler.FuncName == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupCQRS(cqrsList []*internal.CQRSFile, endpoint string) (*internal.CQRSFile, bool) {
+	for _, file := range cqrsList {
+		if file.Endpoint == endpoint {
+			return file, true
+		}
+	}
+	return nil, false
+}
+
+func declName(decl ast.Node) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Name.Name
+	case *ast.Field:
+		return d.Names[0].Name
+	case *ast.GenDecl:
+		if typeSpec, ok := d.Specs[0].(*ast.TypeSpec); ok {
+			return typeSpec.Name.Name
+		}
+	}
+	return ""
+}
+
+// handlerKind returns "query" or "command" when expr is a cqrs.QueryHandler or cqrs.CommandHandler
+// instantiation.
+func handlerKind(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.IndexExpr:
+		expr = x.X
+	case *ast.IndexListExpr:
+		expr = x.X
+	default:
+		return ""
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	switch sel.Sel.Name {
+	case "QueryHandler":
+		return "query"
+	case "CommandHandler":
+		return "command"
+	}
+	return ""
+}
+
+// refersToEndpoint reports whether the function signature refers to the query, result or command
+// type of endpoint.
+func refersToEndpoint(funcType *ast.FuncType, endpoint string) bool {
+	found := false
+	ast.Inspect(funcType, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok {
+			switch ident.Name {
+			case endpoint + "Query", endpoint + "Result", endpoint + "Cmd":
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// isEndpointSignature reports whether funcType has the shape of the implementation methods gorsx
+// generates, (ctx context.Context, req T) (res R, err error) or (ctx context.Context, req T) (err error),
+// whose params and results are named.
+func isEndpointSignature(funcType *ast.FuncType) bool {
+	params, results := funcType.Params, funcType.Results
+	if params == nil || len(params.List) != 2 || results == nil || len(results.List) == 0 || len(results.List) > 2 {
+		return false
+	}
+	for _, field := range append(append([]*ast.Field(nil), params.List...), results.List...) {
+		if len(field.Names) != 1 {
+			return false
+		}
+	}
+	ctx, ok := params.List[0].Type.(*ast.SelectorExpr)
+	if !ok || ctx.Sel.Name != "Context" {
+		return false
+	}
+	last, ok := results.List[len(results.List)-1].Type.(*ast.Ident)
+	return ok && last.Name == "error"
+}
+
+// isGeneratedImplBody reports whether the body of an implementation method is one gorsx generates.
+func isGeneratedImplBody(editor *internal.GoEditor, funcDecl *ast.FuncDecl, assemblerImportPath internal.GoImportPath, srvName string) bool {
+	info := internal.NewRPCMethodInfo(funcDecl.Name.Name)
+	var names []string
+	for _, list := range []*ast.FieldList{funcDecl.Type.Params, funcDecl.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+	hasResult := len(names) == 4
+	switch len(names) {
+	case 3:
+		info.CtxName, info.ReqName, info.ErrName = names[0], names[1], names[2]
+	case 4:
+		info.CtxName, info.ReqName, info.ResName, info.ErrName = names[0], names[1], names[2], names[3]
+	default:
+		return false
+	}
+	recv := ""
+	if names := funcDecl.Recv.List[0].Names; len(names) > 0 {
+		recv = names[0].Name
+	}
+	assemblerPkg, cqrsxPkg := assemblerImportPath.Ident("").GoImport.PackageName, cqrsxPackage.Ident("").GoImport.PackageName
+	bodies := []string{info.GenBody(recv, assemblerPkg, cqrsxPkg)}
+	for _, kind := range []string{"query", "command", "async"} {
+		info.CQRS = &internal.CQRSFile{Type: "command", Service: srvName, Endpoint: info.FuncName, Async: kind == "async"}
+		if kind == "query" {
+			info.CQRS.Type = kind
+		}
+		info.Assembler = internal.NewAssemblerCore(kind == "query", info.FuncName, nil, nil, nil, nil)
+		info.Result1 = nil
+		if hasResult {
+			info.Result1 = &internal.Result{}
+		}
+		bodies = append(bodies, info.GenBody(recv, assemblerPkg, cqrsxPkg))
+	}
+	for _, body := range bodies {
+		if isStubBody(editor, funcDecl.Body, body) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStubBody reports whether body consists of the given statements, ignoring white space.
+func isStubBody(editor *internal.GoEditor, body *ast.BlockStmt, stmts string) bool {
+	text := editor.Text(body.Lbrace+1, body.Rbrace)
+	return stripSpace(text) == stripSpace(stmts)
+}
+
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
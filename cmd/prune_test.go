@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/go-miya/gorsx/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newPruneGenerate returns a Generate of the service Users, which only declares the query Get.
+func newPruneGenerate() *Generate {
+	get := &internal.FuncInfo{
+		FuncName:  "Get",
+		CQRS:      &internal.CQRSFile{Type: "query", Service: "Users", Endpoint: "Get"},
+		Assembler: internal.NewAssemblerCore(true, "Get", nil, nil, nil, nil),
+	}
+	return &Generate{
+		SrvName:             "Users",
+		pkgImportPath:       "example.com/users",
+		assemblerImportPath: "example.com/users/assembler",
+		Funcs:               []*internal.FuncInfo{get},
+		CQRSList:            CQRSList{get.CQRS},
+		Prune:               true,
+	}
+}
+
+// editFile writes src to a temporary file, edits it with edit and returns the edited source.
+func editFile(t *testing.T, src string, edit func(editor *internal.GoEditor)) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "users.go")
+	if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	editor, err := internal.NewGoEditor(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(editor)
+	return string(editor.Bytes())
+}
+
+const pruneImplSrc = `package impl
+
+import (
+	"context"
+	"example.com/users"
+	"example.com/users/assembler"
+	"example.com/users/bus"
+	"log"
+)
+
+type Users struct {
+	queries  *bus.Queries
+	commands *bus.Commands
+}
+
+func (ctrl *Users) Get(ctx context.Context, req *users.GetReq) (res *users.User, err error) {
+	resp, err := ctrl.queries.Get.Handle(ctx, assembler.GetTo(req))
+	if err != nil {
+		return
+	}
+	return assembler.GetFrom(resp), nil
+}
+
+func (ctrl *Users) Delete(ctx context.Context, req *users.DeleteReq) (err error) {
+	return ctrl.commands.Delete.Handle(ctx, assembler.DeleteTo(req))
+}
+
+// Rename renames a user.
+func (ctrl *Users) Rename(ctx context.Context, req *users.RenameReq) (err error) {
+	log.Println("rename", req)
+	return ctrl.commands.Rename.Handle(ctx, assembler.RenameTo(req))
+}
+
+// Close releases the resources of the service.
+func (ctrl *Users) Close() error {
+	return nil
+}
+
+// Health reports whether the service is healthy.
+func (ctrl *Users) Health(ctx context.Context) error {
+	return nil
+}
+
+func (ctrl *Users) Stats(ctx context.Context, name string) (int, error) {
+	return 0, nil
+}
+`
+
+const pruneImplWant = `package impl
+
+import (
+	"context"
+	"example.com/users"
+	"example.com/users/assembler"
+	"example.com/users/bus"
+	"log"
+)
+
+type Users struct {
+	queries  *bus.Queries
+	commands *bus.Commands
+}
+
+func (ctrl *Users) Get(ctx context.Context, req *users.GetReq) (res *users.User, err error) {
+	resp, err := ctrl.queries.Get.Handle(ctx, assembler.GetTo(req))
+	if err != nil {
+		return
+	}
+	return assembler.GetFrom(resp), nil
+}
+
+// Rename renames a user.
+//
+// Deprecated: gorsx orphan
+func (ctrl *Users) Rename(ctx context.Context, req *users.RenameReq) (err error) {
+	log.Println("rename", req)
+	return ctrl.commands.Rename.Handle(ctx, assembler.RenameTo(req))
+}
+
+// Close releases the resources of the service.
+func (ctrl *Users) Close() error {
+	return nil
+}
+
+// Health reports whether the service is healthy.
+func (ctrl *Users) Health(ctx context.Context) error {
+	return nil
+}
+
+func (ctrl *Users) Stats(ctx context.Context, name string) (int, error) {
+	return 0, nil
+}
+`
+
+func TestPruneImpl(t *testing.T) {
+	g := newPruneGenerate()
+	got := editFile(t, pruneImplSrc, func(editor *internal.GoEditor) { g.pruneImpl(editor, "Users") })
+	if got != pruneImplWant {
+		t.Errorf("pruneImpl() =\n%s\nwant\n%s", got, pruneImplWant)
+	}
+	if !g.keptOrphans["Rename"] || g.keptOrphans["Delete"] || g.keptOrphans["Close"] {
+		t.Errorf("keptOrphans = %v, want only Rename", g.keptOrphans)
+	}
+
+	// a second run leaves the marked method as it is
+	again := editFile(t, got, func(editor *internal.GoEditor) { newPruneGenerate().pruneImpl(editor, "Users") })
+	if again != got {
+		t.Errorf("second pruneImpl() =\n%s\nwant\n%s", again, got)
+	}
+}
+
+const pruneAssemblerSrc = `package assembler
+
+import (
+	"example.com/users"
+	"example.com/users/app"
+	"time"
+)
+
+func GetTo(in *users.GetReq) *app.GetQuery {
+	panic("to implemented")
+}
+
+func GetFrom(in *app.GetResult) *users.User {
+	panic("to implemented")
+}
+
+func DeleteTo(in *users.DeleteReq) *app.DeleteCmd {
+	panic("to implemented")
+}
+
+func RenameTo(in *users.RenameReq) *app.RenameCmd {
+	return &app.RenameCmd{Name: in.Name}
+}
+
+func FormatTo(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+`
+
+const pruneAssemblerWant = `package assembler
+
+import (
+	"example.com/users"
+	"example.com/users/app"
+	"time"
+)
+
+func GetTo(in *users.GetReq) *app.GetQuery {
+	panic("to implemented")
+}
+
+func GetFrom(in *app.GetResult) *users.User {
+	panic("to implemented")
+}
+
+// Deprecated: gorsx orphan
+func RenameTo(in *users.RenameReq) *app.RenameCmd {
+	return &app.RenameCmd{Name: in.Name}
+}
+
+func FormatTo(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+`
+
+func TestPruneAssembler(t *testing.T) {
+	g := newPruneGenerate()
+	got := editFile(t, pruneAssemblerSrc, g.pruneAssembler)
+	if got != pruneAssemblerWant {
+		t.Errorf("pruneAssembler() =\n%s\nwant\n%s", got, pruneAssemblerWant)
+	}
+}
+
+const pruneBusSrc = `package bus
+
+import (
+	"example.com/users/app"
+	"example.com/users/other"
+	"github.com/go-miya/gorsx/cqrsx"
+	"log/slog"
+)
+
+type Commands struct {
+	Get    app.Get
+	Delete app.Delete
+	Rename app.Rename
+	Purge  other.Purge
+	Audit  app.Auditor
+	Logger *slog.Logger
+	// Queue enqueues the @Async commands, which the worker of NewWorker handles.
+	Queue cqrsx.CommandQueue
+}
+`
+
+const pruneBusWant = `package bus
+
+import (
+	"example.com/users/app"
+	"example.com/users/other"
+	"github.com/go-miya/gorsx/cqrsx"
+	"log/slog"
+)
+
+type Commands struct {
+	Get    app.Get
+	// Deprecated: gorsx orphan
+	Rename app.Rename
+	Purge  other.Purge
+	Audit  app.Auditor
+	Logger *slog.Logger
+	// Queue enqueues the @Async commands, which the worker of NewWorker handles.
+	Queue cqrsx.CommandQueue
+}
+`
+
+func TestPruneBus(t *testing.T) {
+	g := newPruneGenerate()
+	// the customized impl method of Rename depends on its bus field, which is kept
+	g.keptOrphans = map[string]bool{"Rename": true}
+	got := editFile(t, pruneBusSrc, func(editor *internal.GoEditor) {
+		g.pruneBus(editor, editor.FindStruct("Commands"), "example.com/users/app", g.CQRSList)
+	})
+	if got != pruneBusWant {
+		t.Errorf("pruneBus() =\n%s\nwant\n%s", got, pruneBusWant)
+	}
+}
+
+func TestPruneBusWithoutCommands(t *testing.T) {
+	dir := t.TempDir()
+	busFile := filepath.Join(dir, "bus", "command.go")
+	if err := os.MkdirAll(filepath.Dir(busFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(busFile, []byte(pruneBusSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the service declares no command anymore, and Bus is not set
+	g := newPruneGenerate()
+	g.Buf, g.HeaderBuf, g.ImportsBuf, g.FunctionBuf = &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	g.generateBus(dir, "example.com/users", &internal.Path{Command: "./app", BusCommand: "./bus/command.go"}, false)
+	got, err := os.ReadFile(busFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), "Rename app.Rename") || strings.Contains(string(got), "Get    app.Get") {
+		t.Errorf("generateBus() did not prune the generated fields:\n%s", got)
+	}
+	for _, field := range []string{"Purge  other.Purge", "Audit  app.Auditor", "Logger *slog.Logger", "Queue cqrsx.CommandQueue"} {
+		if !strings.Contains(string(got), field) {
+			t.Errorf("generateBus() pruned the field %q:\n%s", field, got)
+		}
+	}
+}
+
+const pruneRenameSrc = `package app
+
+import (
+	"context"
+	"github.com/go-leo/design-pattern/cqrs"
+)
+
+type RenameCmd struct {
+	Name string
+}
+
+type Rename cqrs.CommandHandler[*RenameCmd]
+`
+
+const pruneRenameWant = `package app
+
+import (
+	"context"
+	"github.com/go-leo/design-pattern/cqrs"
+)
+
+type RenameCmd struct {
+	Name string
+}
+
+// Deprecated: gorsx orphan
+type Rename cqrs.CommandHandler[*RenameCmd]
+`
+
+const pruneHelperSrc = `package app
+
+type Clock interface {
+	Now() int64
+}
+`
+
+func TestPruneCQRS(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	content := func(kind, endpoint string, annotate ...func(file *internal.CQRSFile)) []byte {
+		file := &internal.CQRSFile{Type: kind, Package: "app", Endpoint: endpoint, LowerEndpoint: strings.ToLower(endpoint[:1]) + endpoint[1:]}
+		for _, f := range annotate {
+			f(file)
+		}
+		src, err := file.Content()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return src
+	}
+	annotated := func(file *internal.CQRSFile) {
+		file.Transactional = true
+		file.Emits = []string{"OrderPlaced"}
+		file.KeyFields = []*internal.KeyField{{Name: "ID", Type: "string"}, {Name: "Day", Type: "time.Time"}}
+		file.Imports = []string{"time"}
+	}
+	get := write("get.go", content("query", "Get"))
+	deleted := write("delete.go", content("command", "Delete"))
+	placed := write("place.go", content("command", "Place", annotated))
+	customized := bytes.Replace(content("command", "Cancel", annotated), []byte(`panic("implement me")`), []byte("return nil"), 1)
+	cancel := write("cancel.go", customized)
+	rename := write("rename.go", []byte(pruneRenameSrc))
+	helper := write("clock.go", []byte(pruneHelperSrc))
+
+	g := newPruneGenerate()
+	g.pruneCQRS(dir, dir)
+
+	for _, filename := range []string{deleted, placed} {
+		if _, err := os.Stat(filename); !os.IsNotExist(err) {
+			t.Errorf("untouched handler %s is not deleted, %v", filename, err)
+		}
+	}
+	if got, err := os.ReadFile(cancel); err != nil || !bytes.Contains(got, []byte(orphanMarker+"\ntype Cancel ")) {
+		t.Errorf("customized handler cancel.go =\n%s\nwant it kept and marked, %v", got, err)
+	}
+	for filename, want := range map[string]string{
+		get:    string(content("query", "Get")),
+		rename: pruneRenameWant,
+		helper: pruneHelperSrc,
+	} {
+		got, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s =\n%s\nwant\n%s", filepath.Base(filename), got, want)
+		}
+	}
+}
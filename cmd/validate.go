@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/go-miya/gorsx/internal"
+	"go/types"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// validates reports whether the handler of the method validates the bound request, as opted in by a
+// @Validate annotation of the method or the service.
+func (g *Generate) validates(info *internal.FuncInfo) bool {
+	if info.Param2.Bytes || info.Param2.String || info.Param2.Reader {
+		return false
+	}
+	return info.Route.Validate || (g.RouteGroup != nil && g.RouteGroup.Validate)
+}
+
+// printHandlerValidate prints the statements validating req, by its Validate method if it implements
+// gors.Validator, or else by the function checking its validate struct tags.
+func (g *Generate) printHandlerValidate(f *goFile, info *internal.FuncInfo) {
+	reqType := info.Signature.Params().At(1).Type()
+	switch {
+	case implementsValidator(reqType):
+		f.P("if err := req.Validate(); err != nil {")
+		f.P("o.ErrorHandler(w, r, ", gorsPackage.Ident("InvalidArgument"), "(err))")
+		f.P("return")
+		f.P("}")
+	case len(validatedFields(reqType)) > 0:
+		target := "req"
+		if _, ok := reqType.(*types.Pointer); !ok {
+			target = "&req"
+		}
+		f.P("if err := ", validateName(g.SrvName, info), "(", target, "); err != nil {")
+		f.P("o.ErrorHandler(w, r, err)")
+		f.P("return")
+		f.P("}")
+	}
+}
+
+func validateName(srvName string, info *internal.FuncInfo) string {
+	return fmt.Sprintf("_%s_%s_Validate", srvName, info.FuncName)
+}
+
+// implementsValidator reports whether t, or a pointer to t, has a Validate() error method.
+func implementsValidator(t types.Type) bool {
+	if _, ok := t.(*types.Pointer); !ok {
+		t = types.NewPointer(t)
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "Validate")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Results().At(0).Type(), types.Universe.Lookup("error").Type())
+}
+
+// validatedFields returns the fields of the request with a validate struct tag.
+func validatedFields(reqType types.Type) []internal.StructField {
+	var fields []internal.StructField
+	for _, field := range internal.StructFields(reqType) {
+		if rules, ok := field.Lookup("validate"); ok && rules != "" && rules != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// printValidate prints the function checking the validate struct tags of the request, such as
+// `validate:"required,min=1,max=64"`. It reports the first failing rule of every field as a
+// gors.FieldViolation, named after the binding the field is bound by.
+func (g *Generate) printValidate(f *goFile, info *internal.FuncInfo) {
+	reqType := info.Signature.Params().At(1).Type()
+	if implementsValidator(reqType) {
+		return
+	}
+	fields := validatedFields(reqType)
+	if len(fields) == 0 {
+		return
+	}
+	if _, ok := reqType.(*types.Pointer); !ok {
+		reqType = types.NewPointer(reqType)
+	}
+	f.P()
+	f.P("func ", validateName(g.SrvName, info), "(req ", reqType, ") error {")
+	f.P("var violations ", gorsPackage.Ident("FieldViolations"))
+	for _, field := range fields {
+		g.printValidateField(f, info, field)
+	}
+	f.P("return violations.Err()")
+	f.P("}")
+}
+
+// validateCheck is a failing condition of a validate rule and the description of the violation.
+type validateCheck struct {
+	cond        []any
+	description string
+}
+
+func (g *Generate) printValidateField(f *goFile, info *internal.FuncInfo, field internal.StructField) {
+	tag, _ := field.Lookup("validate")
+	x := "req." + field.GoName
+	t := field.Type
+	_, isPointer := t.Underlying().(*types.Pointer)
+	if isPointer {
+		t = t.Underlying().(*types.Pointer).Elem()
+	}
+	value := x
+	if isPointer {
+		value = "*" + x
+	}
+
+	var required, omitempty bool
+	var checks []validateCheck
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "required":
+			required = true
+		case "omitempty":
+			omitempty = true
+		case "min", "max", "len":
+			checks = append(checks, g.sizeCheck(info, field, t, value, name, arg))
+		case "email":
+			if basic, ok := t.Underlying().(*types.Basic); !ok || basic.Info()&types.IsString == 0 {
+				log.Fatalf("error: func %s field %s: email requires a string field", info.FuncName, field.GoName)
+			}
+			checks = append(checks, validateCheck{
+				cond:        []any{"!", gorsPackage.Ident("IsEmail"), "(", stringValue(t, value), ")"},
+				description: "must be a valid email address",
+			})
+		case "oneof":
+			checks = append(checks, g.oneofCheck(info, field, t, value, arg))
+		default:
+			log.Fatalf("error: func %s field %s: unknown validate rule %q", info.FuncName, field.GoName, name)
+		}
+	}
+
+	var chain []validateCheck
+	var guard []any
+	switch {
+	case required:
+		chain = append(chain, validateCheck{cond: zeroCond(field.Type, x), description: "is required"})
+	case isPointer:
+		guard = []any{x, " != nil"}
+	case omitempty:
+		guard = nonZeroCond(field.Type, x)
+	}
+	chain = append(chain, checks...)
+	if len(chain) == 0 {
+		return
+	}
+	if guard != nil {
+		f.P(append(append([]any{"if "}, guard...), " {")...)
+	}
+	name, source := g.violationField(info, field)
+	for i, check := range chain {
+		keyword := "if "
+		if i > 0 {
+			keyword = "} else if "
+		}
+		f.P(append(append([]any{keyword}, check.cond...), " {")...)
+		f.P("violations = append(violations, ", gorsPackage.Ident("FieldViolation"), "{Field: ", strconv.Quote(name), ", Source: ", strconv.Quote(source), ", Description: ", strconv.Quote(check.description), "})")
+	}
+	f.P("}")
+	if guard != nil {
+		f.P("}")
+	}
+}
+
+// sizeCheck returns the check of a min, max or len rule: the length of a string, slice, array or map,
+// or the value of a number.
+func (g *Generate) sizeCheck(info *internal.FuncInfo, field internal.StructField, t types.Type, value, rule, arg string) validateCheck {
+	op, bound := " < ", "at least"
+	switch rule {
+	case "max":
+		op, bound = " > ", "at most"
+	case "len":
+		op, bound = " != ", "exactly"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			mustParseInt(info, field, rule, arg)
+			return validateCheck{
+				cond:        []any{internal.GoImportPath("unicode/utf8").Ident("RuneCountInString"), "(", stringValue(t, value), ")", op, arg},
+				description: fmt.Sprintf("must be %s %s characters long", bound, arg),
+			}
+		case u.Info()&types.IsInteger != 0 && rule != "len":
+			mustParseInt(info, field, rule, arg)
+			return validateCheck{cond: []any{value, op, arg}, description: fmt.Sprintf("must be %s %s", bound, arg)}
+		case u.Info()&types.IsFloat != 0 && rule != "len":
+			if _, err := strconv.ParseFloat(arg, 64); err != nil {
+				log.Fatalf("error: func %s field %s: %s=%s is not a number", info.FuncName, field.GoName, rule, arg)
+			}
+			return validateCheck{cond: []any{value, op, arg}, description: fmt.Sprintf("must be %s %s", bound, arg)}
+		}
+	case *types.Slice, *types.Array, *types.Map:
+		mustParseInt(info, field, rule, arg)
+		return validateCheck{
+			cond:        []any{"len(", value, ")", op, arg},
+			description: fmt.Sprintf("must have %s %s items", bound, arg),
+		}
+	}
+	log.Fatalf("error: func %s field %s: %s does not apply to %s", info.FuncName, field.GoName, rule, t)
+	return validateCheck{}
+}
+
+// oneofCheck returns the check of a oneof rule, whose space separated values are strings or numbers.
+func (g *Generate) oneofCheck(info *internal.FuncInfo, field internal.StructField, t types.Type, value, arg string) validateCheck {
+	values := strings.Fields(arg)
+	if len(values) == 0 {
+		log.Fatalf("error: func %s field %s: oneof requires values", info.FuncName, field.GoName)
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsString|types.IsInteger|types.IsFloat) == 0 {
+		log.Fatalf("error: func %s field %s: oneof does not apply to %s", info.FuncName, field.GoName, t)
+	}
+	var cond []any
+	for i, v := range values {
+		if i > 0 {
+			cond = append(cond, " && ")
+		}
+		literal := v
+		if basic.Info()&types.IsString != 0 {
+			literal = strconv.Quote(v)
+		} else if _, err := strconv.ParseFloat(v, 64); err != nil {
+			log.Fatalf("error: func %s field %s: oneof value %s is not a number", info.FuncName, field.GoName, v)
+		}
+		cond = append(cond, value, " != ", literal)
+	}
+	return validateCheck{cond: cond, description: "must be one of [" + strings.Join(values, " ") + "]"}
+}
+
+// zeroCond returns the condition that x, of type t, is the zero value.
+func zeroCond(t types.Type, x string) []any {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return []any{x, ` == ""`}
+		case u.Info()&types.IsNumeric != 0:
+			return []any{x, " == 0"}
+		case u.Info()&types.IsBoolean != 0:
+			return []any{"!", x}
+		}
+	case *types.Pointer, *types.Interface, *types.Chan, *types.Signature:
+		return []any{x, " == nil"}
+	case *types.Slice, *types.Map:
+		return []any{"len(", x, ") == 0"}
+	}
+	return []any{gorsPackage.Ident("IsZero"), "(", x, ")"}
+}
+
+// nonZeroCond returns the condition that x, of type t, is not the zero value.
+func nonZeroCond(t types.Type, x string) []any {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return []any{x, ` != ""`}
+		case u.Info()&types.IsNumeric != 0:
+			return []any{x, " != 0"}
+		case u.Info()&types.IsBoolean != 0:
+			return []any{x}
+		}
+	case *types.Pointer, *types.Interface, *types.Chan, *types.Signature:
+		return []any{x, " != nil"}
+	case *types.Slice, *types.Map:
+		return []any{"len(", x, ") != 0"}
+	}
+	return []any{"!", gorsPackage.Ident("IsZero"), "(", x, ")"}
+}
+
+// stringValue converts value of the string type t to a string, when t is a named string type.
+func stringValue(t types.Type, value string) string {
+	if types.Identical(t, types.Typ[types.String]) {
+		return value
+	}
+	return "string(" + value + ")"
+}
+
+func mustParseInt(info *internal.FuncInfo, field internal.StructField, rule, arg string) {
+	if _, err := strconv.Atoi(arg); err != nil {
+		log.Fatalf("error: func %s field %s: %s=%s is not an integer", info.FuncName, field.GoName, rule, arg)
+	}
+}
+
+// violationField returns the name and source of the field in a violation, those of the first binding
+// naming the field, or else of the body binding.
+func (g *Generate) violationField(info *internal.FuncInfo, field internal.StructField) (string, string) {
+	bodyKey := ""
+	for _, binding := range info.Route.Bindings {
+		key, source, body := bindingTag(binding)
+		if key == "" {
+			continue
+		}
+		if name, ok := field.Tag(key); ok {
+			return name, source
+		}
+		if body && bodyKey == "" {
+			bodyKey = key
+		}
+	}
+	if name, ok := field.Name(bodyKey); ok && bodyKey != "" {
+		return name, bodyKey
+	}
+	return field.GoName, ""
+}
+
+// bindingTag returns the struct tag key of a binding, the source of the request it binds, and whether
+// it binds the body, whose fields are named by their Go names when they have no tag.
+func bindingTag(binding any) (key string, source string, body bool) {
+	switch binding {
+	case internal.UriBinding:
+		return "uri", "uri", false
+	case internal.QueryBinding:
+		return "form", "query", false
+	case internal.HeaderBinding:
+		return "header", "header", false
+	case internal.FormBinding, internal.FormPostBinding, internal.FormMultipartBinding:
+		return "form", "form", false
+	case internal.JSONBinding:
+		return "json", "json", true
+	case internal.XMLBinding:
+		return "xml", "xml", true
+	case internal.YAMLBinding:
+		return "yaml", "yaml", true
+	case internal.TOMLBinding:
+		return "toml", "toml", true
+	case internal.MsgPackBinding:
+		return "msgpack", "msgpack", true
+	}
+	return "", "", false
+}
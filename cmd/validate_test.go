@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/go-miya/gorsx/internal"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+const validateSrc = `package users
+
+import "context"
+
+type Role string
+
+type UpdateReq struct {
+	ID       int      ` + "`uri:\"id\" validate:\"required,min=1\"`" + `
+	Page     int      ` + "`form:\"page\" validate:\"omitempty,max=100\"`" + `
+	Name     string   ` + "`json:\"name\" validate:\"required,min=2,max=64\"`" + `
+	Email    *string  ` + "`json:\"email\" validate:\"email\"`" + `
+	Role     Role     ` + "`json:\"role\" validate:\"oneof=admin member\"`" + `
+	Tags     []string ` + "`validate:\"max=8\"`" + `
+	Code     string   ` + "`json:\"code\" validate:\"len=6\"`" + `
+	Score    float64  ` + "`json:\"score\" validate:\"omitempty,min=0.5\"`" + `
+	Internal string   ` + "`json:\"-\" validate:\"-\"`" + `
+}
+
+type Users interface {
+	Update(ctx context.Context, req *UpdateReq) error
+}
+`
+
+// validateFunc returns the FuncInfo of Users.Update, routed by the @GORS annotation comment.
+func validateFunc(t *testing.T, comment string) *internal.FuncInfo {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "users.go", validateSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/users", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := pkg.Scope().Lookup("Users").Type().Underlying().(*types.Interface).Method(0)
+	info := internal.NewMethodInfo(method.Name(), nil, method.Type().(*types.Signature))
+	if err := info.Check(); err != nil {
+		t.Fatal(err)
+	}
+	info.Comments = []string{comment}
+	info.Route = internal.NewRoute(method.Name(), info.Comments)
+	return info
+}
+
+func TestPrintValidate(t *testing.T) {
+	info := validateFunc(t, "// @GORS @PUT @Path(/users/:id) @UriBinding @QueryBinding @JSONBinding @Validate")
+	g := &Generate{SrvName: "Users"}
+	if !g.validates(info) {
+		t.Fatal("validates = false, want the @Validate method validated")
+	}
+	f := newGoFile("users", "example.com/users")
+	g.printValidate(f, info)
+	got, err := f.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the violations are named after the bindings of the fields, in the messages of their rules
+	checkGolden(t, "validate.golden", got)
+}
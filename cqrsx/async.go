@@ -0,0 +1,473 @@
+package cqrsx
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-miya/gorsx/gors"
+)
+
+// ErrJobNotFound is returned for an unknown job. Handlers respond it as 404 Not Found.
+var ErrJobNotFound = gors.NewError(http.StatusNotFound, "job_not_found", "job not found")
+
+// JobStatus is the status of the job of an @Async command.
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job is an @Async command enqueued in a CommandQueue.
+type Job struct {
+	ID string `json:"id"`
+	// Command is the name of the command, such as Service.Endpoint.
+	Command string `json:"command"`
+	// Payload is the command encoded in JSON.
+	Payload []byte    `json:"-"`
+	Status  JobStatus `json:"status"`
+	// Error is the error of a failed job.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CommandQueue queues the jobs of the @Async commands, and records their status.
+type CommandQueue interface {
+	// Enqueue adds a pending job.
+	Enqueue(ctx context.Context, job *Job) error
+	// Dequeue waits for a pending job, which it marks running, until ctx is done.
+	Dequeue(ctx context.Context) (*Job, error)
+	// Finish marks the job of id succeeded, or failed when cause is not nil.
+	Finish(ctx context.Context, id string, cause error) error
+	// Release marks the running job of id pending again, to be run by another worker.
+	Release(ctx context.Context, id string) error
+	// Job returns the job of id, or ErrJobNotFound.
+	Job(ctx context.Context, id string) (*Job, error)
+}
+
+// AcceptedJob is the response of the handler of an @Async command, 202 Accepted.
+type AcceptedJob struct {
+	JobID string `json:"job_id"`
+}
+
+type acceptedJobKey struct{}
+
+// WithAcceptedJob returns a copy of ctx carrying an AcceptedJob, which Enqueue fills in with the ID of
+// the job of the command.
+func WithAcceptedJob(ctx context.Context) (context.Context, *AcceptedJob) {
+	job := &AcceptedJob{}
+	return context.WithValue(ctx, acceptedJobKey{}, job), job
+}
+
+// AcceptedJobFrom returns the AcceptedJob carried by ctx.
+func AcceptedJobFrom(ctx context.Context) (*AcceptedJob, bool) {
+	job, ok := ctx.Value(acceptedJobKey{}).(*AcceptedJob)
+	return job, ok
+}
+
+// Enqueue enqueues cmd, the command of name, such as Service.Endpoint, encoded in JSON, in queue, and
+// fills in the AcceptedJob carried by ctx with the ID of its job. It is called by the implementations of
+// the @Async methods.
+func Enqueue(ctx context.Context, queue CommandQueue, name string, cmd any) error {
+	if queue == nil {
+		return fmt.Errorf("cqrsx: %s is @Async, set the Queue of the Commands", name)
+	}
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("cqrsx: encode command: %w", err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := queue.Enqueue(ctx, &Job{ID: id, Command: name, Payload: payload, Status: JobPending, CreatedAt: now, UpdatedAt: now}); err != nil {
+		return err
+	}
+	if accepted, ok := AcceptedJobFrom(ctx); ok {
+		accepted.JobID = id
+	}
+	return nil
+}
+
+func newJobID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("cqrsx: generate job id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// MemoryCommandQueue is an in-memory CommandQueue, for a single process. Its jobs are lost when the
+// process stops, and only the status of its last DefaultFinishedJobs finished jobs is kept.
+type MemoryCommandQueue struct {
+	pending chan string
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	// finished are the IDs of the finished jobs, the oldest first.
+	finished []string
+	keep     int
+}
+
+var _ CommandQueue = (*MemoryCommandQueue)(nil)
+
+// DefaultFinishedJobs is how many finished jobs a MemoryCommandQueue keeps the status of.
+const DefaultFinishedJobs = 1000
+
+// NewMemoryCommandQueue returns a queue buffering size pending jobs, whose Enqueue blocks beyond.
+func NewMemoryCommandQueue(size int) *MemoryCommandQueue {
+	return &MemoryCommandQueue{pending: make(chan string, size), jobs: make(map[string]*Job), keep: DefaultFinishedJobs}
+}
+
+func (q *MemoryCommandQueue) Enqueue(ctx context.Context, job *Job) error {
+	q.mu.Lock()
+	q.jobs[job.ID] = job
+	q.mu.Unlock()
+	select {
+	case q.pending <- job.ID:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		delete(q.jobs, job.ID)
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (q *MemoryCommandQueue) Dequeue(ctx context.Context) (*Job, error) {
+	select {
+	case id := <-q.pending:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		job := q.jobs[id]
+		job.Status, job.UpdatedAt = JobRunning, time.Now()
+		copied := *job
+		return &copied, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *MemoryCommandQueue) Finish(ctx context.Context, id string, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.Status, job.UpdatedAt = JobSucceeded, time.Now()
+	if cause != nil {
+		job.Status, job.Error = JobFailed, cause.Error()
+	}
+	q.finished = append(q.finished, id)
+	for len(q.finished) > q.keep {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+	return nil
+}
+
+func (q *MemoryCommandQueue) Release(ctx context.Context, id string) error {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrJobNotFound
+	}
+	job.Status, job.UpdatedAt = JobPending, time.Now()
+	q.mu.Unlock()
+	select {
+	case q.pending <- id:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *MemoryCommandQueue) Job(ctx context.Context, id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// SQLCommandQueue is a durable CommandQueue of a database/sql table, shared by the processes of a
+// service. The table is created by:
+//
+//	CREATE TABLE command_jobs (
+//		id         VARCHAR(64) PRIMARY KEY,
+//		command    VARCHAR(255) NOT NULL,
+//		payload    BLOB NOT NULL,
+//		status     VARCHAR(16) NOT NULL,
+//		error      TEXT,
+//		created_at BIGINT NOT NULL,
+//		updated_at BIGINT NOT NULL
+//	)
+//
+// where the times are in Unix milliseconds. A running job is leased to its worker, and is claimed again
+// once the lease expires, so that the jobs of a crashed worker are run by another one. The jobs running
+// longer than the lease are run twice, set it with WithLease beyond the longest command.
+type SQLCommandQueue struct {
+	db           *sql.DB
+	table        string
+	placeholder  func(n int) string
+	pollInterval time.Duration
+	lease        time.Duration
+	now          func() time.Time
+}
+
+var _ CommandQueue = (*SQLCommandQueue)(nil)
+
+// DefaultJobLease is how long a running job of a SQLCommandQueue is leased to its worker by default.
+const DefaultJobLease = 5 * time.Minute
+
+// NewSQLCommandQueue returns a queue of the command_jobs table of db, polled every pollInterval by
+// Dequeue when it is empty.
+func NewSQLCommandQueue(db *sql.DB, pollInterval time.Duration, opts ...SQLStoreOption) *SQLCommandQueue {
+	o := newSQLStoreOptions("command_jobs", opts)
+	lease := o.lease
+	if lease <= 0 {
+		lease = DefaultJobLease
+	}
+	return &SQLCommandQueue{db: db, table: o.table, placeholder: o.placeholder, pollInterval: pollInterval, lease: lease, now: time.Now}
+}
+
+func (q *SQLCommandQueue) Enqueue(ctx context.Context, job *Job) error {
+	p := q.placeholder
+	if _, err := q.db.ExecContext(ctx,
+		"INSERT INTO "+q.table+" (id, command, payload, status, created_at, updated_at) VALUES ("+p(1)+", "+p(2)+", "+p(3)+", "+p(4)+", "+p(5)+", "+p(6)+")",
+		job.ID, job.Command, job.Payload, string(job.Status), job.CreatedAt.UnixMilli(), job.UpdatedAt.UnixMilli()); err != nil {
+		return fmt.Errorf("cqrsx: insert job: %w", err)
+	}
+	return nil
+}
+
+func (q *SQLCommandQueue) Dequeue(ctx context.Context) (*Job, error) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := q.claim(ctx)
+		if err != nil || job != nil {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks the oldest pending job, or running job whose lease expired, running, and returns nil
+// when there is none.
+func (q *SQLCommandQueue) claim(ctx context.Context) (*Job, error) {
+	p := q.placeholder
+	for {
+		now := q.now()
+		expired := now.Add(-q.lease).UnixMilli()
+		var id string
+		err := q.db.QueryRowContext(ctx,
+			"SELECT id FROM "+q.table+" WHERE status = "+p(1)+" OR (status = "+p(2)+" AND updated_at <= "+p(3)+") ORDER BY created_at, id LIMIT 1",
+			string(JobPending), string(JobRunning), expired).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cqrsx: select pending job: %w", err)
+		}
+		res, err := q.db.ExecContext(ctx,
+			"UPDATE "+q.table+" SET status = "+p(1)+", updated_at = "+p(2)+" WHERE id = "+p(3)+" AND (status = "+p(4)+" OR (status = "+p(5)+" AND updated_at <= "+p(6)+"))",
+			string(JobRunning), now.UnixMilli(), id, string(JobPending), string(JobRunning), expired)
+		if err != nil {
+			return nil, fmt.Errorf("cqrsx: claim job: %w", err)
+		}
+		// another worker may have claimed the job in between
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			return q.Job(ctx, id)
+		}
+	}
+}
+
+func (q *SQLCommandQueue) Finish(ctx context.Context, id string, cause error) error {
+	p := q.placeholder
+	status, msg := JobSucceeded, sql.NullString{}
+	if cause != nil {
+		status, msg = JobFailed, sql.NullString{String: cause.Error(), Valid: true}
+	}
+	if _, err := q.db.ExecContext(ctx,
+		"UPDATE "+q.table+" SET status = "+p(1)+", error = "+p(2)+", updated_at = "+p(3)+" WHERE id = "+p(4),
+		string(status), msg, q.now().UnixMilli(), id); err != nil {
+		return fmt.Errorf("cqrsx: finish job: %w", err)
+	}
+	return nil
+}
+
+func (q *SQLCommandQueue) Release(ctx context.Context, id string) error {
+	p := q.placeholder
+	if _, err := q.db.ExecContext(ctx,
+		"UPDATE "+q.table+" SET status = "+p(1)+", updated_at = "+p(2)+" WHERE id = "+p(3)+" AND status = "+p(4),
+		string(JobPending), q.now().UnixMilli(), id, string(JobRunning)); err != nil {
+		return fmt.Errorf("cqrsx: release job: %w", err)
+	}
+	return nil
+}
+
+func (q *SQLCommandQueue) Job(ctx context.Context, id string) (*Job, error) {
+	job := &Job{ID: id}
+	var status string
+	var msg sql.NullString
+	var createdAt, updatedAt int64
+	err := q.db.QueryRowContext(ctx,
+		"SELECT command, payload, status, error, created_at, updated_at FROM "+q.table+" WHERE id = "+q.placeholder(1),
+		id).Scan(&job.Command, &job.Payload, &status, &msg, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cqrsx: select job: %w", err)
+	}
+	job.Status, job.Error = JobStatus(status), msg.String
+	job.CreatedAt, job.UpdatedAt = time.UnixMilli(createdAt), time.UnixMilli(updatedAt)
+	return job, nil
+}
+
+// Worker runs the jobs of a CommandQueue with the handlers of their commands.
+type Worker struct {
+	queue    CommandQueue
+	opts     *workerOptions
+	mu       sync.RWMutex
+	handlers map[string]func(ctx context.Context, payload []byte) error
+}
+
+// WorkerOption configures a Worker.
+type WorkerOption func(o *workerOptions)
+
+type workerOptions struct {
+	concurrency int
+	onError     func(err error)
+	// backoff is how long a goroutine of the Worker waits after an error of the queue.
+	backoff time.Duration
+}
+
+// WithWorkerConcurrency sets how many jobs the Worker runs at once, 1 by default.
+func WithWorkerConcurrency(n int) WorkerOption {
+	return func(o *workerOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithWorkerErrorHandler sets the handler of the errors of the queue, which are ignored by default.
+// The errors of the jobs are recorded in their status.
+func WithWorkerErrorHandler(h func(err error)) WorkerOption {
+	return func(o *workerOptions) {
+		o.onError = h
+	}
+}
+
+// NewWorker returns a Worker of queue, whose commands are registered with RegisterCommand.
+func NewWorker(queue CommandQueue, opts ...WorkerOption) *Worker {
+	o := &workerOptions{concurrency: 1, onError: func(error) {}, backoff: time.Second}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Worker{queue: queue, opts: o, handlers: make(map[string]func(ctx context.Context, payload []byte) error)}
+}
+
+// RegisterCommand registers h for the jobs of the command of name, such as Service.Endpoint.
+func RegisterCommand[C any](w *Worker, name string, h CommandHandler[C]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[name] = func(ctx context.Context, payload []byte) error {
+		var cmd C
+		target := any(&cmd)
+		if t := reflect.TypeOf(cmd); t != nil && t.Kind() == reflect.Pointer {
+			cmd = reflect.New(t.Elem()).Interface().(C)
+			target = cmd
+		}
+		if err := json.Unmarshal(payload, target); err != nil {
+			return fmt.Errorf("cqrsx: decode command: %w", err)
+		}
+		return h.Handle(ctx, cmd)
+	}
+}
+
+// Run runs the jobs of the queue until ctx is done, and returns its error.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := w.queue.Dequeue(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						w.opts.onError(err)
+					}
+					// back off from the failing queue
+					select {
+					case <-ctx.Done():
+					case <-time.After(w.opts.backoff):
+					}
+					continue
+				}
+				err = w.run(ctx, job)
+				if errors.Is(err, errNoHandler) || (err != nil && ctx.Err() != nil) {
+					// another worker of the queue may handle the command, or run it once this one stops
+					if errors.Is(err, errNoHandler) {
+						w.opts.onError(err)
+					}
+					if err := w.queue.Release(context.Background(), job.ID); err != nil {
+						w.opts.onError(err)
+					}
+					select {
+					case <-ctx.Done():
+					case <-time.After(w.opts.backoff):
+					}
+					continue
+				}
+				// the job is finished even when ctx is done in the meantime
+				if err := w.queue.Finish(context.Background(), job.ID, err); err != nil {
+					w.opts.onError(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// errNoHandler is returned by Worker.run for the commands registered by no RegisterCommand.
+var errNoHandler = errors.New("cqrsx: no handler of command")
+
+// run runs job, recovering from the panics of its handler.
+func (w *Worker) run(ctx context.Context, job *Job) (err error) {
+	w.mu.RLock()
+	handle, ok := w.handlers[job.Command]
+	w.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w %s", errNoHandler, job.Command)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("cqrsx: command %s panicked: %v", job.Command, p)
+		}
+	}()
+	return handle(ctx, job.Payload)
+}
@@ -0,0 +1,324 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const commandJobsSchema = `CREATE TABLE command_jobs (
+	id         VARCHAR(64) PRIMARY KEY,
+	command    VARCHAR(255) NOT NULL,
+	payload    BLOB NOT NULL,
+	status     VARCHAR(16) NOT NULL,
+	error      TEXT,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+)`
+
+type batchDeleteCmd struct {
+	IDs []int
+}
+
+// testCommandQueue checks the lifecycle of the jobs of q.
+func testCommandQueue(t *testing.T, q CommandQueue) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	accepted, job := WithAcceptedJob(ctx)
+	if err := Enqueue(accepted, q, "Keyword.BatchDelete", &batchDeleteCmd{IDs: []int{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Enqueue(ctx, q, "Keyword.BatchDelete", &batchDeleteCmd{IDs: []int{3}}); err != nil {
+		t.Fatal(err)
+	}
+	if job.JobID == "" {
+		t.Fatal("Enqueue did not fill in the accepted job")
+	}
+	if got, err := q.Job(ctx, job.JobID); err != nil || got.Status != JobPending || got.Command != "Keyword.BatchDelete" {
+		t.Fatalf("Job = %+v, %v, want a pending Keyword.BatchDelete", got, err)
+	}
+
+	first, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the jobs enqueued within the same millisecond may be dequeued in any order
+	if second.ID == job.JobID {
+		first, second = second, first
+	}
+	if first.ID != job.JobID || first.Status != JobRunning || string(first.Payload) != `{"IDs":[1,2]}` {
+		t.Fatalf("Dequeue = %+v %s, want the accepted job running", first, first.Payload)
+	}
+	if err := q.Finish(ctx, first.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Finish(ctx, second.ID, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Job(ctx, first.ID); got.Status != JobSucceeded || got.Error != "" {
+		t.Errorf("first job = %+v, want it succeeded", got)
+	}
+	if got, _ := q.Job(ctx, second.ID); got.Status != JobFailed || got.Error != "boom" {
+		t.Errorf("second job = %+v, want it failed with boom", got)
+	}
+	if _, err := q.Job(ctx, "unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Job(unknown) = %v, want %v", err, ErrJobNotFound)
+	}
+
+	// a released job is run again
+	if err := Enqueue(ctx, q, "Keyword.BatchDelete", &batchDeleteCmd{}); err != nil {
+		t.Fatal(err)
+	}
+	released, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Release(ctx, released.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Job(ctx, released.ID); got.Status != JobPending {
+		t.Errorf("released job = %+v, want it pending", got)
+	}
+	if again, err := q.Dequeue(ctx); err != nil || again.ID != released.ID || again.Status != JobRunning {
+		t.Errorf("Dequeue after Release = %+v, %v, want the released job running", again, err)
+	}
+
+	empty, cancelEmpty := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelEmpty()
+	if _, err := q.Dequeue(empty); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dequeue of an empty queue = %v, want it to wait until ctx is done", err)
+	}
+}
+
+func TestMemoryCommandQueue(t *testing.T) {
+	testCommandQueue(t, NewMemoryCommandQueue(10))
+}
+
+func TestMemoryCommandQueueKeepsLastFinishedJobs(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryCommandQueue(10)
+	q.keep = 2
+	var ids []string
+	for i := 0; i < 3; i++ {
+		accepted, job := WithAcceptedJob(ctx)
+		if err := Enqueue(accepted, q, "Keyword.BatchDelete", &batchDeleteCmd{}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.JobID)
+	}
+	for range ids {
+		job, err := q.Dequeue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Finish(ctx, job.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Job(ctx, ids[0]); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Job of the oldest finished job = %v, want %v", err, ErrJobNotFound)
+	}
+	for _, id := range ids[1:] {
+		if job, err := q.Job(ctx, id); err != nil || job.Status != JobSucceeded {
+			t.Errorf("Job = %+v, %v, want the last finished jobs kept", job, err)
+		}
+	}
+}
+
+func TestSQLCommandQueue(t *testing.T) {
+	testCommandQueue(t, NewSQLCommandQueue(openSQLite(t, commandJobsSchema), time.Millisecond))
+}
+
+func TestSQLCommandQueueReclaimsExpiredLeases(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	q := NewSQLCommandQueue(openSQLite(t, commandJobsSchema), time.Millisecond, WithLease(time.Minute))
+	q.now = func() time.Time { return now }
+	if err := Enqueue(ctx, q, "Keyword.BatchDelete", &batchDeleteCmd{}); err != nil {
+		t.Fatal(err)
+	}
+	// the worker running the job crashes
+	crashed, err := q.claim(ctx)
+	if err != nil || crashed == nil {
+		t.Fatalf("claim = %v, %v, want the pending job", crashed, err)
+	}
+	now = now.Add(59 * time.Second)
+	if job, err := q.claim(ctx); err != nil || job != nil {
+		t.Fatalf("claim within the lease = %+v, %v, want none", job, err)
+	}
+	now = now.Add(time.Second)
+	job, err := q.claim(ctx)
+	if err != nil || job == nil || job.ID != crashed.ID || job.Status != JobRunning {
+		t.Fatalf("claim after the lease = %+v, %v, want the job of the crashed worker", job, err)
+	}
+	if !job.UpdatedAt.Equal(now) {
+		t.Errorf("UpdatedAt = %v, want the lease renewed at %v", job.UpdatedAt, now)
+	}
+}
+
+// flakyQueue fails its first Dequeue calls.
+type flakyQueue struct {
+	CommandQueue
+	mu       sync.Mutex
+	failures int
+}
+
+func (q *flakyQueue) Dequeue(ctx context.Context) (*Job, error) {
+	q.mu.Lock()
+	if q.failures > 0 {
+		q.failures--
+		q.mu.Unlock()
+		return nil, errors.New("queue down")
+	}
+	q.mu.Unlock()
+	return q.CommandQueue.Dequeue(ctx)
+}
+
+func TestWorkerRunsJobs(t *testing.T) {
+	memory := NewMemoryCommandQueue(10)
+	queue := &flakyQueue{CommandQueue: memory, failures: 2}
+	var queueErrs []error
+	var mu sync.Mutex
+	w := NewWorker(queue, WithWorkerConcurrency(2), WithWorkerErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		queueErrs = append(queueErrs, err)
+	}))
+	w.opts.backoff = time.Millisecond
+
+	var got []*batchDeleteCmd
+	RegisterCommand[*batchDeleteCmd](w, "Keyword.BatchDelete", commandFunc[*batchDeleteCmd](func(ctx context.Context, cmd *batchDeleteCmd) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, cmd)
+		if len(cmd.IDs) == 0 {
+			return errors.New("nothing to delete")
+		}
+		return nil
+	}))
+	RegisterCommand[batchDeleteCmd](w, "Keyword.Panic", commandFunc[batchDeleteCmd](func(ctx context.Context, cmd batchDeleteCmd) error {
+		panic("boom")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ids := make(map[string]string)
+	for _, c := range []struct {
+		name string
+		cmd  any
+	}{
+		{"Keyword.BatchDelete", &batchDeleteCmd{IDs: []int{1, 2}}},
+		{"Keyword.BatchDelete", &batchDeleteCmd{}},
+		{"Keyword.Panic", &batchDeleteCmd{}},
+	} {
+		accepted, job := WithAcceptedJob(ctx)
+		if err := Enqueue(accepted, memory, c.name, c.cmd); err != nil {
+			t.Fatal(err)
+		}
+		ids[job.JobID] = c.name
+	}
+
+	done := make(chan error)
+	runCtx, stop := context.WithCancel(ctx)
+	go func() { done <- w.Run(runCtx) }()
+	waitFor(t, func() bool {
+		for id := range ids {
+			if job, _ := memory.Job(ctx, id); job.Status == JobPending || job.Status == JobRunning {
+				return false
+			}
+		}
+		return true
+	})
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want %v", err, context.Canceled)
+	}
+
+	statuses := make(map[JobStatus]int)
+	for id := range ids {
+		job, _ := memory.Job(ctx, id)
+		statuses[job.Status]++
+		if job.Status == JobFailed && job.Error == "" {
+			t.Errorf("job %s failed without its error", job.Command)
+		}
+	}
+	if statuses[JobSucceeded] != 1 || statuses[JobFailed] != 2 {
+		t.Errorf("statuses = %v, want 1 succeeded and 2 failed, by an error and a panic", statuses)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queueErrs) != 2 {
+		t.Errorf("queue errors = %v, want the 2 failed Dequeue calls reported and retried", queueErrs)
+	}
+	if len(got) != 2 || got[0] == nil || got[1] == nil {
+		t.Fatalf("handled commands = %v, want the 2 Keyword.BatchDelete commands decoded", got)
+	}
+	var decoded bool
+	for _, cmd := range got {
+		decoded = decoded || (len(cmd.IDs) == 2 && cmd.IDs[0] == 1 && cmd.IDs[1] == 2)
+	}
+	if !decoded {
+		t.Errorf("handled commands = %+v, want the IDs of the first one decoded", got)
+	}
+}
+
+func TestWorkerReleasesJobs(t *testing.T) {
+	queue := NewMemoryCommandQueue(10)
+	var mu sync.Mutex
+	var errs []error
+	w := NewWorker(queue, WithWorkerConcurrency(2), WithWorkerErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	w.opts.backoff = time.Millisecond
+	running := make(chan struct{})
+	RegisterCommand[*batchDeleteCmd](w, "Keyword.BatchDelete", commandFunc[*batchDeleteCmd](func(ctx context.Context, cmd *batchDeleteCmd) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ids := make([]string, 0, 2)
+	// the command of another service sharing the queue, which this worker does not handle
+	for _, name := range []string{"Keyword.BatchDelete", "Other.Import"} {
+		accepted, job := WithAcceptedJob(ctx)
+		if err := Enqueue(accepted, queue, name, &batchDeleteCmd{}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.JobID)
+	}
+
+	done := make(chan error)
+	runCtx, stop := context.WithCancel(ctx)
+	go func() { done <- w.Run(runCtx) }()
+	<-running
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	})
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want %v", err, context.Canceled)
+	}
+	// the job interrupted by the stop and the unknown command are left to other workers
+	for _, id := range ids {
+		if job, err := queue.Job(ctx, id); err != nil || job.Status != JobPending {
+			t.Errorf("Job = %+v, %v, want it released pending", job, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !errors.Is(errs[0], errNoHandler) {
+		t.Errorf("worker error = %v, want %v", errs[0], errNoHandler)
+	}
+}
@@ -0,0 +1,114 @@
+package cqrsx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-miya/gorsx/gors"
+)
+
+// ErrUnauthenticated is returned when an endpoint requiring authorization is called without a
+// principal. Handlers respond it as 401 Unauthorized.
+var ErrUnauthenticated = gors.NewError(http.StatusUnauthorized, "unauthenticated", "authentication required")
+
+// ForbiddenError is returned when the principal lacks the roles or permissions an endpoint requires.
+// It wraps a 403 Forbidden gors.Error, which handlers respond.
+type ForbiddenError struct {
+	// Endpoint is the endpoint, such as Service.Endpoint.
+	Endpoint string
+	// Principal is the ID of the principal.
+	Principal string
+	// Roles are the roles the endpoint requires any of, when the principal has none of them.
+	Roles []string
+	// Permissions are the permissions the endpoint requires that the principal lacks.
+	Permissions []string
+}
+
+func (e *ForbiddenError) Error() string {
+	msg := "cqrsx: " + e.Principal + " is forbidden to call " + e.Endpoint
+	if len(e.Roles) > 0 {
+		msg += ", any role of " + strings.Join(e.Roles, ", ") + " is required"
+	}
+	if len(e.Permissions) > 0 {
+		msg += ", missing permissions " + strings.Join(e.Permissions, ", ")
+	}
+	return msg
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return gors.Errorf(http.StatusForbidden, "forbidden", "forbidden to call %s", e.Endpoint)
+}
+
+// Principal is the authenticated caller of an endpoint.
+type Principal struct {
+	ID          string
+	Roles       []string
+	Permissions []string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p, such as in a middleware authenticating the requests.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal carried by ctx.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// AuthRequirement is the authorization of an endpoint, declared by @Auth and @Permission.
+type AuthRequirement = gors.AuthRequirement
+
+// Authorizer decides whether the principal of ctx may call the endpoint of requirement. It returns
+// ErrUnauthenticated, a *ForbiddenError or another error when it may not.
+type Authorizer = gors.Authorizer
+
+// AuthorizerFunc is a function Authorizer.
+type AuthorizerFunc = gors.AuthorizerFunc
+
+// PrincipalExtractor returns the principal of ctx.
+type PrincipalExtractor func(ctx context.Context) (*Principal, bool)
+
+// NewAuthorizer returns an Authorizer checking the roles and permissions of the principal extracted by
+// extract, PrincipalFrom when nil. It authorizes the handlers of the @GORS methods with
+// gors.WithAuthorizer.
+func NewAuthorizer(extract PrincipalExtractor) Authorizer {
+	if extract == nil {
+		extract = PrincipalFrom
+	}
+	return AuthorizerFunc(func(ctx context.Context, requirement *AuthRequirement) error {
+		p, ok := extract(ctx)
+		if !ok {
+			return ErrUnauthenticated
+		}
+		forbidden := &ForbiddenError{Endpoint: requirement.Endpoint, Principal: p.ID}
+		if len(requirement.Roles) > 0 && !hasAny(p.Roles, requirement.Roles) {
+			forbidden.Roles = requirement.Roles
+		}
+		for _, permission := range requirement.Permissions {
+			if !hasAny(p.Permissions, []string{permission}) {
+				forbidden.Permissions = append(forbidden.Permissions, permission)
+			}
+		}
+		if len(forbidden.Roles) > 0 || len(forbidden.Permissions) > 0 {
+			return forbidden
+		}
+		return nil
+	})
+}
+
+// hasAny reports whether have contains any of want.
+func hasAny(have []string, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package cqrsx
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cache stores the results of the @Cache queries. Values are the JSON encoding of the results, so that
+// a shared store such as Redis may implement it.
+type Cache interface {
+	// Get returns the value of key, reporting whether it is found and not expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value of key, expiring after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes the values of keys.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// CacheInfo is the caching of a query, declared by @Cache(ttl=30s,key=Field1+Field2).
+type CacheInfo struct {
+	TTL time.Duration
+	// Key returns the key of the result of a query, derived from the fields of the query.
+	Key func(q any) string
+}
+
+// WithCache caches the results of the @Cache queries in c, and deletes the ones the @Invalidate
+// commands invalidate. Nothing is cached when c is nil.
+func WithCache(c Cache) Option {
+	return func(o *Options) {
+		o.Cache = c
+	}
+}
+
+// CacheKey returns the key of the result of the endpoint name for the parts of a query, the hash of
+// their JSON encoding.
+func CacheKey(name string, parts ...any) string {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		// unsupported values, such as channels, are hashed by their Go syntax
+		data = []byte(fmt.Sprintf("%#v", parts))
+	}
+	sum := sha256.Sum256(data)
+	return "cqrsx:" + name + ":" + hex.EncodeToString(sum[:])
+}
+
+// cached wraps the handler of a @Cache query, returning the cached result of the query if any.
+// Concurrent misses of a key share the handling of the first one, which runs without the cancellation
+// of its caller, so that the others do not fail when the first caller goes away. The others stop
+// waiting for it when their own context is done.
+func cached[R any](o *Options, info Info, next Handler) Handler {
+	c, ci := o.Cache, info.Cache
+	return func(ctx context.Context, req any) (any, error) {
+		key := ci.Key(req)
+		if data, ok, err := c.Get(ctx, key); err == nil && ok {
+			var r R
+			if err := json.Unmarshal(data, &r); err == nil {
+				return r, nil
+			}
+		}
+		return o.flights.do(ctx, key, func() (any, error) {
+			ctx := context.WithoutCancel(ctx)
+			res, err := next(ctx, req)
+			if err != nil {
+				return res, err
+			}
+			if data, err := json.Marshal(res); err == nil {
+				_ = c.Set(ctx, key, data, ci.TTL)
+			}
+			return res, nil
+		})
+	}
+}
+
+// invalidating wraps the handler of a @Invalidate command, deleting the cached results of the queries
+// it invalidates once it succeeds. Failing to delete them does not fail the command, whose results
+// are committed.
+func (o *Options) invalidating(info Info, next Handler) Handler {
+	c := o.Cache
+	return func(ctx context.Context, req any) (any, error) {
+		res, err := next(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		if keys := info.Invalidates(req); len(keys) > 0 {
+			_ = c.Delete(ctx, keys...)
+		}
+		return res, nil
+	}
+}
+
+// flightGroup deduplicates the concurrent calls of a key.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	// done is closed once the first call returns.
+	done chan struct{}
+	res  any
+	err  error
+}
+
+// errFlightPanicked is returned to the calls waiting for a first call that panicked.
+var errFlightPanicked = errors.New("cqrsx: the shared handling panicked")
+
+// do returns the result of fn, or of the call of fn in flight for key. A call waiting for the one in
+// flight returns when ctx is done. When fn panics, the panic goes on in its call, and the waiting ones
+// fail with errFlightPanicked.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (any, error)) (any, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	if f, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.res, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{}), err: errFlightPanicked}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.res, f.err = fn()
+	return f.res, f.err
+}
+
+// LRUCache is an in-memory Cache evicting the least recently used values beyond its size.
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+// NewLRUCache returns an in-memory cache of at most size values.
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &LRUCache{size: size, ll: list.New(), entries: make(map[string]*list.Element), now: time.Now}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.expireAt.IsZero() && !c.now().Before(entry.expireAt) {
+		c.ll.Remove(elem)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.ll.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (c *LRUCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = c.now().Add(ttl)
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = &lruEntry{key: key, value: value, expireAt: expireAt}
+		c.ll.MoveToFront(elem)
+		return nil
+	}
+	c.entries[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (c *LRUCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.ll.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type orderQuery struct {
+	ID string
+}
+
+type orderResult struct {
+	ID    string
+	Calls int32
+}
+
+type cancelOrderCmd struct {
+	ID string
+}
+
+// countingOrders is a query handler counting its calls, blocked on release when it is not nil.
+type countingOrders struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (h *countingOrders) Handle(ctx context.Context, q *orderQuery) (*orderResult, error) {
+	calls := h.calls.Add(1)
+	if h.release != nil {
+		<-h.release
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &orderResult{ID: q.ID, Calls: calls}, nil
+}
+
+var getOrderInfo = Info{Service: "Orders", Endpoint: "GetOrder", Kind: KindQuery, Cache: &CacheInfo{
+	TTL: time.Minute,
+	Key: func(q any) string { return CacheKey("Orders.GetOrder", q.(*orderQuery).ID) },
+}}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(2)
+	_ = c.Set(ctx, "a", []byte("1"), 0)
+	_ = c.Set(ctx, "b", []byte("2"), 0)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Fatal("Get(a) missed before eviction")
+	}
+	_ = c.Set(ctx, "c", []byte("3"), 0)
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("Get(b) hit, want it evicted as the least recently used value")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, key); !ok {
+			t.Errorf("Get(%s) missed, want it kept", key)
+		}
+	}
+}
+
+func TestLRUCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewLRUCache(10)
+	c.now = func() time.Time { return now }
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "forever", []byte("2"), 0)
+	now = now.Add(59 * time.Second)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Error("Get(a) missed before its ttl")
+	}
+	now = now.Add(time.Second)
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("Get(a) hit, want it expired after its ttl")
+	}
+	if _, ok, _ := c.Get(ctx, "forever"); !ok {
+		t.Error("Get(forever) missed, want the values without ttl kept")
+	}
+}
+
+func TestCachedQueryHitsCache(t *testing.T) {
+	h := &countingOrders{}
+	o := NewOptions(WithCache(NewLRUCache(10)))
+	q := DecorateQuery[*orderQuery, *orderResult](h, getOrderInfo, o)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		res, err := q.Handle(ctx, &orderQuery{ID: "1"})
+		if err != nil || res.ID != "1" || res.Calls != 1 {
+			t.Fatalf("Handle #%d = %+v, %v, want the first result", i, res, err)
+		}
+	}
+	if _, err := q.Handle(ctx, &orderQuery{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.calls.Load(); got != 2 {
+		t.Errorf("handler called %d times, want once per key", got)
+	}
+}
+
+func TestCachedQuerySharesConcurrentMisses(t *testing.T) {
+	h := &countingOrders{release: make(chan struct{})}
+	o := NewOptions(WithCache(NewLRUCache(10)))
+	q := DecorateQuery[*orderQuery, *orderResult](h, getOrderInfo, o)
+
+	// the first caller goes away while the others wait for its flight
+	first, cancel := context.WithCancel(context.Background())
+	const waiters = 5
+	var wg sync.WaitGroup
+	results := make([]*orderResult, waiters+1)
+	errs := make([]error, waiters+1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], errs[0] = q.Handle(first, &orderQuery{ID: "1"})
+	}()
+	waitFor(t, func() bool { return h.calls.Load() == 1 })
+	for i := 1; i <= waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = q.Handle(context.Background(), &orderQuery{ID: "1"})
+		}(i)
+	}
+	// let the waiters join the flight, the late ones get the cached result
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(h.release)
+	wg.Wait()
+
+	if got := h.calls.Load(); got != 1 {
+		t.Errorf("handler called %d times, want the concurrent misses to share one call", got)
+	}
+	for i := range results {
+		if errs[i] != nil || results[i] == nil || results[i].ID != "1" {
+			t.Errorf("caller #%d = %+v, %v, want the shared result despite the cancelled first caller", i, results[i], errs[i])
+		}
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup
+	entered, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan any)
+	go func() {
+		defer func() { panicked <- recover() }()
+		_, _ = g.do(context.Background(), "k", func() (any, error) {
+			close(entered)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-entered
+	waited := make(chan error)
+	go func() {
+		_, err := g.do(context.Background(), "k", func() (any, error) { return "not shared", nil })
+		waited <- err
+	}()
+	// let the second call join the flight
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if p := <-panicked; p != "boom" {
+		t.Errorf("first call panicked with %v, want boom", p)
+	}
+	if err := <-waited; !errors.Is(err, errFlightPanicked) {
+		t.Errorf("waiting call = %v, want %v", err, errFlightPanicked)
+	}
+	if res, err := g.do(context.Background(), "k", func() (any, error) { return "again", nil }); err != nil || res != "again" {
+		t.Errorf("call after the panic = %v, %v, want it run", res, err)
+	}
+}
+
+func TestFlightGroupWaiterContext(t *testing.T) {
+	var g flightGroup
+	entered, release := make(chan struct{}), make(chan struct{})
+	done := make(chan any)
+	go func() {
+		res, _ := g.do(context.Background(), "k", func() (any, error) {
+			close(entered)
+			<-release
+			return "shared", nil
+		})
+		done <- res
+	}()
+	<-entered
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.do(ctx, "k", func() (any, error) { return "not shared", nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting call = %v, want it to stop waiting once its ctx is done", err)
+	}
+	close(release)
+	if res := <-done; res != "shared" {
+		t.Errorf("first call = %v, want shared", res)
+	}
+}
+
+func TestInvalidatingCommandDeletesCachedResults(t *testing.T) {
+	h := &countingOrders{}
+	o := NewOptions(WithCache(NewLRUCache(10)))
+	q := DecorateQuery[*orderQuery, *orderResult](h, getOrderInfo, o)
+	failing := errors.New("failing")
+	var fail bool
+	info := Info{Service: "Orders", Endpoint: "CancelOrder", Kind: KindCommand, Invalidates: func(cmd any) []string {
+		return []string{CacheKey("Orders.GetOrder", cmd.(*cancelOrderCmd).ID)}
+	}}
+	c := DecorateCommand[*cancelOrderCmd](commandFunc[*cancelOrderCmd](func(ctx context.Context, cmd *cancelOrderCmd) error {
+		if fail {
+			return failing
+		}
+		return nil
+	}), info, o)
+	ctx := context.Background()
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := q.Handle(ctx, &orderQuery{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fail = true
+	if err := c.Handle(ctx, &cancelOrderCmd{ID: "1"}); !errors.Is(err, failing) {
+		t.Fatalf("Handle = %v, want %v", err, failing)
+	}
+	if res, _ := q.Handle(ctx, &orderQuery{ID: "1"}); res.Calls != 1 {
+		t.Errorf("result of 1 handled again after a failed command, want it kept cached")
+	}
+	fail = false
+	if err := c.Handle(ctx, &cancelOrderCmd{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := q.Handle(ctx, &orderQuery{ID: "1"}); res.Calls != 3 {
+		t.Errorf("result of 1 = call %d, want it handled again once invalidated", res.Calls)
+	}
+	if res, _ := q.Handle(ctx, &orderQuery{ID: "2"}); res.Calls != 2 {
+		t.Errorf("result of 2 = call %d, want it kept cached", res.Calls)
+	}
+}
+
+// waitFor waits for cond to hold, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
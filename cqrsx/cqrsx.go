@@ -0,0 +1,164 @@
+// Package cqrsx decorates the query and command handlers of the buses generated by gorsx with
+// cross-cutting behavior, such as tracing, metrics and logging.
+package cqrsx
+
+import (
+	"context"
+	"time"
+)
+
+// Kind is the kind of a handler, query or command.
+type Kind string
+
+const (
+	KindQuery   Kind = "query"
+	KindCommand Kind = "command"
+)
+
+// Info describes the handler of an endpoint of a service.
+type Info struct {
+	Service  string
+	Endpoint string
+	Kind     Kind
+	// LogPayload reports whether the query or command and the result are logged, declared by @Log.
+	LogPayload bool
+	// Redact names the fields of the payloads masked in the logs, declared by @Log(redact=...).
+	Redact []string
+	// Transactional reports whether the command runs in a transaction of the TxManager, declared by
+	// @Transactional.
+	Transactional bool
+	// Cache is the caching of the query, declared by @Cache.
+	Cache *CacheInfo
+	// Invalidates returns the keys of the cached results the command invalidates, declared by
+	// @Invalidate.
+	Invalidates func(cmd any) []string
+	// Idempotency is the idempotency of the command, declared by @Idempotent.
+	Idempotency *IdempotencyInfo
+	// Emits are the events the command publishes, declared by @Emits.
+	Emits []string
+}
+
+// Name returns the name of the endpoint, such as Keyword.ManualAudit.
+func (i Info) Name() string {
+	return i.Service + "." + i.Endpoint
+}
+
+// QueryHandler handles the query Q, it is a cqrs.QueryHandler.
+type QueryHandler[Q any, R any] interface {
+	Handle(ctx context.Context, q Q) (R, error)
+}
+
+// CommandHandler handles the command C, it is a cqrs.CommandHandler.
+type CommandHandler[C any] interface {
+	Handle(ctx context.Context, cmd C) error
+}
+
+// Handler handles a query or a command. The result of a command is nil.
+type Handler func(ctx context.Context, req any) (any, error)
+
+// Decorator wraps the handler of the endpoint described by info.
+type Decorator func(info Info, next Handler) Handler
+
+// Options configures the decorators of the handlers of a bus.
+type Options struct {
+	// Decorators wrap every handler, the first one outermost.
+	Decorators []Decorator
+	// TxManager runs the @Transactional commands in transactions.
+	TxManager TxManager
+	// Cache caches the results of the @Cache queries.
+	Cache Cache
+	// IdempotencyStore records the idempotency keys of the @Idempotent commands.
+	IdempotencyStore IdempotencyStore
+	// IdempotencyLease is how long the idempotency key of a request in flight is held.
+	IdempotencyLease time.Duration
+	// EventErrorHandler handles the errors of the event handlers of the @Emits commands.
+	EventErrorHandler func(err error)
+	flights           flightGroup
+}
+
+type Option func(o *Options)
+
+// WithDecorator appends decorators wrapping every handler.
+func WithDecorator(decorators ...Decorator) Option {
+	return func(o *Options) {
+		for _, d := range decorators {
+			if d != nil {
+				o.Decorators = append(o.Decorators, d)
+			}
+		}
+	}
+}
+
+func NewOptions(opts ...Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// decorate wraps handle with the decorators of o, the first one outermost. The transaction of a
+// @Transactional command is innermost, so that the events are dispatched, the cache is invalidated,
+// the idempotency outcome is recorded and the decorators observe once it is committed.
+func (o *Options) decorate(info Info, handle Handler) Handler {
+	if info.Transactional {
+		handle = o.transactional(info, handle)
+	}
+	if len(info.Emits) > 0 {
+		handle = o.emitting(info, handle)
+	}
+	if info.Invalidates != nil && o.Cache != nil {
+		handle = o.invalidating(info, handle)
+	}
+	if info.Idempotency != nil {
+		handle = o.idempotent(info, handle)
+	}
+	for i := len(o.Decorators) - 1; i >= 0; i-- {
+		handle = o.Decorators[i](info, handle)
+	}
+	return handle
+}
+
+// DecorateQuery returns h wrapped with the cache and the decorators of o. A nil h is returned as it is.
+func DecorateQuery[Q any, R any](h QueryHandler[Q, R], info Info, o *Options) QueryHandler[Q, R] {
+	if h == nil {
+		return h
+	}
+	handle := Handler(func(ctx context.Context, req any) (any, error) {
+		return h.Handle(ctx, req.(Q))
+	})
+	if info.Cache != nil && o.Cache != nil {
+		handle = cached[R](o, info, handle)
+	}
+	return queryHandler[Q, R]{handle: o.decorate(info, handle)}
+}
+
+// DecorateCommand returns h wrapped with its transaction, the dispatch of its events, the cache
+// invalidation, the idempotency and the decorators of o. A nil h is returned as it is.
+func DecorateCommand[C any](h CommandHandler[C], info Info, o *Options) CommandHandler[C] {
+	if h == nil {
+		return h
+	}
+	return commandHandler[C]{handle: o.decorate(info, func(ctx context.Context, req any) (any, error) {
+		return nil, h.Handle(ctx, req.(C))
+	})}
+}
+
+type queryHandler[Q any, R any] struct {
+	handle Handler
+}
+
+func (h queryHandler[Q, R]) Handle(ctx context.Context, q Q) (R, error) {
+	res, err := h.handle(ctx, q)
+	r, _ := res.(R)
+	return r, err
+}
+
+type commandHandler[C any] struct {
+	handle Handler
+}
+
+func (h commandHandler[C]) Handle(ctx context.Context, cmd C) error {
+	_, err := h.handle(ctx, cmd)
+	return err
+}
@@ -0,0 +1,168 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+// EventPublisher publishes the events emitted by the commands, such as the EventBus. It is injected into
+// the handlers of the @Emits commands.
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...any) error
+}
+
+// EventBus is an in-process EventPublisher, dispatching the events to the handlers subscribed to their
+// types with Subscribe.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[reflect.Type][]func(ctx context.Context, event any) error
+}
+
+var _ EventPublisher = (*EventBus)(nil)
+
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[reflect.Type][]func(ctx context.Context, event any) error)}
+}
+
+// Subscribe registers handler for the events of type E published on bus, such as
+// cqrsx.Subscribe[app.OrderCreated](bus, handler). The events are matched by their type name, as
+// EventType does, so that handler receives both the events published as values and as pointers.
+func Subscribe[E any](bus *EventBus, handler func(ctx context.Context, event E) error) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	t := reflect.TypeOf((*E)(nil)).Elem()
+	key := eventKey(t)
+	bus.handlers[key] = append(bus.handlers[key], func(ctx context.Context, event any) error {
+		e, ok := event.(E)
+		if !ok {
+			if e, ok = convertEvent(event, t).(E); !ok {
+				// a nil pointer has no value to pass as E
+				return nil
+			}
+		}
+		return handler(ctx, e)
+	})
+}
+
+// eventKey returns the type the handlers of the events of t are registered by, t without pointers.
+func eventKey(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// convertEvent returns event as a value of t, a pointer to its value when t is a pointer type, or
+// nil when event is a nil pointer.
+func convertEvent(event any, t reflect.Type) any {
+	v := reflect.ValueOf(event)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if t.Kind() != reflect.Pointer {
+		return v.Interface()
+	}
+	p := reflect.New(t.Elem())
+	p.Elem().Set(v)
+	return p.Interface()
+}
+
+// Publish dispatches events to their handlers, in the order they are subscribed. The events published
+// by the handler of an @Emits command are dispatched once the command, and its transaction, succeeds,
+// and dropped when it fails, their errors reported to the EventErrorHandler of the command. The errors
+// of the handlers are joined otherwise.
+func (b *EventBus) Publish(ctx context.Context, events ...any) error {
+	if pending, ok := ctx.Value(pendingEventsKey{}).(*pendingEvents); ok {
+		pending.add(func(ctx context.Context) error { return b.dispatch(ctx, events) })
+		return nil
+	}
+	return b.dispatch(ctx, events)
+}
+
+func (b *EventBus) dispatch(ctx context.Context, events []any) error {
+	var errs []error
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		b.mu.RLock()
+		handlers := b.handlers[eventKey(reflect.TypeOf(event))]
+		b.mu.RUnlock()
+		for _, handler := range handlers {
+			if err := handler(ctx, event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type pendingEventsKey struct{}
+
+// pendingEvents are the dispatches of the events published by a command, deferred until it succeeds.
+type pendingEvents struct {
+	mu         sync.Mutex
+	dispatches []func(ctx context.Context) error
+}
+
+func (p *pendingEvents) add(dispatch func(ctx context.Context) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dispatches = append(p.dispatches, dispatch)
+}
+
+func (p *pendingEvents) dispatch(ctx context.Context) error {
+	p.mu.Lock()
+	dispatches := p.dispatches
+	p.dispatches = nil
+	p.mu.Unlock()
+	var errs []error
+	for _, dispatch := range dispatches {
+		if err := dispatch(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithEventErrorHandler sets the handler of the errors of the event handlers dispatched once an @Emits
+// command succeeds, which are logged with slog by default. They do not fail the command, whose effects
+// are done.
+func WithEventErrorHandler(h func(err error)) Option {
+	return func(o *Options) {
+		o.EventErrorHandler = h
+	}
+}
+
+// emitting wraps the handler of an @Emits command, deferring the dispatch of the events it publishes
+// until it succeeds. A command handled within another one joins its events, dispatched once the outer
+// one succeeds. The errors of the event handlers are reported to the EventErrorHandler.
+func (o *Options) emitting(info Info, next Handler) Handler {
+	onError := o.EventErrorHandler
+	return func(ctx context.Context, req any) (any, error) {
+		if _, ok := ctx.Value(pendingEventsKey{}).(*pendingEvents); ok {
+			return next(ctx, req)
+		}
+		pending := &pendingEvents{}
+		res, err := next(context.WithValue(ctx, pendingEventsKey{}, pending), req)
+		if err != nil {
+			return res, err
+		}
+		if err := pending.dispatch(ctx); err != nil {
+			err = fmt.Errorf("cqrsx: dispatch events of %s: %w", info.Name(), err)
+			if onError == nil {
+				slog.ErrorContext(ctx, err.Error())
+			} else {
+				onError(err)
+			}
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,147 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type OrderPlaced struct {
+	ID string
+}
+
+type OrderCanceled struct {
+	ID string
+}
+
+func TestEventBusDispatchesInSubscriptionOrder(t *testing.T) {
+	bus := NewEventBus()
+	var got []string
+	Subscribe[*OrderPlaced](bus, func(ctx context.Context, event *OrderPlaced) error {
+		got = append(got, "first "+event.ID)
+		return nil
+	})
+	Subscribe[*OrderPlaced](bus, func(ctx context.Context, event *OrderPlaced) error {
+		got = append(got, "second "+event.ID)
+		return errors.New("second failed")
+	})
+	Subscribe[OrderCanceled](bus, func(ctx context.Context, event OrderCanceled) error {
+		got = append(got, "canceled "+event.ID)
+		return errors.New("canceled failed")
+	})
+
+	// outside of a command, the events are dispatched right away, published as values or pointers
+	err := bus.Publish(context.Background(), &OrderPlaced{ID: "1"}, &OrderCanceled{ID: "2"}, OrderPlaced{ID: "3"})
+	want := []string{"first 1", "second 1", "canceled 2", "first 3", "second 3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched %v, want %v", got, want)
+	}
+	if err == nil || err.Error() != "second failed\ncanceled failed\nsecond failed" {
+		t.Errorf("Publish = %v, want the errors of the handlers joined", err)
+	}
+}
+
+func TestEmittedEventsDeferredUntilSuccess(t *testing.T) {
+	db := openSQLite(t, ordersSchema)
+	bus := NewEventBus()
+	// the orders counted when the events are dispatched
+	var dispatched []int
+	Subscribe[*OrderPlaced](bus, func(ctx context.Context, event *OrderPlaced) error {
+		dispatched = append(dispatched, countOrders(t, db))
+		return nil
+	})
+	info := Info{Service: "Orders", Endpoint: "Place", Kind: KindCommand, Transactional: true, Emits: []string{"OrderPlaced"}}
+	var committed int
+	c := DecorateCommand[*placeOrderCmd](commandFunc[*placeOrderCmd](func(ctx context.Context, cmd *placeOrderCmd) error {
+		if err := bus.Publish(ctx, &OrderPlaced{ID: cmd.ID}); err != nil {
+			return err
+		}
+		if len(dispatched) != committed {
+			t.Errorf("the event of %s is dispatched before the command succeeds", cmd.ID)
+		}
+		return placeOrder(ctx, cmd)
+	}), info, NewOptions(WithTxManager(NewSQLTxManager(db, nil))))
+	ctx := context.Background()
+
+	if err := c.Handle(ctx, &placeOrderCmd{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(dispatched) != 1 || dispatched[0] != 1 {
+		t.Fatalf("dispatched with %v orders, want the event dispatched once the order is committed", dispatched)
+	}
+	committed++
+
+	failing := errors.New("failing")
+	if err := c.Handle(ctx, &placeOrderCmd{ID: "2", Fail: failing}); !errors.Is(err, failing) {
+		t.Fatalf("Handle = %v, want %v", err, failing)
+	}
+	if len(dispatched) != 1 {
+		t.Errorf("dispatched %d events, want the event of the failed command dropped", len(dispatched))
+	}
+}
+
+func TestEmittedEventsOfNestedCommands(t *testing.T) {
+	bus := NewEventBus()
+	var got []string
+	Subscribe[*OrderPlaced](bus, func(ctx context.Context, event *OrderPlaced) error {
+		got = append(got, event.ID)
+		return nil
+	})
+	errHandler := errors.New("handler failed")
+	Subscribe[*OrderCanceled](bus, func(ctx context.Context, event *OrderCanceled) error {
+		return errHandler
+	})
+	var reported []error
+	o := NewOptions(WithEventErrorHandler(func(err error) { reported = append(reported, err) }))
+	inner := DecorateCommand[string](commandFunc[string](func(ctx context.Context, id string) error {
+		return bus.Publish(ctx, &OrderPlaced{ID: id})
+	}), Info{Service: "Orders", Endpoint: "Place", Kind: KindCommand, Emits: []string{"OrderPlaced"}}, o)
+	outer := DecorateCommand[[]string](commandFunc[[]string](func(ctx context.Context, ids []string) error {
+		for _, id := range ids {
+			if id == "fail" {
+				return errors.New("failing")
+			}
+			if id == "cancel" {
+				if err := bus.Publish(ctx, &OrderCanceled{ID: id}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := inner.Handle(ctx, id); err != nil {
+				return err
+			}
+			if len(got) != 0 {
+				t.Errorf("dispatched %v before the outer command succeeds", got)
+			}
+		}
+		return nil
+	}), Info{Service: "Orders", Endpoint: "PlaceAll", Kind: KindCommand, Emits: []string{"OrderPlaced"}}, o)
+	ctx := context.Background()
+
+	if err := outer.Handle(ctx, []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched %v, want the events of the inner commands once the outer one succeeds", got)
+	}
+
+	got = nil
+	if err := outer.Handle(ctx, []string{"3", "fail"}); err == nil {
+		t.Fatal("Handle = nil, want the error of the outer command")
+	}
+	if len(got) != 0 {
+		t.Errorf("dispatched %v, want the events of the inner commands dropped with the outer one", got)
+	}
+
+	// the errors of the handlers are reported rather than failing the command, its effects already done
+	if err := outer.Handle(ctx, []string{"4", "cancel"}); err != nil {
+		t.Errorf("Handle = %v, want nil", err)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], errHandler) {
+		t.Errorf("reported %v, want the error of the event handler", reported)
+	}
+	if want := []string{"4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched %v, want %v", got, want)
+	}
+}
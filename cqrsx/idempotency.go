@@ -0,0 +1,316 @@
+package cqrsx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-miya/gorsx/gors"
+)
+
+// ErrIdempotencyInFlight is returned for a request whose idempotency key is the one of a request in
+// flight. Handlers respond it as 409 Conflict.
+var ErrIdempotencyInFlight = gors.NewError(http.StatusConflict, "idempotency_in_flight", "a request with the same idempotency key is in flight")
+
+// IdempotencyStore records the idempotency keys of the @Idempotent commands and their outcomes.
+type IdempotencyStore interface {
+	// Acquire records key as in flight, expiring after ttl, the lease of its request, and reports
+	// whether it did. When key is recorded already, it returns the outcome of its request, which is nil
+	// while it is in flight.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (acquired bool, outcome []byte, err error)
+	// Complete records the outcome of the request of key, expiring after ttl.
+	Complete(ctx context.Context, key string, outcome []byte, ttl time.Duration) error
+	// Release deletes key, so that its request may be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyInfo is the idempotency of a command, declared by @Idempotent(header=Idempotency-Key,ttl=24h).
+type IdempotencyInfo struct {
+	// TTL is how long the outcome of a request is replayed.
+	TTL time.Duration
+}
+
+// WithIdempotencyStore records the idempotency keys of the @Idempotent commands in s.
+func WithIdempotencyStore(s IdempotencyStore) Option {
+	return func(o *Options) {
+		o.IdempotencyStore = s
+	}
+}
+
+// DefaultIdempotencyLease is how long the idempotency key of a request in flight is held by default.
+const DefaultIdempotencyLease = time.Minute
+
+// WithIdempotencyLease sets how long the idempotency key of a request in flight is held, so that the
+// key of a request lost with its process is released after lease rather than the TTL of its outcome.
+// The requests taking longer than lease may run twice, set it beyond the longest command.
+func WithIdempotencyLease(lease time.Duration) Option {
+	return func(o *Options) {
+		o.IdempotencyLease = lease
+	}
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx carrying the idempotency key of a request, which the
+// handlers of the @Idempotent methods take from the header of the request.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKey returns the idempotency key carried by ctx.
+func IdempotencyKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	return key, ok && key != ""
+}
+
+// outcome is the recorded outcome of a request, an Error or a success.
+type outcome struct {
+	StatusCode int    `json:"status_code,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// Details are the details of the Error encoded in JSON, which are replayed as they are, so that the
+	// response body is the one of the request.
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// newOutcome returns the outcome of the Error e.
+func newOutcome(e *gors.Error) (outcome, error) {
+	out := outcome{StatusCode: e.StatusCode, Code: e.Code, Message: e.Message}
+	for _, detail := range e.Details {
+		raw, err := json.Marshal(detail)
+		if err != nil {
+			return out, err
+		}
+		out.Details = append(out.Details, raw)
+	}
+	return out, nil
+}
+
+// err returns the Error of the outcome, nil for a success.
+func (out outcome) err() error {
+	if out.StatusCode == 0 {
+		return nil
+	}
+	e := gors.NewError(out.StatusCode, out.Code, out.Message)
+	for _, detail := range out.Details {
+		e.Details = append(e.Details, detail)
+	}
+	return e
+}
+
+// idempotent wraps the handler of an @Idempotent command. A request carrying the key of a completed
+// request replays its outcome, the success or the client error with its details, rather than running
+// the command again, and the one of a request in flight fails with ErrIdempotencyInFlight. The key of
+// a request in flight is leased, and its outcome recorded for the TTL once it completes. Successes and
+// client errors are recorded, the other errors release the key, so that the request may be retried.
+// It panics when no IdempotencyStore is configured.
+func (o *Options) idempotent(info Info, next Handler) Handler {
+	if o.IdempotencyStore == nil {
+		panic(fmt.Sprintf("cqrsx: %s is @Idempotent, configure an IdempotencyStore with cqrsx.WithIdempotencyStore", info.Name()))
+	}
+	store, ttl := o.IdempotencyStore, info.Idempotency.TTL
+	lease := o.IdempotencyLease
+	if lease <= 0 {
+		lease = DefaultIdempotencyLease
+	}
+	lease = min(lease, ttl)
+	return func(ctx context.Context, req any) (any, error) {
+		key, ok := IdempotencyKey(ctx)
+		if !ok {
+			return next(ctx, req)
+		}
+		key = info.Name() + ":" + key
+		acquired, recorded, err := store.Acquire(ctx, key, lease)
+		if err != nil {
+			return nil, err
+		}
+		if !acquired {
+			if recorded == nil {
+				return nil, ErrIdempotencyInFlight
+			}
+			var out outcome
+			if err := json.Unmarshal(recorded, &out); err != nil {
+				return nil, fmt.Errorf("cqrsx: decode outcome of idempotency key: %w", err)
+			}
+			return nil, out.err()
+		}
+		res, err := next(ctx, req)
+		var out outcome
+		var e *gors.Error
+		switch {
+		case err == nil:
+		case errors.As(err, &e) && e.StatusCode < http.StatusInternalServerError:
+			var oErr error
+			if out, oErr = newOutcome(e); oErr != nil {
+				// an outcome that cannot be replayed is not recorded
+				_ = store.Release(ctx, key)
+				return res, err
+			}
+		default:
+			_ = store.Release(ctx, key)
+			return res, err
+		}
+		recorded, _ = json.Marshal(out)
+		if cErr := store.Complete(ctx, key, recorded, ttl); cErr != nil {
+			return res, errors.Join(err, cErr)
+		}
+		return res, err
+	}
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore, for a single process.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotencyRecord
+	now     func() time.Time
+}
+
+type idempotencyRecord struct {
+	outcome  []byte
+	expireAt time.Time
+}
+
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*idempotencyRecord), now: time.Now}
+}
+
+func (s *MemoryIdempotencyStore) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if record, ok := s.records[key]; ok && now.Before(record.expireAt) {
+		return false, record.outcome, nil
+	}
+	s.records[key] = &idempotencyRecord{expireAt: now.Add(ttl)}
+	return true, nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, outcome []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = &idempotencyRecord{outcome: outcome, expireAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// SQLIdempotencyStore is an IdempotencyStore of a database/sql table, shared by the processes of a
+// service. The table is created by:
+//
+//	CREATE TABLE idempotency_keys (
+//		idempotency_key VARCHAR(255) PRIMARY KEY,
+//		outcome         BLOB,
+//		expires_at      BIGINT NOT NULL
+//	)
+//
+// where outcome is NULL while the request is in flight, and expires_at is in Unix milliseconds.
+type SQLIdempotencyStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+	now         func() time.Time
+}
+
+var _ IdempotencyStore = (*SQLIdempotencyStore)(nil)
+
+// SQLStoreOption configures the tables of the database/sql stores.
+type SQLStoreOption func(s *sqlStoreOptions)
+
+type sqlStoreOptions struct {
+	table       string
+	placeholder func(n int) string
+	lease       time.Duration
+}
+
+// WithTable sets the name of the table of the store.
+func WithTable(table string) SQLStoreOption {
+	return func(o *sqlStoreOptions) {
+		o.table = table
+	}
+}
+
+// WithDollarPlaceholders numbers the placeholders of the statements, $1, $2, ..., as PostgreSQL does,
+// rather than using ?.
+func WithDollarPlaceholders() SQLStoreOption {
+	return func(o *sqlStoreOptions) {
+		o.placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+	}
+}
+
+// WithLease sets how long a SQLCommandQueue leases a running job to its worker, DefaultJobLease by
+// default.
+func WithLease(lease time.Duration) SQLStoreOption {
+	return func(o *sqlStoreOptions) {
+		o.lease = lease
+	}
+}
+
+func newSQLStoreOptions(table string, opts []SQLStoreOption) *sqlStoreOptions {
+	o := &sqlStoreOptions{table: table, placeholder: func(int) string { return "?" }}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// NewSQLIdempotencyStore returns a store of the idempotency_keys table of db.
+func NewSQLIdempotencyStore(db *sql.DB, opts ...SQLStoreOption) *SQLIdempotencyStore {
+	o := newSQLStoreOptions("idempotency_keys", opts)
+	return &SQLIdempotencyStore{db: db, table: o.table, placeholder: o.placeholder, now: time.Now}
+}
+
+func (s *SQLIdempotencyStore) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, []byte, error) {
+	p := s.placeholder
+	now := s.now()
+	if _, err := s.db.ExecContext(ctx,
+		"DELETE FROM "+s.table+" WHERE idempotency_key = "+p(1)+" AND expires_at <= "+p(2),
+		key, now.UnixMilli()); err != nil {
+		return false, nil, fmt.Errorf("cqrsx: delete expired idempotency key: %w", err)
+	}
+	_, insertErr := s.db.ExecContext(ctx,
+		"INSERT INTO "+s.table+" (idempotency_key, expires_at) VALUES ("+p(1)+", "+p(2)+")",
+		key, now.Add(ttl).UnixMilli())
+	if insertErr == nil {
+		return true, nil, nil
+	}
+	// the key is recorded already, unless the insert failed for another reason
+	var outcome []byte
+	err := s.db.QueryRowContext(ctx, "SELECT outcome FROM "+s.table+" WHERE idempotency_key = "+p(1), key).Scan(&outcome)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil, fmt.Errorf("cqrsx: insert idempotency key: %w", insertErr)
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("cqrsx: select idempotency key: %w", err)
+	}
+	return false, outcome, nil
+}
+
+func (s *SQLIdempotencyStore) Complete(ctx context.Context, key string, outcome []byte, ttl time.Duration) error {
+	p := s.placeholder
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE "+s.table+" SET outcome = "+p(1)+", expires_at = "+p(2)+" WHERE idempotency_key = "+p(3),
+		outcome, s.now().Add(ttl).UnixMilli(), key); err != nil {
+		return fmt.Errorf("cqrsx: complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLIdempotencyStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM "+s.table+" WHERE idempotency_key = "+s.placeholder(1), key); err != nil {
+		return fmt.Errorf("cqrsx: release idempotency key: %w", err)
+	}
+	return nil
+}
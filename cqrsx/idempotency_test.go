@@ -0,0 +1,133 @@
+package cqrsx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-miya/gorsx/gors"
+)
+
+const idempotencyKeysSchema = `CREATE TABLE idempotency_keys (
+	idempotency_key VARCHAR(255) PRIMARY KEY,
+	outcome         BLOB,
+	expires_at      BIGINT NOT NULL
+)`
+
+type createOrderCmd struct {
+	Fail error
+	// Block blocks the command until it is closed.
+	Block chan struct{}
+	// Crash panics in the command, as if its process crashed.
+	Crash bool
+}
+
+// testIdempotency checks the outcomes of the @Idempotent command replayed with store, whose clock is
+// set by setNow.
+func testIdempotency(t *testing.T, store IdempotencyStore, setNow func(now time.Time)) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	setNow(now)
+	var calls atomic.Int32
+	h := commandFunc[*createOrderCmd](func(ctx context.Context, cmd *createOrderCmd) error {
+		calls.Add(1)
+		if cmd.Block != nil {
+			<-cmd.Block
+		}
+		if cmd.Crash {
+			panic("crash")
+		}
+		return cmd.Fail
+	})
+	info := Info{Service: "Orders", Endpoint: "Create", Kind: KindCommand, Idempotency: &IdempotencyInfo{TTL: 24 * time.Hour}}
+	c := DecorateCommand[*createOrderCmd](h, info, NewOptions(WithIdempotencyStore(store), WithIdempotencyLease(time.Minute)))
+	ctx := WithIdempotencyKey(context.Background(), "k1")
+
+	// a duplicate of the request in flight conflicts
+	block := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- c.Handle(ctx, &createOrderCmd{Block: block}) }()
+	waitFor(t, func() bool { return calls.Load() == 1 })
+	if err := c.Handle(ctx, &createOrderCmd{}); !errors.Is(err, ErrIdempotencyInFlight) {
+		t.Fatalf("duplicate in flight = %v, want %v", err, ErrIdempotencyInFlight)
+	}
+	close(block)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// a duplicate of the completed request replays its outcome within the TTL
+	now = now.Add(23 * time.Hour)
+	setNow(now)
+	if err := c.Handle(ctx, &createOrderCmd{Fail: errors.New("not run")}); err != nil {
+		t.Fatalf("duplicate = %v, want the success replayed", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("command run %d times, want once", calls.Load())
+	}
+	now = now.Add(time.Hour)
+	setNow(now)
+	if err := c.Handle(ctx, &createOrderCmd{}); err != nil || calls.Load() != 2 {
+		t.Fatalf("request after the TTL = %v, run %d times, want it run again", err, calls.Load())
+	}
+
+	// client errors are replayed, the other errors release the key
+	conflict := gors.NewError(http.StatusConflict, "duplicate_order", "duplicate order").
+		WithDetails(gors.FieldViolation{Field: "order_id", Source: "json", Description: "already placed"})
+	ctx = WithIdempotencyKey(context.Background(), "k2")
+	if err := c.Handle(ctx, &createOrderCmd{Fail: conflict}); !errors.Is(err, conflict) {
+		t.Fatal(err)
+	}
+	var e *gors.Error
+	if err := c.Handle(ctx, &createOrderCmd{}); !errors.As(err, &e) || e.StatusCode != http.StatusConflict || e.Code != "duplicate_order" || calls.Load() != 3 {
+		t.Fatalf("duplicate of a client error = %v, run %d times, want the error replayed", err, calls.Load())
+	}
+	// the body of the replayed error is the one of the request
+	if got, want := mustJSON(t, e.Details), mustJSON(t, conflict.Details); got != want {
+		t.Errorf("replayed details = %s, want %s", got, want)
+	}
+	ctx = WithIdempotencyKey(context.Background(), "k3")
+	if err := c.Handle(ctx, &createOrderCmd{Fail: errors.New("database down")}); err == nil {
+		t.Fatal("want the error of the command")
+	}
+	if err := c.Handle(ctx, &createOrderCmd{}); err != nil || calls.Load() != 5 {
+		t.Fatalf("retry of a server error = %v, run %d times, want it run again", err, calls.Load())
+	}
+
+	// the key of a request lost with its process is released after the lease, not the TTL
+	ctx = WithIdempotencyKey(context.Background(), "k4")
+	func() {
+		defer func() { _ = recover() }()
+		_ = c.Handle(ctx, &createOrderCmd{Crash: true})
+	}()
+	if err := c.Handle(ctx, &createOrderCmd{}); !errors.Is(err, ErrIdempotencyInFlight) {
+		t.Fatalf("request within the lease = %v, want %v", err, ErrIdempotencyInFlight)
+	}
+	now = now.Add(time.Minute)
+	setNow(now)
+	if err := c.Handle(ctx, &createOrderCmd{}); err != nil || calls.Load() != 7 {
+		t.Fatalf("request after the lease = %v, run %d times, want it run", err, calls.Load())
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	testIdempotency(t, store, func(now time.Time) { store.now = func() time.Time { return now } })
+}
+
+func TestSQLIdempotencyStore(t *testing.T) {
+	store := NewSQLIdempotencyStore(openSQLite(t, idempotencyKeysSchema))
+	testIdempotency(t, store, func(now time.Time) { store.now = func() time.Time { return now } })
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
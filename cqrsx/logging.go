@@ -0,0 +1,214 @@
+package cqrsx
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of the redacted fields in the logs.
+const redacted = "[REDACTED]"
+
+type logConfig struct {
+	level      slog.Level
+	errorLevel slog.Level
+}
+
+// LogOption configures the Logging decorator.
+type LogOption func(c *logConfig)
+
+// LogLevel sets the level of the handlings that succeed, slog.LevelInfo by default.
+func LogLevel(level slog.Level) LogOption {
+	return func(c *logConfig) {
+		c.level = level
+	}
+}
+
+// LogErrorLevel sets the level of the handlings that fail, slog.LevelError by default.
+func LogErrorLevel(level slog.Level) LogOption {
+	return func(c *logConfig) {
+		c.errorLevel = level
+	}
+}
+
+// WithLogger logs every handling with logger, see Logging. Nothing is logged when logger is nil.
+func WithLogger(logger *slog.Logger, opts ...LogOption) Option {
+	if logger == nil {
+		return func(o *Options) {}
+	}
+	return WithDecorator(Logging(logger, opts...))
+}
+
+// Logging returns a decorator logging the service, endpoint, kind, duration and error of every
+// handling. The query or command and the result are logged too for the endpoints annotated by @Log,
+// with the fields named by @Log(redact=...) masked.
+func Logging(logger *slog.Logger, opts ...LogOption) Decorator {
+	c := &logConfig{level: slog.LevelInfo, errorLevel: slog.LevelError}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return func(info Info, next Handler) Handler {
+		return func(ctx context.Context, req any) (any, error) {
+			start := time.Now()
+			res, err := next(ctx, req)
+			level := c.level
+			if err != nil {
+				level = c.errorLevel
+			}
+			if !logger.Enabled(ctx, level) {
+				return res, err
+			}
+			attrs := []slog.Attr{
+				slog.String("service", info.Service),
+				slog.String("endpoint", info.Endpoint),
+				slog.String("kind", string(info.Kind)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if info.LogPayload {
+				attrs = append(attrs, slog.Attr{Key: string(info.Kind), Value: redactValue(req, info.Redact)})
+				if err == nil && info.Kind == KindQuery {
+					attrs = append(attrs, slog.Attr{Key: "result", Value: redactValue(res, info.Redact)})
+				}
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			logger.LogAttrs(ctx, level, "cqrs "+info.Name(), attrs...)
+			return res, err
+		}
+	}
+}
+
+// redactDepth bounds the nesting redactValue walks, so that cyclic values are logged.
+const redactDepth = 8
+
+// redactValue returns the value of v to log. The fields of a struct whose Go or json name is one of
+// redact are masked, in nested structs too, including through pointers, slices, arrays and maps, and
+// so are the values of the map keys named by redact. A value logging itself, such as a fmt.Stringer,
+// is logged field by field when it may hold a redacted field.
+func redactValue(v any, redact []string) slog.Value {
+	if len(redact) == 0 {
+		return slog.AnyValue(v)
+	}
+	return redactReflect(reflect.ValueOf(v), redact, redactDepth)
+}
+
+func redactReflect(rv reflect.Value, redact []string, depth int) slog.Value {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return slog.AnyValue(nil)
+	}
+	if depth == 0 || !rv.CanInterface() || isLogLeaf(rv, redact) {
+		return slog.AnyValue(rv.Interface())
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		rt := rv.Type()
+		attrs := make([]slog.Attr, 0, rt.NumField())
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if isRedacted(field, redact) {
+				attrs = append(attrs, slog.String(field.Name, redacted))
+				continue
+			}
+			attrs = append(attrs, slog.Attr{Key: field.Name, Value: redactReflect(rv.Field(i), redact, depth-1)})
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		attrs := make([]slog.Attr, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			attrs = append(attrs, slog.Attr{Key: strconv.Itoa(i), Value: redactReflect(rv.Index(i), redact, depth-1)})
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Map:
+		if rv.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		attrs := make([]slog.Attr, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if isRedactedName(key, redact) {
+				attrs = append(attrs, slog.String(key, redacted))
+				continue
+			}
+			attrs = append(attrs, slog.Attr{Key: key, Value: redactReflect(iter.Value(), redact, depth-1)})
+		}
+		slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+		return slog.GroupValue(attrs...)
+	}
+	return slog.AnyValue(rv.Interface())
+}
+
+// isLogLeaf reports whether rv is logged as it is, such as a time.Time or a value logging itself
+// without any field of redact, rather than field by field.
+func isLogLeaf(rv reflect.Value, redact []string) bool {
+	switch rv.Interface().(type) {
+	case slog.LogValuer, json.Marshaler, encoding.TextMarshaler, fmt.Stringer:
+		return !mayHoldRedacted(rv.Type(), redact, make(map[reflect.Type]bool))
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Map:
+		return false
+	case reflect.Slice:
+		// []byte is logged as it is
+		return rv.Type().Elem().Kind() == reflect.Uint8
+	}
+	return true
+}
+
+// mayHoldRedacted reports whether the values of t may hold a field of redact, through their exported
+// fields, elements, or the values of maps and interfaces, which may hold anything.
+func mayHoldRedacted(t reflect.Type, redact []string, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return mayHoldRedacted(t.Elem(), redact, seen)
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.IsExported() && (isRedacted(field, redact) || mayHoldRedacted(field.Type, redact, seen)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isRedacted(field reflect.StructField, redact []string) bool {
+	jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return isRedactedName(field.Name, redact) || (jsonName != "" && slices.Contains(redact, jsonName))
+}
+
+// isRedactedName reports whether name, of a field or a map key, is one of redact, ignoring case.
+func isRedactedName(name string, redact []string) bool {
+	for _, r := range redact {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package cqrsx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type loggedAddress struct {
+	Street string
+	Token  string `json:"token"`
+}
+
+// loggedSession, loggedKey and loggedUser log themselves with their redacted fields.
+type loggedSession struct {
+	Token string
+}
+
+func (s loggedSession) String() string { return "session " + s.Token }
+
+type loggedKey struct {
+	Password string
+}
+
+func (k *loggedKey) MarshalJSON() ([]byte, error) { return json.Marshal(k.Password) }
+
+type loggedUser struct {
+	Token string
+}
+
+func (u loggedUser) LogValue() slog.Value { return slog.StringValue(u.Token) }
+
+// loggedID logs itself without any redacted field.
+type loggedID string
+
+func (id loggedID) String() string { return "id-" + string(id) }
+
+type loggedCmd struct {
+	Name     string
+	Password string
+	At       time.Time
+	Home     *loggedAddress
+	Previous []loggedAddress
+	Labels   map[string]string
+	Session  loggedSession
+	Key      *loggedKey
+	User     loggedUser
+	ID       loggedID
+	Extra    map[string]any
+}
+
+func TestLoggingRedactsNestedFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	info := Info{Service: "Users", Endpoint: "Create", Kind: KindCommand, LogPayload: true, Redact: []string{"password", "token"}}
+	handler := Logging(logger)(info, func(ctx context.Context, req any) (any, error) { return nil, nil })
+	cmd := &loggedCmd{
+		Name:     "amy",
+		Password: "secret",
+		At:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Home:     &loggedAddress{Street: "Main", Token: "t1"},
+		Previous: []loggedAddress{{Street: "Old", Token: "t2"}},
+		Labels:   map[string]string{"team": "a"},
+		Session:  loggedSession{Token: "t3"},
+		Key:      &loggedKey{Password: "k1"},
+		User:     loggedUser{Token: "u1"},
+		ID:       "7",
+		Extra:    map[string]any{"Password": "p1", "home": loggedAddress{Street: "Side", Token: "t4"}},
+	}
+	if _, err := handler(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	var record struct {
+		Command struct {
+			Name     string
+			Password string
+			At       time.Time
+			Home     loggedAddress
+			Previous map[string]loggedAddress
+			Labels   map[string]string
+			ID       string
+			Extra    struct {
+				Password string
+				Home     loggedAddress `json:"home"`
+			}
+		} `json:"command"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.String(), err)
+	}
+	got := record.Command
+	if got.Name != "amy" || got.Password != redacted {
+		t.Errorf("top-level fields = %q %q, want amy %s", got.Name, got.Password, redacted)
+	}
+	if !got.At.Equal(cmd.At) {
+		t.Errorf("At = %v, want %v", got.At, cmd.At)
+	}
+	if got.Home.Street != "Main" || got.Home.Token != redacted {
+		t.Errorf("Home = %+v, want its Token redacted", got.Home)
+	}
+	if prev := got.Previous["0"]; prev.Street != "Old" || prev.Token != redacted {
+		t.Errorf("Previous = %+v, want the Token of its elements redacted", got.Previous)
+	}
+	if got.Labels["team"] != "a" {
+		t.Errorf("Labels = %v, want them logged as is", got.Labels)
+	}
+	if got.ID != "7" {
+		t.Errorf("ID = %q, want it logged as is", got.ID)
+	}
+	if got.Extra.Password != redacted || got.Extra.Home.Street != "Side" || got.Extra.Home.Token != redacted {
+		t.Errorf("Extra = %+v, want its Password key and the Token of its values redacted", got.Extra)
+	}
+	// the values logging themselves and the map keys do not leak the redacted fields
+	for _, secret := range []string{"secret", "t1", "t2", "t3", "t4", "k1", "u1", "p1"} {
+		if bytes.Contains(buf.Bytes(), []byte(`"`+secret+`"`)) || bytes.Contains(buf.Bytes(), []byte(" "+secret+`"`)) {
+			t.Errorf("log %s leaks %q", buf.String(), secret)
+		}
+	}
+}
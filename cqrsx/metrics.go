@@ -0,0 +1,81 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of the handling of a query or command, the outcome label of the metrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// metrics are the collectors of the Metrics decorator.
+type metrics struct {
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var metricLabels = []string{"service", "endpoint", "kind", "outcome"}
+
+// WithRegisterer measures every handler with metrics registered on reg, see Metrics. Nothing is
+// measured when reg is nil.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	if reg == nil {
+		return func(o *Options) {}
+	}
+	return WithDecorator(Metrics(reg))
+}
+
+// Metrics returns a decorator counting the queries and commands handled, as cqrs_handled_total, and
+// observing their durations, as cqrs_handling_seconds, labeled by service, endpoint, kind and outcome.
+// Errors are the ones with the error outcome. The collectors are registered on reg, or reused when
+// they already are, so that the buses of several services may share reg. It panics when reg rejects
+// them.
+func Metrics(reg prometheus.Registerer) Decorator {
+	m := &metrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cqrs_handled_total",
+			Help: "Total number of queries and commands handled.",
+		}, metricLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cqrs_handling_seconds",
+			Help:    "Duration of the handling of queries and commands in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, metricLabels),
+	}
+	m.handled = register(reg, m.handled)
+	m.duration = register(reg, m.duration)
+	return func(info Info, next Handler) Handler {
+		return func(ctx context.Context, req any) (any, error) {
+			start := time.Now()
+			res, err := next(ctx, req)
+			outcome := OutcomeSuccess
+			if err != nil {
+				outcome = OutcomeError
+			}
+			labels := prometheus.Labels{"service": info.Service, "endpoint": info.Endpoint, "kind": string(info.Kind), "outcome": outcome}
+			m.handled.With(labels).Inc()
+			m.duration.With(labels).Observe(time.Since(start).Seconds())
+			return res, err
+		}
+	}
+}
+
+// register registers c on reg, returning the collector registered before if any.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,109 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatherMetrics returns the samples gathered from reg by metric name, then by their labels joined as
+// name=value pairs: the value of the counters, the sample count of the histograms.
+func gatherMetrics(t *testing.T, reg prometheus.Gatherer) map[string]map[string]float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]map[string]float64)
+	for _, family := range families {
+		samples := make(map[string]float64)
+		for _, m := range family.GetMetric() {
+			var labels []string
+			for _, label := range m.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			value := m.GetCounter().GetValue()
+			if m.GetHistogram() != nil {
+				value = float64(m.GetHistogram().GetSampleCount())
+			}
+			samples[strings.Join(labels, ",")] = value
+		}
+		got[family.GetName()] = samples
+	}
+	return got
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	errAudit := errors.New("audit failed")
+	handle := func(ctx context.Context, req any) (any, error) {
+		if req == "fail" {
+			return nil, errAudit
+		}
+		return nil, nil
+	}
+	audit := Metrics(reg)(Info{Service: "Keyword", Endpoint: "ManualAudit", Kind: KindCommand}, handle)
+	// the decorator of another bus shares the collectors registered on reg
+	get := Metrics(reg)(Info{Service: "Keyword", Endpoint: "Get", Kind: KindQuery}, handle)
+
+	ctx := context.Background()
+	for _, req := range []string{"succeed", "succeed", "fail"} {
+		if _, err := audit(ctx, req); req == "fail" && !errors.Is(err, errAudit) {
+			t.Fatalf("handler = %v, want the error of the handler", err)
+		}
+	}
+	if _, err := get(ctx, "succeed"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		"endpoint=ManualAudit,kind=command,outcome=success,service=Keyword": 2,
+		"endpoint=ManualAudit,kind=command,outcome=error,service=Keyword":   1,
+		"endpoint=Get,kind=query,outcome=success,service=Keyword":           1,
+	}
+	got := gatherMetrics(t, reg)
+	for _, name := range []string{"cqrs_handled_total", "cqrs_handling_seconds"} {
+		if len(got[name]) != len(want) {
+			t.Errorf("%s = %v, want %v", name, got[name], want)
+			continue
+		}
+		for labels, v := range want {
+			if got[name][labels] != v {
+				t.Errorf("%s{%s} = %v, want %v", name, labels, got[name][labels], v)
+			}
+		}
+	}
+}
+
+func TestMetricsPanicsOnConflictingCollectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cqrs_handled_total",
+		Help: "Total number of queries and commands handled.",
+	}, []string{"service"}))
+	defer func() {
+		if recover() == nil {
+			t.Error("Metrics did not panic on a collector of other labels")
+		}
+	}()
+	Metrics(reg)
+}
+
+func TestWithRegisterer(t *testing.T) {
+	if o := NewOptions(WithRegisterer(nil)); len(o.Decorators) != 0 {
+		t.Errorf("decorators = %d, want none without a registerer", len(o.Decorators))
+	}
+	reg := prometheus.NewRegistry()
+	c := DecorateCommand[string](commandFunc[string](func(ctx context.Context, cmd string) error {
+		return nil
+	}), Info{Service: "Keyword", Endpoint: "Delete", Kind: KindCommand}, NewOptions(WithRegisterer(reg)))
+	if err := c.Handle(context.Background(), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gatherMetrics(t, reg)["cqrs_handled_total"]; got["endpoint=Delete,kind=command,outcome=success,service=Keyword"] != 1 {
+		t.Errorf("cqrs_handled_total = %v, want the command counted", got)
+	}
+}
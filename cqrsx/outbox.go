@@ -0,0 +1,342 @@
+package cqrsx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrNoTx is returned by the Outbox publishing outside of a transaction.
+var ErrNoTx = errors.New("cqrsx: outbox: no transaction in context, make the command @Transactional with a cqrsx.SQLTxManager")
+
+// Outbox is an EventPublisher writing the events to the outbox table in the transaction of the command,
+// carried by the context, so that they are committed or rolled back with it. A Relay publishes them
+// afterwards. The table is created by:
+//
+//	CREATE TABLE outbox (
+//		id              INTEGER PRIMARY KEY AUTOINCREMENT, -- BIGSERIAL PRIMARY KEY in PostgreSQL
+//		event_type      VARCHAR(255) NOT NULL,
+//		payload         BLOB NOT NULL,
+//		created_at      BIGINT NOT NULL,
+//		attempts        INT NOT NULL DEFAULT 0,
+//		next_attempt_at BIGINT NOT NULL,
+//		last_error      TEXT,
+//		delivered_at    BIGINT
+//	)
+//
+// where the times are in Unix milliseconds, and delivered_at is NULL until the event is published.
+type Outbox struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+	now         func() time.Time
+}
+
+var _ EventPublisher = (*Outbox)(nil)
+
+// NewOutbox returns an Outbox of the outbox table of db.
+func NewOutbox(db *sql.DB, opts ...SQLStoreOption) *Outbox {
+	o := newSQLStoreOptions("outbox", opts)
+	return &Outbox{db: db, table: o.table, placeholder: o.placeholder, now: time.Now}
+}
+
+// Publish writes events in the transaction carried by ctx, encoded in JSON and typed by the name of
+// their types. It fails with ErrNoTx when ctx carries no transaction.
+func (o *Outbox) Publish(ctx context.Context, events ...any) error {
+	tx, ok := SQLTx(ctx)
+	if !ok {
+		return ErrNoTx
+	}
+	p := o.placeholder
+	now := o.now().UnixMilli()
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("cqrsx: encode event: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO "+o.table+" (event_type, payload, created_at, attempts, next_attempt_at) VALUES ("+p(1)+", "+p(2)+", "+p(3)+", 0, "+p(4)+")",
+			EventType(event), payload, now, now); err != nil {
+			return fmt.Errorf("cqrsx: insert outbox event: %w", err)
+		}
+	}
+	return nil
+}
+
+// EventType returns the type of event in the outbox, the name of its type.
+func EventType(event any) string {
+	t := reflect.TypeOf(event)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// OutboxMessage is an event of the outbox.
+type OutboxMessage struct {
+	ID        int64
+	EventType string
+	// Payload is the event encoded in JSON.
+	Payload   []byte
+	CreatedAt time.Time
+	// Attempts is the number of the failed attempts to publish the event.
+	Attempts int
+	// nextAttemptAt is the next_attempt_at the event is selected with, which the Relay claims it by.
+	nextAttemptAt int64
+}
+
+// Publisher publishes the events of the outbox, such as to a message broker.
+type Publisher interface {
+	Publish(ctx context.Context, msg *OutboxMessage) error
+}
+
+// PublisherFunc is a Publisher func.
+type PublisherFunc func(ctx context.Context, msg *OutboxMessage) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg *OutboxMessage) error {
+	return f(ctx, msg)
+}
+
+// EventBusPublisher returns a Publisher dispatching the events of the outbox to the handlers of bus,
+// decoded by decode, such as the DecodeEvent generated next to the command bus.
+func EventBusPublisher(bus *EventBus, decode func(eventType string, payload []byte) (any, error)) Publisher {
+	return PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		event, err := decode(msg.EventType, msg.Payload)
+		if err != nil {
+			return err
+		}
+		return bus.dispatch(ctx, []any{event})
+	})
+}
+
+// MemoryPublisher is a Publisher keeping the events in memory, for tests.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []*OutboxMessage
+}
+
+var _ Publisher = (*MemoryPublisher)(nil)
+
+func (p *MemoryPublisher) Publish(ctx context.Context, msg *OutboxMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+// Messages returns the events published so far.
+func (p *MemoryPublisher) Messages() []*OutboxMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*OutboxMessage(nil), p.messages...)
+}
+
+// Relay publishes the events of an Outbox, polling the outbox table. An event failing to be published
+// is retried with an exponential backoff. The Relays of the processes of a service share the table, a
+// Relay claims the events it publishes for a while, after which the events it did not mark are claimed
+// again. The events are delivered at least once, so that they may be published again when the Relay
+// stops between publishing and marking them, or takes longer than the claim.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	opts      *relayOptions
+}
+
+// RelayOption configures a Relay.
+type RelayOption func(o *relayOptions)
+
+type relayOptions struct {
+	interval   time.Duration
+	batchSize  int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	claim      time.Duration
+	onError    func(err error)
+}
+
+// WithRelayInterval sets how often the Relay polls the outbox table, 1s by default.
+func WithRelayInterval(d time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.interval = d
+	}
+}
+
+// WithRelayBatchSize sets how many events the Relay publishes per poll, 100 by default.
+func WithRelayBatchSize(n int) RelayOption {
+	return func(o *relayOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithRelayBackoff sets the delay of the first retry of an event, doubled by every failed attempt up to
+// limit, 1s and 5m by default.
+func WithRelayBackoff(first, limit time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.minBackoff = first
+		o.maxBackoff = limit
+	}
+}
+
+// WithRelayClaim sets how long the Relay claims the events it publishes, 1m by default.
+func WithRelayClaim(d time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.claim = d
+	}
+}
+
+// WithRelayErrorHandler sets the handler of the errors of the polls of Run, and of the events failing
+// to be published, which are ignored by default.
+func WithRelayErrorHandler(h func(err error)) RelayOption {
+	return func(o *relayOptions) {
+		o.onError = h
+	}
+}
+
+// NewRelay returns a Relay publishing the events of outbox with publisher.
+func NewRelay(outbox *Outbox, publisher Publisher, opts ...RelayOption) *Relay {
+	o := &relayOptions{
+		interval:   time.Second,
+		batchSize:  100,
+		minBackoff: time.Second,
+		maxBackoff: 5 * time.Minute,
+		claim:      time.Minute,
+		onError:    func(error) {},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Relay{outbox: outbox, publisher: publisher, opts: o}
+}
+
+// Run polls the outbox until ctx is done, and returns its error.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+	for {
+		// drain the outbox, a full batch is likely followed by more events
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				r.opts.onError(err)
+			}
+			if err != nil || n < r.opts.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes a batch of the events due, in the order they are written, and returns how many
+// were due. The events claimed by another Relay in the meantime are skipped.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	msgs, err := r.due(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		claimed, err := r.claim(ctx, msg)
+		if err != nil {
+			return len(msgs), err
+		}
+		if !claimed {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, msg); err != nil {
+			r.opts.onError(fmt.Errorf("cqrsx: publish outbox event %d: %w", msg.ID, err))
+			if err := r.retry(ctx, msg, err); err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+		if err := r.delivered(ctx, msg); err != nil {
+			return len(msgs), err
+		}
+	}
+	return len(msgs), nil
+}
+
+func (r *Relay) due(ctx context.Context) ([]*OutboxMessage, error) {
+	o, p := r.outbox, r.outbox.placeholder
+	rows, err := o.db.QueryContext(ctx,
+		"SELECT id, event_type, payload, created_at, attempts, next_attempt_at FROM "+o.table+
+			" WHERE delivered_at IS NULL AND next_attempt_at <= "+p(1)+" ORDER BY id LIMIT "+p(2),
+		o.now().UnixMilli(), r.opts.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("cqrsx: select outbox events: %w", err)
+	}
+	defer rows.Close()
+	var msgs []*OutboxMessage
+	for rows.Next() {
+		msg := &OutboxMessage{}
+		var createdAt int64
+		if err := rows.Scan(&msg.ID, &msg.EventType, &msg.Payload, &createdAt, &msg.Attempts, &msg.nextAttemptAt); err != nil {
+			return nil, fmt.Errorf("cqrsx: scan outbox event: %w", err)
+		}
+		msg.CreatedAt = time.UnixMilli(createdAt)
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cqrsx: select outbox events: %w", err)
+	}
+	return msgs, nil
+}
+
+// claim claims msg by postponing its next attempt by the claim of the Relay, unless another Relay
+// claimed it since it was selected, and reports whether it did.
+func (r *Relay) claim(ctx context.Context, msg *OutboxMessage) (bool, error) {
+	o, p := r.outbox, r.outbox.placeholder
+	res, err := o.db.ExecContext(ctx,
+		"UPDATE "+o.table+" SET next_attempt_at = "+p(1)+" WHERE id = "+p(2)+" AND next_attempt_at = "+p(3)+" AND delivered_at IS NULL",
+		o.now().Add(r.opts.claim).UnixMilli(), msg.ID, msg.nextAttemptAt)
+	if err != nil {
+		return false, fmt.Errorf("cqrsx: claim outbox event %d: %w", msg.ID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("cqrsx: claim outbox event %d: %w", msg.ID, err)
+	}
+	return n == 1, nil
+}
+
+func (r *Relay) delivered(ctx context.Context, msg *OutboxMessage) error {
+	o, p := r.outbox, r.outbox.placeholder
+	if _, err := o.db.ExecContext(ctx,
+		"UPDATE "+o.table+" SET delivered_at = "+p(1)+" WHERE id = "+p(2),
+		o.now().UnixMilli(), msg.ID); err != nil {
+		return fmt.Errorf("cqrsx: mark outbox event %d delivered: %w", msg.ID, err)
+	}
+	return nil
+}
+
+func (r *Relay) retry(ctx context.Context, msg *OutboxMessage, cause error) error {
+	o, p := r.outbox, r.outbox.placeholder
+	next := o.now().Add(r.backoff(msg.Attempts + 1))
+	if _, err := o.db.ExecContext(ctx,
+		"UPDATE "+o.table+" SET attempts = attempts + 1, next_attempt_at = "+p(1)+", last_error = "+p(2)+" WHERE id = "+p(3),
+		next.UnixMilli(), cause.Error(), msg.ID); err != nil {
+		return fmt.Errorf("cqrsx: retry outbox event %d: %w", msg.ID, err)
+	}
+	return nil
+}
+
+// backoff returns the delay of the retry following the attempts-th failed attempt.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.opts.minBackoff
+	for i := 1; i < attempts && d < r.opts.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.opts.maxBackoff {
+		d = r.opts.maxBackoff
+	}
+	return d
+}
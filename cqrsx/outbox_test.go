@@ -0,0 +1,167 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const outboxSchema = `CREATE TABLE outbox (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_type      VARCHAR(255) NOT NULL,
+	payload         BLOB NOT NULL,
+	created_at      BIGINT NOT NULL,
+	attempts        INT NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL,
+	last_error      TEXT,
+	delivered_at    BIGINT
+)`
+
+func TestOutboxPublishesInTransaction(t *testing.T) {
+	db := openSQLite(t, ordersSchema, outboxSchema)
+	outbox := NewOutbox(db)
+	info := Info{Service: "Orders", Endpoint: "Place", Kind: KindCommand, Transactional: true, Emits: []string{"OrderPlaced"}}
+	c := DecorateCommand[*placeOrderCmd](commandFunc[*placeOrderCmd](func(ctx context.Context, cmd *placeOrderCmd) error {
+		if err := outbox.Publish(ctx, &OrderPlaced{ID: cmd.ID}); err != nil {
+			return err
+		}
+		return placeOrder(ctx, cmd)
+	}), info, NewOptions(WithTxManager(NewSQLTxManager(db, nil))))
+	ctx := context.Background()
+
+	if err := c.Handle(ctx, &placeOrderCmd{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Handle(ctx, &placeOrderCmd{ID: "2", Fail: errors.New("failing")}); err == nil {
+		t.Fatal("want the error of the command")
+	}
+	if err := outbox.Publish(ctx, &OrderPlaced{ID: "3"}); !errors.Is(err, ErrNoTx) {
+		t.Errorf("Publish outside of a transaction = %v, want %v", err, ErrNoTx)
+	}
+
+	publisher := &MemoryPublisher{}
+	if n, err := NewRelay(outbox, publisher).RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce = %d, %v, want the event of the committed command only", n, err)
+	}
+	msgs := publisher.Messages()
+	if len(msgs) != 1 || msgs[0].EventType != "OrderPlaced" || string(msgs[0].Payload) != `{"ID":"1"}` {
+		t.Fatalf("published %+v, want OrderPlaced of 1", msgs)
+	}
+	if n, err := NewRelay(outbox, publisher).RelayOnce(ctx); err != nil || n != 0 {
+		t.Errorf("RelayOnce = %d, %v, want the delivered event not published again", n, err)
+	}
+}
+
+// writeOutbox writes events to outbox in a transaction.
+func writeOutbox(t *testing.T, outbox *Outbox, events ...any) {
+	t.Helper()
+	err := NewSQLTxManager(outbox.db, nil).WithTx(context.Background(), func(ctx context.Context) error {
+		return outbox.Publish(ctx, events...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelayRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewOutbox(openSQLite(t, outboxSchema))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	outbox.now = func() time.Time { return now }
+	writeOutbox(t, outbox, &OrderPlaced{ID: "1"}, &OrderPlaced{ID: "2"})
+
+	failures := 3
+	var published []string
+	publisher := PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		if string(msg.Payload) == `{"ID":"1"}` && failures > 0 {
+			failures--
+			return errors.New("broker down")
+		}
+		published = append(published, string(msg.Payload))
+		return nil
+	})
+	relay := NewRelay(outbox, publisher, WithRelayBackoff(time.Second, 3*time.Second))
+
+	// the first event is retried after 1s, 2s, then 3s, the limit of the backoff
+	for i, wait := range []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second} {
+		if wait > 0 {
+			now = now.Add(wait - time.Millisecond)
+			if n, err := relay.RelayOnce(ctx); err != nil || n != 0 {
+				t.Fatalf("attempt #%d before its backoff = %d, %v, want none due", i, n, err)
+			}
+			now = now.Add(time.Millisecond)
+		}
+		if _, err := relay.RelayOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(published) != 2 || published[0] != `{"ID":"2"}` || published[1] != `{"ID":"1"}` {
+		t.Errorf("published %v, want the second event once, then the first one once it succeeds", published)
+	}
+	var attempts int
+	var lastError string
+	if err := outbox.db.QueryRow("SELECT attempts, last_error FROM outbox WHERE id = 1").Scan(&attempts, &lastError); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || lastError != "broker down" {
+		t.Errorf("attempts, last_error = %d, %q, want 3 failed attempts recorded", attempts, lastError)
+	}
+}
+
+func TestRelaysClaimEvents(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewOutbox(openSQLite(t, outboxSchema))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	outbox.now = func() time.Time { return now }
+	writeOutbox(t, outbox, &OrderPlaced{ID: "1"}, &OrderPlaced{ID: "2"})
+
+	second := &MemoryPublisher{}
+	secondRelay := NewRelay(outbox, second)
+	published := make(map[int64]int)
+	first := NewRelay(outbox, PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		published[msg.ID]++
+		// the second relay polls while the first one publishes
+		if _, err := secondRelay.RelayOnce(ctx); err != nil {
+			t.Error(err)
+		}
+		return nil
+	}), WithRelayClaim(time.Minute))
+	// both relays select the events, the first one claims them before the second one
+	msgs, err := secondRelay.due(ctx)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("due = %v, %v, want the 2 events", msgs, err)
+	}
+	if n, err := first.RelayOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("RelayOnce = %d, %v, want the 2 events", n, err)
+	}
+	for _, msg := range msgs {
+		if claimed, err := secondRelay.claim(ctx, msg); err != nil || claimed {
+			t.Errorf("claim of the selected event %d = %v, %v, want it skipped", msg.ID, claimed, err)
+		}
+	}
+	for _, msg := range second.Messages() {
+		published[msg.ID]++
+	}
+	if len(published) != 2 || published[1] != 1 || published[2] != 1 {
+		t.Errorf("published %v, want each event published once", published)
+	}
+
+	// the claim of a relay stopped before marking the event delivered expires
+	writeOutbox(t, outbox, &OrderPlaced{ID: "3"})
+	msgs, err = first.due(ctx)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("due = %v, %v, want the third event", msgs, err)
+	}
+	if claimed, err := first.claim(ctx, msgs[0]); err != nil || !claimed {
+		t.Fatalf("claim = %v, %v, want the event claimed", claimed, err)
+	}
+	now = now.Add(time.Minute - time.Millisecond)
+	if n, err := secondRelay.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("RelayOnce within the claim = %d, %v, want none due", n, err)
+	}
+	now = now.Add(time.Millisecond)
+	if n, err := secondRelay.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce after the claim = %d, %v, want the event published by the second relay", n, err)
+	}
+}
@@ -0,0 +1,44 @@
+package cqrsx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer and meter of the decorators.
+const instrumentationName = "github.com/go-miya/gorsx/cqrsx"
+
+// WithTracerProvider traces every handler with tp, see Tracing. Nothing is traced when tp is nil.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	if tp == nil {
+		return func(o *Options) {}
+	}
+	return WithDecorator(Tracing(tp))
+}
+
+// Tracing returns a decorator starting a span named after the endpoint, such as Keyword.ManualAudit,
+// around every handler. The span carries the service, endpoint and kind of the handler, and the
+// error status when the handler fails.
+func Tracing(tp trace.TracerProvider) Decorator {
+	tracer := tp.Tracer(instrumentationName)
+	return func(info Info, next Handler) Handler {
+		attrs := trace.WithAttributes(
+			attribute.String("cqrs.service", info.Service),
+			attribute.String("cqrs.endpoint", info.Endpoint),
+			attribute.String("cqrs.kind", string(info.Kind)),
+		)
+		return func(ctx context.Context, req any) (any, error) {
+			ctx, span := tracer.Start(ctx, info.Name(), attrs, trace.WithSpanKind(trace.SpanKindInternal))
+			defer span.End()
+			res, err := next(ctx, req)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return res, err
+		}
+	}
+}
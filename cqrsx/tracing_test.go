@@ -0,0 +1,148 @@
+package cqrsx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type commandFunc[C any] func(ctx context.Context, cmd C) error
+
+func (f commandFunc[C]) Handle(ctx context.Context, cmd C) error {
+	return f(ctx, cmd)
+}
+
+// recordedSpan is a span recorded by recordingTracerProvider.
+type recordedSpan struct {
+	trace.Span
+	name    string
+	kind    trace.SpanKind
+	attrs   []attribute.KeyValue
+	errs    []error
+	code    codes.Code
+	message string
+	ended   bool
+}
+
+func (s *recordedSpan) RecordError(err error, options ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {
+	s.code, s.message = code, description
+}
+
+func (s *recordedSpan) End(options ...trace.SpanEndOption) {
+	s.ended = true
+}
+
+// recordingTracerProvider records the spans its tracers start, which are otherwise no-ops.
+type recordingTracerProvider struct {
+	trace.TracerProvider
+	mu    sync.Mutex
+	names []string
+	spans []*recordedSpan
+}
+
+func newRecordingTracerProvider() *recordingTracerProvider {
+	return &recordingTracerProvider{TracerProvider: trace.NewNoopTracerProvider()}
+}
+
+func (tp *recordingTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.names = append(tp.names, name)
+	return recordingTracer{Tracer: tp.TracerProvider.Tracer(name, options...), tp: tp}
+}
+
+type recordingTracer struct {
+	trace.Tracer
+	tp *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	_, noop := t.Tracer.Start(ctx, name, opts...)
+	span := &recordedSpan{Span: noop, name: name, kind: config.SpanKind(), attrs: config.Attributes()}
+	t.tp.mu.Lock()
+	defer t.tp.mu.Unlock()
+	t.tp.spans = append(t.tp.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func TestTracing(t *testing.T) {
+	tp := newRecordingTracerProvider()
+	info := Info{Service: "Keyword", Endpoint: "ManualAudit", Kind: KindCommand}
+	errAudit := errors.New("audit failed")
+	var spanInHandler trace.Span
+	handler := Tracing(tp)(info, func(ctx context.Context, req any) (any, error) {
+		spanInHandler = trace.SpanFromContext(ctx)
+		if req == "fail" {
+			return nil, errAudit
+		}
+		return "ok", nil
+	})
+	if len(tp.names) != 1 || tp.names[0] != instrumentationName {
+		t.Errorf("tracers = %v, want the one of %s", tp.names, instrumentationName)
+	}
+
+	if res, err := handler(context.Background(), "succeed"); err != nil || res != "ok" {
+		t.Fatalf("handler = %v, %v, want the result of the handler", res, err)
+	}
+	if _, err := handler(context.Background(), "fail"); !errors.Is(err, errAudit) {
+		t.Fatalf("handler = %v, want the error of the handler", err)
+	}
+	if len(tp.spans) != 2 {
+		t.Fatalf("recorded %d spans, want one per call", len(tp.spans))
+	}
+	if spanInHandler != tp.spans[1] {
+		t.Error("the handler does not run in the context of the span")
+	}
+	wantAttrs := []attribute.KeyValue{
+		attribute.String("cqrs.service", "Keyword"),
+		attribute.String("cqrs.endpoint", "ManualAudit"),
+		attribute.String("cqrs.kind", "command"),
+	}
+	for i, span := range tp.spans {
+		if span.name != "Keyword.ManualAudit" || span.kind != trace.SpanKindInternal || !span.ended {
+			t.Errorf("span #%d = %s %v ended %v, want an ended internal span Keyword.ManualAudit", i, span.name, span.kind, span.ended)
+		}
+		if len(span.attrs) != len(wantAttrs) {
+			t.Errorf("span #%d attributes = %v, want %v", i, span.attrs, wantAttrs)
+			continue
+		}
+		for j, attr := range span.attrs {
+			if attr != wantAttrs[j] {
+				t.Errorf("span #%d attributes = %v, want %v", i, span.attrs, wantAttrs)
+				break
+			}
+		}
+	}
+	if succeeded := tp.spans[0]; succeeded.code != codes.Unset || len(succeeded.errs) != 0 {
+		t.Errorf("span of the success = %v %v, want no error status", succeeded.code, succeeded.errs)
+	}
+	if failed := tp.spans[1]; failed.code != codes.Error || failed.message != errAudit.Error() || len(failed.errs) != 1 || failed.errs[0] != errAudit {
+		t.Errorf("span of the failure = %v %q %v, want the error recorded", failed.code, failed.message, failed.errs)
+	}
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	if o := NewOptions(WithTracerProvider(nil)); len(o.Decorators) != 0 {
+		t.Errorf("decorators = %d, want none without a tracer provider", len(o.Decorators))
+	}
+	tp := newRecordingTracerProvider()
+	c := DecorateCommand[string](commandFunc[string](func(ctx context.Context, cmd string) error {
+		return nil
+	}), Info{Service: "Keyword", Endpoint: "Delete", Kind: KindCommand}, NewOptions(WithTracerProvider(tp)))
+	if err := c.Handle(context.Background(), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tp.spans) != 1 || tp.spans[0].name != "Keyword.Delete" {
+		t.Errorf("recorded %d spans, want the span of the command", len(tp.spans))
+	}
+}
@@ -0,0 +1,90 @@
+package cqrsx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TxManager runs functions in transactions.
+type TxManager interface {
+	// WithTx runs fn in a transaction carried by the context given to fn. The transaction is committed
+	// when fn returns nil, and rolled back when it returns an error or panics.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithTxManager runs the @Transactional commands in transactions of m.
+func WithTxManager(m TxManager) Option {
+	return func(o *Options) {
+		o.TxManager = m
+	}
+}
+
+// transactional wraps the handler of a @Transactional command in a transaction. It panics when no
+// TxManager is configured, so that a command is never run outside of the transaction it declares.
+func (o *Options) transactional(info Info, next Handler) Handler {
+	if o.TxManager == nil {
+		panic(fmt.Sprintf("cqrsx: %s is @Transactional, configure a TxManager with cqrsx.WithTxManager", info.Name()))
+	}
+	m := o.TxManager
+	return func(ctx context.Context, req any) (any, error) {
+		var res any
+		err := m.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			res, err = next(ctx, req)
+			return err
+		})
+		return res, err
+	}
+}
+
+type sqlTxKey struct{}
+
+// SQLTxManager is a TxManager of database/sql transactions. The handlers get the transaction with
+// SQLTx.
+type SQLTxManager struct {
+	db   *sql.DB
+	opts *sql.TxOptions
+}
+
+var _ TxManager = (*SQLTxManager)(nil)
+
+// NewSQLTxManager returns a TxManager beginning transactions of db with opts, which may be nil.
+func NewSQLTxManager(db *sql.DB, opts *sql.TxOptions) *SQLTxManager {
+	return &SQLTxManager{db: db, opts: opts}
+}
+
+// WithTx runs fn in a transaction. When ctx already carries a transaction, fn joins it, and the
+// outermost WithTx commits or rolls it back.
+func (m *SQLTxManager) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := SQLTx(ctx); ok {
+		return fn(ctx)
+	}
+	tx, err := m.db.BeginTx(ctx, m.opts)
+	if err != nil {
+		return fmt.Errorf("cqrsx: begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(context.WithValue(ctx, sqlTxKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("cqrsx: rollback transaction: %w", rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cqrsx: commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SQLTx returns the transaction carried by ctx, begun by SQLTxManager.
+func SQLTx(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(sqlTxKey{}).(*sql.Tx)
+	return tx, ok
+}
@@ -0,0 +1,122 @@
+package cqrsx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite opens an in-memory SQLite database created by the statements of schema.
+func openSQLite(t *testing.T, schema ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the in-memory database lives as long as one of its connections
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+const ordersSchema = `CREATE TABLE orders (id VARCHAR(64) PRIMARY KEY)`
+
+type placeOrderCmd struct {
+	ID    string
+	Fail  error
+	Panic bool
+}
+
+// placeOrder inserts the order of cmd in the transaction of ctx, then fails or panics as cmd says.
+func placeOrder(ctx context.Context, cmd *placeOrderCmd) error {
+	tx, ok := SQLTx(ctx)
+	if !ok {
+		return errors.New("no transaction")
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO orders (id) VALUES (?)", cmd.ID); err != nil {
+		return err
+	}
+	if cmd.Panic {
+		panic("boom")
+	}
+	return cmd.Fail
+}
+
+func countOrders(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestTransactionalCommand(t *testing.T) {
+	db := openSQLite(t, ordersSchema)
+	info := Info{Service: "Orders", Endpoint: "Place", Kind: KindCommand, Transactional: true}
+	c := DecorateCommand[*placeOrderCmd](commandFunc[*placeOrderCmd](placeOrder), info, NewOptions(WithTxManager(NewSQLTxManager(db, nil))))
+	ctx := context.Background()
+
+	if err := c.Handle(ctx, &placeOrderCmd{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countOrders(t, db); n != 1 {
+		t.Fatalf("orders = %d after a success, want it committed", n)
+	}
+
+	failing := errors.New("failing")
+	if err := c.Handle(ctx, &placeOrderCmd{ID: "2", Fail: failing}); !errors.Is(err, failing) {
+		t.Fatalf("Handle = %v, want %v", err, failing)
+	}
+	if n := countOrders(t, db); n != 1 {
+		t.Fatalf("orders = %d after an error, want it rolled back", n)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want the panic of the command", p)
+			}
+		}()
+		_ = c.Handle(ctx, &placeOrderCmd{ID: "3", Panic: true})
+	}()
+	if n := countOrders(t, db); n != 1 {
+		t.Fatalf("orders = %d after a panic, want it rolled back", n)
+	}
+}
+
+func TestSQLTxManagerJoinsOuterTransaction(t *testing.T) {
+	db := openSQLite(t, ordersSchema)
+	m := NewSQLTxManager(db, nil)
+	failing := errors.New("failing")
+	err := m.WithTx(context.Background(), func(ctx context.Context) error {
+		outer, _ := SQLTx(ctx)
+		if err := m.WithTx(ctx, func(ctx context.Context) error {
+			if inner, _ := SQLTx(ctx); inner != outer {
+				t.Error("the nested WithTx began another transaction, want it to join the outer one")
+			}
+			return placeOrder(ctx, &placeOrderCmd{ID: "1"})
+		}); err != nil {
+			return err
+		}
+		// the nested WithTx does not commit the transaction
+		if _, err := outer.ExecContext(ctx, "SELECT 1"); err != nil {
+			t.Errorf("transaction done by the nested WithTx: %v", err)
+		}
+		return failing
+	})
+	if !errors.Is(err, failing) {
+		t.Fatalf("WithTx = %v, want %v", err, failing)
+	}
+	if n := countOrders(t, db); n != 0 {
+		t.Errorf("orders = %d, want the insert of the nested WithTx rolled back with the outer transaction", n)
+	}
+}
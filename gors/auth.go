@@ -0,0 +1,46 @@
+package gors
+
+import (
+	"context"
+	"fmt"
+)
+
+// AuthRequirement is the authorization of an endpoint, declared by @Auth and @Permission.
+type AuthRequirement struct {
+	// Endpoint is the endpoint, such as Service.Endpoint.
+	Endpoint string
+	// Roles are the roles of which the principal needs any, none when empty.
+	Roles []string
+	// Permissions are the permissions the principal needs all of.
+	Permissions []string
+}
+
+// Authorizer decides whether the principal of ctx may call the endpoint of requirement, and returns an
+// error when it may not.
+type Authorizer interface {
+	Authorize(ctx context.Context, requirement *AuthRequirement) error
+}
+
+// AuthorizerFunc is a function Authorizer.
+type AuthorizerFunc func(ctx context.Context, requirement *AuthRequirement) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, requirement *AuthRequirement) error {
+	return f(ctx, requirement)
+}
+
+// WithAuthorizer sets the authorizer of the handlers of the @GORS methods that declare @Auth or
+// @Permission, and of the job route of a service declaring them.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(o *Options) {
+		o.Authorizer = authorizer
+	}
+}
+
+// Authorize authorizes the call of the endpoint of requirement with authorizer. It fails when
+// authorizer is nil, so that an endpoint requiring authorization is never served without it.
+func Authorize(ctx context.Context, authorizer Authorizer, requirement *AuthRequirement) error {
+	if authorizer == nil {
+		return fmt.Errorf("gors: %s requires authorization, set the authorizer with gors.WithAuthorizer", requirement.Endpoint)
+	}
+	return authorizer.Authorize(ctx, requirement)
+}
@@ -0,0 +1,377 @@
+package gors
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxMemory is the memory limit of multipart forms, beyond which files are stored on disk.
+const defaultMaxMemory = 32 << 20
+
+// DefaultMaxBodyBytes is the default limit of the bodies the handlers and the clients read, but the
+// streamed ones.
+const DefaultMaxBodyBytes = 10 << 20
+
+// Binding binds a part of an HTTP request into obj, a pointer to a struct.
+type Binding func(r *http.Request, obj any) error
+
+// BindError is returned when a request cannot be bound.
+type BindError struct {
+	// Source is the part of the request, such as "uri", "query", "header" or "json".
+	Source string
+	// Field is the tag name of the field that cannot be bound, empty for a body.
+	Field string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("gors: bind %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("gors: bind %s %q: %v", e.Source, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Bind binds r into obj with bindings, in order.
+func Bind(r *http.Request, obj any, bindings ...Binding) error {
+	for _, binding := range bindings {
+		if err := binding(r, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindUri binds the path parameters into the fields tagged with uri.
+func BindUri(r *http.Request, obj any) error {
+	params := PathParams(r.Context())
+	return bindValues(obj, "uri", "uri", func(key string) ([]string, bool) {
+		v, ok := params[key]
+		return []string{v}, ok
+	}, nil)
+}
+
+// BindQuery binds the query parameters into the fields tagged with form.
+func BindQuery(r *http.Request, obj any) error {
+	query := r.URL.Query()
+	return bindValues(obj, "query", "form", lookupValues(query), nil)
+}
+
+// BindHeader binds the headers into the fields tagged with header.
+func BindHeader(r *http.Request, obj any) error {
+	return bindValues(obj, "header", "header", func(key string) ([]string, bool) {
+		v, ok := r.Header[textproto.CanonicalMIMEHeaderKey(key)]
+		return v, ok
+	}, nil)
+}
+
+// BindForm binds the query parameters and the urlencoded or multipart form into the fields tagged with form.
+func BindForm(r *http.Request, obj any) error {
+	if err := r.ParseMultipartForm(defaultMaxMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return &BindError{Source: "form", Err: err}
+	}
+	return bindValues(obj, "form", "form", lookupValues(r.Form), multipartFiles(r))
+}
+
+// BindFormPost binds the urlencoded form of the body into the fields tagged with form.
+func BindFormPost(r *http.Request, obj any) error {
+	if err := r.ParseForm(); err != nil {
+		return &BindError{Source: "form", Err: err}
+	}
+	return bindValues(obj, "form", "form", lookupValues(r.PostForm), nil)
+}
+
+// BindFormMultipart binds the multipart form of the body, files included, into the fields tagged with form.
+func BindFormMultipart(r *http.Request, obj any) error {
+	if err := r.ParseMultipartForm(defaultMaxMemory); err != nil {
+		return &BindError{Source: "form", Err: err}
+	}
+	return bindValues(obj, "form", "form", lookupValues(r.MultipartForm.Value), multipartFiles(r))
+}
+
+// BindJSON unmarshals the JSON body into obj.
+func BindJSON(r *http.Request, obj any) error { return bindBody(r, obj, "json", JSONCodec) }
+
+// BindXML unmarshals the XML body into obj.
+func BindXML(r *http.Request, obj any) error { return bindBody(r, obj, "xml", XMLCodec) }
+
+// BindYAML unmarshals the YAML body into obj.
+func BindYAML(r *http.Request, obj any) error { return bindBody(r, obj, "yaml", YAMLCodec) }
+
+// BindTOML unmarshals the TOML body into obj.
+func BindTOML(r *http.Request, obj any) error { return bindBody(r, obj, "toml", TOMLCodec) }
+
+// BindMsgPack unmarshals the MessagePack body into obj.
+func BindMsgPack(r *http.Request, obj any) error { return bindBody(r, obj, "msgpack", MsgPackCodec) }
+
+// BindProtoBuf unmarshals the protobuf body into obj, which must be a proto.Message.
+func BindProtoBuf(r *http.Request, obj any) error { return bindBody(r, obj, "protobuf", ProtoBufCodec) }
+
+func bindBody(r *http.Request, obj any, source string, codec Codec) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return &BindError{Source: source, Err: err}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := codec.Unmarshal(data, obj); err != nil {
+		return &BindError{Source: source, Err: err}
+	}
+	return nil
+}
+
+func lookupValues(values map[string][]string) func(key string) ([]string, bool) {
+	return func(key string) ([]string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func multipartFiles(r *http.Request) func(key string) ([]*multipart.FileHeader, bool) {
+	return func(key string) ([]*multipart.FileHeader, bool) {
+		if r.MultipartForm == nil {
+			return nil, false
+		}
+		files, ok := r.MultipartForm.File[key]
+		return files, ok
+	}
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// bindValues sets the fields of obj tagged with tag to the values lookup finds by tag name, and the
+// *multipart.FileHeader fields to the files lookupFiles finds. Errors are reported for source.
+func bindValues(
+	obj any,
+	source string,
+	tag string,
+	lookup func(key string) ([]string, bool),
+	lookupFiles func(key string) ([]*multipart.FileHeader, bool),
+) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &BindError{Source: source, Err: fmt.Errorf("%T is not a pointer to a struct", obj)}
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return &BindError{Source: source, Err: fmt.Errorf("%T is not a pointer to a struct", obj)}
+	}
+	return bindStruct(rv, source, tag, lookup, lookupFiles)
+}
+
+func bindStruct(
+	rv reflect.Value,
+	source string,
+	tag string,
+	lookup func(key string) ([]string, bool),
+	lookupFiles func(key string) ([]*multipart.FileHeader, bool),
+) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, tagged := sf.Tag.Lookup(tag)
+		name = strings.Split(name, ",")[0]
+		if name == "-" {
+			continue
+		}
+		field := rv.Field(i)
+		if sf.Anonymous && !tagged {
+			if sf.Type.Kind() == reflect.Pointer && sf.Type.Elem().Kind() == reflect.Struct && sf.IsExported() {
+				if field.IsNil() {
+					field.Set(reflect.New(sf.Type.Elem()))
+				}
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct {
+				if err := bindStruct(field, source, tag, lookup, lookupFiles); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !tagged || !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if isFileField(sf.Type) {
+			if lookupFiles == nil {
+				continue
+			}
+			files, ok := lookupFiles(name)
+			if !ok || len(files) == 0 {
+				continue
+			}
+			if sf.Type == fileHeaderType {
+				field.Set(reflect.ValueOf(files[0]))
+			} else {
+				field.Set(reflect.ValueOf(files))
+			}
+			continue
+		}
+		values, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValues(field, values); err != nil {
+			return &BindError{Source: source, Field: name, Err: err}
+		}
+	}
+	return nil
+}
+
+func isFileField(t reflect.Type) bool {
+	return t == fileHeaderType || (t.Kind() == reflect.Slice && t.Elem() == fileHeaderType)
+}
+
+// setValues sets field to values, one per element of a slice field.
+func setValues(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 && !implementsTextUnmarshaler(field) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+func implementsTextUnmarshaler(field reflect.Value) bool {
+	return field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType)
+}
+
+// setValue parses value into field, a settable value of a basic, time.Time, time.Duration, pointer or
+// encoding.TextUnmarshaler type.
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return setValue(field.Elem(), value)
+	}
+	if implementsTextUnmarshaler(field) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	switch field.Type() {
+	case timeType:
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		if value == "" {
+			value = "false"
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			value = "0"
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			value = "0"
+		}
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			value = "0"
+		}
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		field.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// parseTime parses an RFC 3339 time, a date or a unix time in seconds.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// BindBytes reads the body of a []byte request.
+func BindBytes(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, &BindError{Source: "body", Err: err}
+	}
+	return data, nil
+}
+
+// BindString reads the body of a string request.
+func BindString(r *http.Request) (string, error) {
+	data, err := BindBytes(r)
+	return string(data), err
+}
@@ -0,0 +1,51 @@
+package gors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBindLimitsBody(t *testing.T) {
+	type createReq struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name     string
+		limit    int64
+		body     string
+		wantCode int
+	}{
+		{name: "within the limit", limit: 64, body: `{"name":"x"}`},
+		{name: "beyond the limit", limit: 8, body: `{"name":"x"}`, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "unlimited", limit: -1, body: `{"name":"` + strings.Repeat("x", DefaultMaxBodyBytes) + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOptions(WithMaxBodyBytes(tt.limit))
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader(tt.body))
+			o.LimitBody(w, r)
+			req := new(createReq)
+			err := Bind(r, req, BindJSON)
+			if tt.wantCode == 0 {
+				if err != nil || req.Name == "" {
+					t.Errorf("Bind = %v, name %d bytes, want the body bound", err, len(req.Name))
+				}
+				return
+			}
+			if got := ToError(err).StatusCode; got != tt.wantCode {
+				t.Errorf("ToError(%v).StatusCode = %d, want %d", err, got, tt.wantCode)
+			}
+		})
+	}
+
+	// the default limit applies to the raw bodies too
+	o := NewOptions()
+	r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader(strings.Repeat("x", DefaultMaxBodyBytes+1)))
+	o.LimitBody(httptest.NewRecorder(), r)
+	if _, err := BindBytes(r); ToError(err).StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("BindBytes = %v, want the body rejected beyond DefaultMaxBodyBytes", err)
+	}
+}
@@ -0,0 +1,361 @@
+package gors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNoRoute is returned by the client methods of service methods without a @GORS route.
+var ErrNoRoute = errors.New("gors: method has no @GORS route")
+
+// ResponseError is returned by a client when the server responds with an error status, unless the
+// response is application/problem+json, for which an *Error is returned.
+type ResponseError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *ResponseError) Error() string {
+	body := strings.TrimSpace(string(e.Body))
+	if body == "" {
+		return fmt.Sprintf("gors: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("gors: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), body)
+}
+
+// Client sends the requests of the clients generated by gorsx.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxBodyBytes int64
+}
+
+type ClientOption func(c *Client)
+
+// WithHTTPClient sets the *http.Client requests are sent with, http.DefaultClient by default.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithMaxResponseBytes sets the limit of the bodies of the responses but the streamed ones,
+// DefaultMaxBodyBytes by default and unlimited when n is negative. The reads beyond fail with an *http.MaxBytesError, and the bodies
+// of the error responses are truncated.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxBodyBytes = n
+	}
+}
+
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: http.DefaultClient, maxBodyBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Do sends r and decodes the response with decode, which may be nil when the response has no body.
+func (c *Client) Do(ctx context.Context, r *Request, decode Decoder) error {
+	return c.do(ctx, r, decode, true)
+}
+
+// Stream sends r and hands the body of the response over to v, which the caller must close if it is an
+// io.Closer. Unlike Do, the body is not limited, only the one of an error response is.
+func (c *Client) Stream(ctx context.Context, r *Request, v *io.Reader) error {
+	return c.do(ctx, r, DecodeReader(v), false)
+}
+
+// do sends r and decodes the response with decode, limiting its body when limit is true, and the one of
+// an error response in any case.
+func (c *Client) do(ctx context.Context, r *Request, decode Decoder, limit bool) error {
+	req, err := r.build(ctx, c.baseURL)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if c.maxBodyBytes >= 0 && (limit || resp.StatusCode >= http.StatusBadRequest) {
+		resp.Body = http.MaxBytesReader(nil, resp.Body, c.maxBodyBytes)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body)
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == problemContentType {
+			if e, ok := decodeProblem(resp.StatusCode, body); ok {
+				return e
+			}
+		}
+		return &ResponseError{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	}
+	if decode == nil {
+		return nil
+	}
+	return decode(resp)
+}
+
+// Request is an HTTP request built by a generated client method.
+type Request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	form        url.Values
+	files       map[string][]*multipart.FileHeader
+	multipart   bool
+	body        io.Reader
+	contentType string
+	err         error
+}
+
+// NewRequest returns a request to path, a @Path whose :name and *name parameters are set by SetPathParam.
+func NewRequest(method string, path string) *Request {
+	return &Request{method: method, path: path, query: url.Values{}, header: http.Header{}}
+}
+
+// SetPathParam substitutes the :name or *name parameter of the path with v.
+func (r *Request) SetPathParam(name string, v any) {
+	value := ""
+	if values := FormatValues(v); len(values) > 0 {
+		value = values[0]
+	}
+	segments := strings.Split(r.path, "/")
+	for i, segment := range segments {
+		switch segment {
+		case ":" + name:
+			segments[i] = url.PathEscape(value)
+		case "*" + name:
+			segments[i] = strings.TrimPrefix(escapePath(value), "/")
+		}
+	}
+	r.path = strings.Join(segments, "/")
+}
+
+func escapePath(value string) string {
+	parts := strings.Split(value, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// AddQuery adds v to the query parameter key.
+func (r *Request) AddQuery(key string, v any) {
+	for _, value := range FormatValues(v) {
+		r.query.Add(key, value)
+	}
+}
+
+// AddHeader adds v to the header key.
+func (r *Request) AddHeader(key string, v any) {
+	for _, value := range FormatValues(v) {
+		r.header.Add(key, value)
+	}
+}
+
+// AddForm adds v to the form field key of an application/x-www-form-urlencoded or multipart/form-data
+// body. *multipart.FileHeader values are only sent in multipart bodies.
+func (r *Request) AddForm(key string, v any) {
+	if r.form == nil {
+		r.form = url.Values{}
+	}
+	switch x := v.(type) {
+	case *multipart.FileHeader:
+		if x != nil {
+			r.addFile(key, x)
+		}
+		return
+	case []*multipart.FileHeader:
+		for _, fh := range x {
+			r.addFile(key, fh)
+		}
+		return
+	}
+	for _, value := range FormatValues(v) {
+		r.form.Add(key, value)
+	}
+}
+
+func (r *Request) addFile(key string, fh *multipart.FileHeader) {
+	if r.files == nil {
+		r.files = make(map[string][]*multipart.FileHeader)
+	}
+	r.files[key] = append(r.files[key], fh)
+}
+
+// SetMultipart sends the form as a multipart/form-data body.
+func (r *Request) SetMultipart() {
+	r.multipart = true
+	if r.form == nil {
+		r.form = url.Values{}
+	}
+}
+
+// SetBody sends v marshaled by codec as the body.
+func (r *Request) SetBody(codec Codec, v any) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.SetRawBody(codec.ContentType(), bytes.NewReader(data))
+}
+
+// SetRawBody sends body as it is.
+func (r *Request) SetRawBody(contentType string, body io.Reader) {
+	r.contentType = contentType
+	r.body = body
+}
+
+func (r *Request) build(ctx context.Context, baseURL string) (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	body, contentType := r.body, r.contentType
+	if r.form != nil && body == nil {
+		var err error
+		body, contentType, err = r.formBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	target := baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+func (r *Request) formBody() (io.Reader, string, error) {
+	if !r.multipart {
+		return strings.NewReader(r.form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for key, values := range r.form {
+		for _, value := range values {
+			if err := w.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for key, files := range r.files {
+		for _, fh := range files {
+			if err := writeFile(w, key, fh); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+func writeFile(w *multipart.Writer, key string, fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	part, err := w.CreateFormFile(key, fh.Filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
+
+// Decoder decodes the response of a request.
+type Decoder func(resp *http.Response) error
+
+// DecodeBody unmarshals the response body into v with codec.
+func DecodeBody(codec Codec, v any) Decoder {
+	return func(resp *http.Response) error {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return codec.Unmarshal(data, v)
+	}
+}
+
+// DecodeSecureJSON unmarshals a @SecureJSONRender response, which may be prefixed with while(1);.
+func DecodeSecureJSON(v any) Decoder {
+	return func(resp *http.Response) error {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(bytes.TrimPrefix(data, []byte(secureJSONPrefix)), v)
+	}
+}
+
+// DecodeJSONP unmarshals a @JsonpJSONRender response, unwrapping the callback when there is one.
+func DecodeJSONP(v any) Decoder {
+	return func(resp *http.Response) error {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 && data[0] != '{' && data[0] != '[' {
+			if i := bytes.IndexByte(data, '('); i >= 0 {
+				data = bytes.TrimSuffix(bytes.TrimSuffix(data[i+1:], []byte(";")), []byte(")"))
+			}
+		}
+		return json.Unmarshal(data, v)
+	}
+}
+
+// DecodeBytes reads the response body into v.
+func DecodeBytes(v *[]byte) Decoder {
+	return func(resp *http.Response) error {
+		data, err := io.ReadAll(resp.Body)
+		*v = data
+		return err
+	}
+}
+
+// DecodeString reads the response body into v.
+func DecodeString(v *string) Decoder {
+	return func(resp *http.Response) error {
+		data, err := io.ReadAll(resp.Body)
+		*v = string(data)
+		return err
+	}
+}
+
+// DecodeReader hands the response body over to v, which the caller must close if it is an io.Closer.
+func DecodeReader(v *io.Reader) Decoder {
+	return func(resp *http.Response) error {
+		*v = resp.Body
+		resp.Body = http.NoBody
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package gors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientLimitsResponseBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte(strings.Repeat("x", 32)))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	var body string
+	if err := NewClient(srv.URL, WithMaxResponseBytes(32)).Do(ctx, NewRequest(http.MethodGet, "/ok"), DecodeString(&body)); err != nil || len(body) != 32 {
+		t.Errorf("Do within the limit = %v, %d bytes, want the body", err, len(body))
+	}
+	var tooLarge *http.MaxBytesError
+	if err := NewClient(srv.URL, WithMaxResponseBytes(16)).Do(ctx, NewRequest(http.MethodGet, "/ok"), DecodeString(&body)); !errors.As(err, &tooLarge) {
+		t.Errorf("Do beyond the limit = %v, want an *http.MaxBytesError", err)
+	}
+	var respErr *ResponseError
+	if err := NewClient(srv.URL, WithMaxResponseBytes(16)).Do(ctx, NewRequest(http.MethodGet, "/error"), nil); !errors.As(err, &respErr) || len(respErr.Body) != 16 {
+		t.Errorf("Do of an error response = %v, want its body truncated to the limit", err)
+	}
+	if err := NewClient(srv.URL, WithMaxResponseBytes(-1)).Do(ctx, NewRequest(http.MethodGet, "/ok"), DecodeString(&body)); err != nil || len(body) != 32 {
+		t.Errorf("Do unlimited = %v, %d bytes, want the body", err, len(body))
+	}
+	var stream io.Reader
+	if err := NewClient(srv.URL, WithMaxResponseBytes(16)).Stream(ctx, NewRequest(http.MethodGet, "/ok"), &stream); err != nil {
+		t.Fatalf("Stream beyond the limit = %v, want the body", err)
+	}
+	if data, err := io.ReadAll(stream); err != nil || len(data) != 32 {
+		t.Errorf("Stream read = %v, %d bytes, want the body not limited", err, len(data))
+	}
+	if err := NewClient(srv.URL, WithMaxResponseBytes(16)).Stream(ctx, NewRequest(http.MethodGet, "/error"), &stream); !errors.As(err, &respErr) || len(respErr.Body) != 16 {
+		t.Errorf("Stream of an error response = %v, want its body truncated to the limit", err)
+	}
+}
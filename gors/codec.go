@@ -0,0 +1,96 @@
+// Package gors is the runtime of the HTTP handlers and clients generated by gorsx from @GORS annotations.
+package gors
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec marshals and unmarshals request and response bodies of a media type.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	XMLCodec      Codec = xmlCodec{}
+	YAMLCodec     Codec = yamlCodec{}
+	TOMLCodec     Codec = tomlCodec{}
+	MsgPackCodec  Codec = msgPackCodec{}
+	ProtoBufCodec Codec = protoBufCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json; charset=utf-8" }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string { return "application/xml; charset=utf-8" }
+
+func (xmlCodec) Marshal(v any) ([]byte, error) { return xml.Marshal(v) }
+
+func (xmlCodec) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string { return "application/x-yaml; charset=utf-8" }
+
+func (yamlCodec) Marshal(v any) ([]byte, error) { return yaml.Marshal(v) }
+
+func (yamlCodec) Unmarshal(data []byte, v any) error { return yaml.Unmarshal(data, v) }
+
+type tomlCodec struct{}
+
+func (tomlCodec) ContentType() string { return "application/toml; charset=utf-8" }
+
+func (tomlCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (tomlCodec) Unmarshal(data []byte, v any) error { return toml.Unmarshal(data, v) }
+
+type msgPackCodec struct{}
+
+func (msgPackCodec) ContentType() string { return "application/x-msgpack" }
+
+func (msgPackCodec) Marshal(v any) ([]byte, error) { return msgpack.Marshal(v) }
+
+func (msgPackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+type protoBufCodec struct{}
+
+func (protoBufCodec) ContentType() string { return "application/x-protobuf" }
+
+func (protoBufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("gors: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoBufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gors: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,171 @@
+package gors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// problemContentType is the content type of RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// Error is an error with an HTTP status code, which handlers render as RFC 7807 problem details.
+// Services return it, or wrap it, to control the response of their errors.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code is a machine readable code of the error, such as "user_not_found".
+	Code string
+	// Message is a human readable explanation of the error.
+	Message string
+	// Details are extra information about the error, such as the fields that fail validation.
+	Details []any
+	// Cause is the underlying error, which is not exposed in the response.
+	Cause error
+}
+
+// NewError returns an error with the status code, code and message.
+func NewError(statusCode int, code string, message string) *Error {
+	return &Error{StatusCode: statusCode, Code: code, Message: message}
+}
+
+// Errorf returns an error with the status code, code and formatted message.
+func Errorf(statusCode int, code string, format string, args ...any) *Error {
+	return NewError(statusCode, code, fmt.Sprintf(format, args...))
+}
+
+// WithDetails returns a copy of e with details appended.
+func (e *Error) WithDetails(details ...any) *Error {
+	clone := *e
+	clone.Details = append(append([]any(nil), e.Details...), details...)
+	return &clone
+}
+
+// WithCause returns a copy of e caused by cause.
+func (e *Error) WithCause(cause error) *Error {
+	clone := *e
+	clone.Cause = cause
+	return &clone
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("gors: %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// problem is the RFC 7807 problem details of an Error.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Details  []any  `json:"details,omitempty"`
+}
+
+// ErrorMapper converts err to an Error, reporting whether it knows err.
+type ErrorMapper func(err error) (*Error, bool)
+
+// ToError converts err to an Error. An Error in the chain of err is used as it is. Otherwise, the
+// first mapper knowing err converts it. Binding errors become 400 Bad Request, bodies beyond their
+// limit 413, context errors 499 or 504, and the others 500 Internal Server Error, whose message does
+// not expose err.
+func ToError(err error, mappers ...ErrorMapper) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	for _, mapper := range mappers {
+		if e, ok := mapper(err); ok && e != nil {
+			return e
+		}
+	}
+	var bindErr *BindError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return NewError(http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit)).WithCause(err)
+	case errors.As(err, &bindErr):
+		e := NewError(http.StatusBadRequest, "bind_error", bindErr.Error())
+		if bindErr.Field != "" {
+			e.Details = []any{FieldViolation{Field: bindErr.Field, Source: bindErr.Source, Description: bindErr.Err.Error()}}
+		}
+		return e.WithCause(err)
+	case errors.Is(err, context.Canceled):
+		// the client closed the request
+		return NewError(499, "canceled", "request canceled").WithCause(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewError(http.StatusGatewayTimeout, "deadline_exceeded", "request deadline exceeded").WithCause(err)
+	}
+	return NewError(http.StatusInternalServerError, "internal", http.StatusText(http.StatusInternalServerError)).WithCause(err)
+}
+
+// FieldViolation is a detail of an Error, describing why a field of the request is invalid.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Source      string `json:"source,omitempty"`
+	Description string `json:"description"`
+}
+
+// WriteError writes e as application/problem+json, with the status 500 when e has none.
+func WriteError(w http.ResponseWriter, r *http.Request, e *Error) {
+	statusCode := e.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(statusCode),
+		Status:   statusCode,
+		Detail:   e.Message,
+		Instance: r.URL.Path,
+		Code:     e.Code,
+		Details:  e.Details,
+	}
+	if p.Title == "" {
+		p.Title = fmt.Sprintf("Status %d", statusCode)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, e.Message, statusCode)
+		return
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(data)
+}
+
+// DefaultErrorHandler writes err as application/problem+json, converted by ToError.
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	WriteError(w, r, ToError(err))
+}
+
+// decodeProblem decodes the problem details of an error response into an Error.
+func decodeProblem(statusCode int, body []byte) (*Error, bool) {
+	var p problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, false
+	}
+	if p.Status == 0 {
+		p.Status = statusCode
+	}
+	return &Error{StatusCode: p.Status, Code: p.Code, Message: p.Detail, Details: p.Details}, true
+}
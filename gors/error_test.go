@@ -0,0 +1,188 @@
+package gors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var errNotFound = errors.New("not found")
+
+func TestToError(t *testing.T) {
+	svcErr := NewError(http.StatusConflict, "user_exists", "user exists")
+	mapNotFound := func(err error) (*Error, bool) {
+		if errors.Is(err, errNotFound) {
+			return NewError(http.StatusNotFound, "not_found", "not found"), true
+		}
+		return nil, false
+	}
+	tests := []struct {
+		name        string
+		err         error
+		mappers     []ErrorMapper
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{name: "error", err: svcErr, wantStatus: http.StatusConflict, wantCode: "user_exists", wantMessage: "user exists"},
+		{name: "wrapped error", err: fmt.Errorf("create: %w", svcErr), mappers: []ErrorMapper{mapNotFound}, wantStatus: http.StatusConflict, wantCode: "user_exists", wantMessage: "user exists"},
+		{name: "mapped", err: fmt.Errorf("get: %w", errNotFound), mappers: []ErrorMapper{mapNotFound}, wantStatus: http.StatusNotFound, wantCode: "not_found", wantMessage: "not found"},
+		{name: "unknown to the mappers", err: errors.New("db down"), mappers: []ErrorMapper{mapNotFound}, wantStatus: http.StatusInternalServerError, wantCode: "internal", wantMessage: "Internal Server Error"},
+		{name: "bind error", err: &BindError{Source: "query", Field: "page", Err: strconv.ErrSyntax}, wantStatus: http.StatusBadRequest, wantCode: "bind_error", wantMessage: (&BindError{Source: "query", Field: "page", Err: strconv.ErrSyntax}).Error()},
+		{name: "body too large", err: &BindError{Source: "json", Err: &http.MaxBytesError{Limit: 8}}, wantStatus: http.StatusRequestEntityTooLarge, wantCode: "body_too_large", wantMessage: "request body larger than 8 bytes"},
+		{name: "canceled", err: fmt.Errorf("get: %w", context.Canceled), wantStatus: 499, wantCode: "canceled", wantMessage: "request canceled"},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, wantStatus: http.StatusGatewayTimeout, wantCode: "deadline_exceeded", wantMessage: "request deadline exceeded"},
+		{name: "internal", err: errors.New("password=secret"), wantStatus: http.StatusInternalServerError, wantCode: "internal", wantMessage: "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ToError(tt.err, tt.mappers...)
+			if e.StatusCode != tt.wantStatus || e.Code != tt.wantCode || e.Message != tt.wantMessage {
+				t.Errorf("ToError = %d %s %q, want %d %s %q", e.StatusCode, e.Code, e.Message, tt.wantStatus, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+
+	// the field that cannot be bound is detailed
+	e := ToError(&BindError{Source: "query", Field: "page", Err: strconv.ErrSyntax})
+	want := []any{FieldViolation{Field: "page", Source: "query", Description: strconv.ErrSyntax.Error()}}
+	if !reflect.DeepEqual(e.Details, want) {
+		t.Errorf("ToError details = %+v, want %+v", e.Details, want)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		// status is the status of the response, the one of err when zero
+		status int
+		want   map[string]any
+	}{
+		{
+			name: "details",
+			err: NewError(http.StatusUnprocessableEntity, "invalid", "invalid request").
+				WithDetails(FieldViolation{Field: "name", Description: "required"}).
+				WithCause(errors.New("not exposed")),
+			want: map[string]any{
+				"type":     "about:blank",
+				"title":    "Unprocessable Entity",
+				"status":   float64(http.StatusUnprocessableEntity),
+				"detail":   "invalid request",
+				"instance": "/users",
+				"code":     "invalid",
+				"details":  []any{map[string]any{"field": "name", "description": "required"}},
+			},
+		},
+		{
+			name: "non-standard status",
+			err:  NewError(499, "canceled", ""),
+			want: map[string]any{
+				"type":     "about:blank",
+				"title":    "Status 499",
+				"status":   float64(499),
+				"instance": "/users",
+				"code":     "canceled",
+			},
+		},
+		{
+			name:   "zero status",
+			err:    &Error{Message: "boom"},
+			status: http.StatusInternalServerError,
+			want: map[string]any{
+				"type":     "about:blank",
+				"title":    "Internal Server Error",
+				"status":   float64(http.StatusInternalServerError),
+				"detail":   "boom",
+				"instance": "/users",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, httptest.NewRequest(http.MethodPost, "/users?page=1", nil), tt.err)
+			status := tt.status
+			if status == 0 {
+				status = tt.err.StatusCode
+			}
+			if w.Code != status {
+				t.Errorf("status = %d, want %d", w.Code, status)
+			}
+			if got := w.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("Content-Type = %s, want %s", got, problemContentType)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteErrorFallback(t *testing.T) {
+	// details that cannot be encoded fall back to a plain text error
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest(http.MethodPost, "/users", nil), (&Error{Message: "boom"}).WithDetails(make(chan int)))
+	if w.Code != http.StatusInternalServerError || strings.TrimSpace(w.Body.String()) != "boom" {
+		t.Errorf("response = %d %q, want 500 boom", w.Code, w.Body.String())
+	}
+}
+
+func TestDecodeProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   *Error
+	}{
+		{
+			name:   "problem",
+			status: http.StatusNotFound,
+			body:   `{"type":"about:blank","title":"Not Found","status":404,"detail":"user 1 not found","code":"user_not_found","details":[{"field":"id"}]}`,
+			want:   &Error{StatusCode: http.StatusNotFound, Code: "user_not_found", Message: "user 1 not found", Details: []any{map[string]any{"field": "id"}}},
+		},
+		{
+			name:   "without status",
+			status: http.StatusBadGateway,
+			body:   `{"title":"Bad Gateway"}`,
+			want:   &Error{StatusCode: http.StatusBadGateway},
+		},
+		{name: "not json", status: http.StatusBadGateway, body: "<html>bad gateway</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := decodeProblem(tt.status, []byte(tt.body))
+			if ok != (tt.want != nil) {
+				t.Fatalf("decodeProblem ok = %v, want %v", ok, tt.want != nil)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeProblem = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerMappers(t *testing.T) {
+	o := NewOptions(WithErrorMapper(func(err error) (*Error, bool) {
+		if errors.Is(err, errNotFound) {
+			return NewError(http.StatusNotFound, "not_found", "not found"), true
+		}
+		return nil, false
+	}))
+	w := httptest.NewRecorder()
+	o.ErrorHandler(w, httptest.NewRequest(http.MethodGet, "/users/1", nil), fmt.Errorf("get: %w", errNotFound))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("ErrorHandler = %d %s, want the mapped error as problem+json", w.Code, w.Header().Get("Content-Type"))
+	}
+}
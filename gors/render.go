@@ -0,0 +1,176 @@
+package gors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+)
+
+// secureJSONPrefix prefixes @SecureJSONRender responses to prevent JSON hijacking.
+const secureJSONPrefix = "while(1);"
+
+// Renderer writes v as the response with the status code. It only returns the marshaling error,
+// in which case nothing has been written.
+type Renderer func(w http.ResponseWriter, r *http.Request, code int, v any) error
+
+// Render writes v marshaled by codec as the response with the status code. It only returns the
+// marshaling error, in which case nothing has been written.
+func Render(w http.ResponseWriter, code int, codec Codec, v any) error {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	writeData(w, code, codec.ContentType(), data)
+	return nil
+}
+
+// RenderJSON renders @JSONRender responses.
+func RenderJSON(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	return Render(w, code, JSONCodec, v)
+}
+
+// RenderIndentedJSON renders @IndentedJSONRender responses, indented for humans to read.
+func RenderIndentedJSON(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	writeData(w, code, JSONCodec.ContentType(), data)
+	return nil
+}
+
+// RenderSecureJSON renders @SecureJSONRender responses, prefixed with while(1); so that they cannot
+// be executed as scripts by other sites.
+func RenderSecureJSON(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	writeData(w, code, JSONCodec.ContentType(), append([]byte(secureJSONPrefix), data...))
+	return nil
+}
+
+// jsonpCallbackRegexp matches the names of the JSONP callbacks, such as cb or jQuery.cb_1, which cannot
+// inject a script.
+var jsonpCallbackRegexp = regexp.MustCompile(`^[A-Za-z_$][\w$.]*$`)
+
+// RenderJsonpJSON renders @JsonpJSONRender responses. The JSON is wrapped in a call of the function
+// named by the callback query parameter, if any. It is rendered as JSON when the callback is not the
+// name of a function.
+func RenderJsonpJSON(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	callback := r.URL.Query().Get("callback")
+	if !jsonpCallbackRegexp.MatchString(callback) {
+		writeData(w, code, JSONCodec.ContentType(), data)
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString(callback)
+	buf.WriteByte('(')
+	buf.Write(data)
+	buf.WriteString(");")
+	// the script is not sniffed as another type of content
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeData(w, code, "application/javascript; charset=utf-8", buf.Bytes())
+	return nil
+}
+
+// RenderPureJSON renders @PureJSONRender responses, which do not escape HTML characters.
+func RenderPureJSON(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	writeData(w, code, JSONCodec.ContentType(), buf.Bytes())
+	return nil
+}
+
+// RenderAsciiJSON renders @AsciiJSONRender responses, which escape non-ASCII characters.
+func RenderAsciiJSON(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for len(data) > 0 {
+		c, size := utf8.DecodeRune(data)
+		data = data[size:]
+		if c < utf8.RuneSelf {
+			buf.WriteByte(byte(c))
+			continue
+		}
+		if c > 0xFFFF {
+			// surrogate pair
+			c -= 0x10000
+			_, _ = fmt.Fprintf(&buf, "\\u%04x\\u%04x", 0xD800+(c>>10), 0xDC00+(c&0x3FF))
+			continue
+		}
+		_, _ = fmt.Fprintf(&buf, "\\u%04x", c)
+	}
+	writeData(w, code, JSONCodec.ContentType(), buf.Bytes())
+	return nil
+}
+
+// RenderXML renders @XMLRender responses.
+func RenderXML(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	return Render(w, code, XMLCodec, v)
+}
+
+// RenderYAML renders @YAMLRender responses.
+func RenderYAML(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	return Render(w, code, YAMLCodec, v)
+}
+
+// RenderTOML renders @TOMLRender responses.
+func RenderTOML(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	return Render(w, code, TOMLCodec, v)
+}
+
+// RenderMsgPack renders @MsgPackRender responses.
+func RenderMsgPack(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	return Render(w, code, MsgPackCodec, v)
+}
+
+// RenderProtoBuf renders @ProtoBufRender responses, v must be a proto.Message.
+func RenderProtoBuf(w http.ResponseWriter, r *http.Request, code int, v any) error {
+	return Render(w, code, ProtoBufCodec, v)
+}
+
+// RenderBytes writes data as it is, with the content type.
+func RenderBytes(w http.ResponseWriter, code int, contentType string, data []byte) {
+	writeData(w, code, contentType, data)
+}
+
+// RenderString writes s as it is, with the content type.
+func RenderString(w http.ResponseWriter, code int, contentType string, s string) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	_, _ = io.WriteString(w, s)
+}
+
+// RenderReader streams rd, with the content type. rd is closed if it is an io.Closer.
+func RenderReader(w http.ResponseWriter, code int, contentType string, rd io.Reader) {
+	if closer, ok := rd.(io.Closer); ok {
+		defer closer.Close()
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	if rd != nil {
+		_, _ = io.Copy(w, rd)
+	}
+}
+
+func writeData(w http.ResponseWriter, code int, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,53 @@
+package gors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenderJsonpJSON(t *testing.T) {
+	tests := []struct {
+		callback    string
+		body        string
+		contentType string
+		nosniff     bool
+	}{
+		{"", `{"name":"x"}`, "application/json; charset=utf-8", false},
+		{"cb", `cb({"name":"x"});`, "application/javascript; charset=utf-8", true},
+		{"jQuery.cb_1$", `jQuery.cb_1$({"name":"x"});`, "application/javascript; charset=utf-8", true},
+		{"alert(1);cb", `{"name":"x"}`, "application/json; charset=utf-8", false},
+		{"1cb", `{"name":"x"}`, "application/json; charset=utf-8", false},
+		{"</script>", `{"name":"x"}`, "application/json; charset=utf-8", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/x", nil)
+		r.URL.RawQuery = "callback=" + tt.callback
+		w := httptest.NewRecorder()
+		if err := RenderJsonpJSON(w, r, http.StatusOK, map[string]string{"name": "x"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Body.String(); got != tt.body {
+			t.Errorf("RenderJsonpJSON(callback=%s) = %s, want %s", tt.callback, got, tt.body)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("RenderJsonpJSON(callback=%s) Content-Type = %s, want %s", tt.callback, got, tt.contentType)
+		}
+		if got := w.Header().Get("X-Content-Type-Options") == "nosniff"; got != tt.nosniff {
+			t.Errorf("RenderJsonpJSON(callback=%s) nosniff = %v, want %v", tt.callback, got, tt.nosniff)
+		}
+	}
+}
+
+func TestRenderAsciiJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := RenderAsciiJSON(w, httptest.NewRequest(http.MethodGet, "/x", nil), http.StatusOK, map[string]string{"name": "gö😀"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Body.String(), `{"name":"g\u00f6\ud83d\ude00"}`; got != want {
+		t.Errorf("RenderAsciiJSON = %s, want %s", got, want)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/json; charset=utf-8"; got != want {
+		t.Errorf("RenderAsciiJSON Content-Type = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package gors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type greetReq struct {
+	ID   int    `uri:"id"`
+	Name string `form:"name"`
+}
+
+type greetResp struct {
+	ID       int
+	Greeting string
+}
+
+// greet is the service method of the round trips, failing for the id 0.
+func greet(ctx context.Context, req *greetReq) (*greetResp, error) {
+	if req.ID == 0 {
+		return nil, NewError(http.StatusNotFound, "user_not_found", "user 0 not found").
+			WithDetails(FieldViolation{Field: "id", Source: "uri", Description: "unknown user"})
+	}
+	return &greetResp{ID: req.ID, Greeting: "<héllo & 😀> " + req.Name}, nil
+}
+
+// greetHandler returns the handler gorsx generates for greet, rendering its response with render.
+func greetHandler(o *Options, render func(w http.ResponseWriter, r *http.Request, code int, v any) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.LimitBody(w, r)
+		req := new(greetReq)
+		if err := Bind(r, req, BindUri, BindQuery); err != nil {
+			o.ErrorHandler(w, r, err)
+			return
+		}
+		resp, err := greet(r.Context(), req)
+		if err != nil {
+			o.ErrorHandler(w, r, err)
+			return
+		}
+		if err := render(w, r, http.StatusOK, resp); err != nil {
+			o.ErrorHandler(w, r, err)
+		}
+	})
+}
+
+// serveRoutes serves routes on a mux, the way the generated AppendXxxServeMuxRoutes registers them.
+func serveRoutes(t *testing.T, routes []Route) *Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		route := route
+		mux.Handle(route.ServeMuxPattern(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route.Serve(w, r, func(name string, wildcard bool) string {
+				return r.PathValue(name)
+			})
+		}))
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL)
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	o := NewOptions()
+	tests := []struct {
+		name   string
+		render func(w http.ResponseWriter, r *http.Request, code int, v any) error
+		query  string
+		decode func(v any) Decoder
+	}{
+		{name: "json", render: RenderJSON, decode: func(v any) Decoder { return DecodeBody(JSONCodec, v) }},
+		{name: "indented json", render: RenderIndentedJSON, decode: func(v any) Decoder { return DecodeBody(JSONCodec, v) }},
+		{name: "secure json", render: RenderSecureJSON, decode: DecodeSecureJSON},
+		{name: "jsonp", render: RenderJsonpJSON, query: "cb", decode: DecodeJSONP},
+		{name: "jsonp without callback", render: RenderJsonpJSON, decode: DecodeJSONP},
+		{name: "pure json", render: RenderPureJSON, decode: func(v any) Decoder { return DecodeBody(JSONCodec, v) }},
+		{name: "ascii json", render: RenderAsciiJSON, decode: func(v any) Decoder { return DecodeBody(JSONCodec, v) }},
+		{name: "xml", render: RenderXML, decode: func(v any) Decoder { return DecodeBody(XMLCodec, v) }},
+		{name: "yaml", render: RenderYAML, decode: func(v any) Decoder { return DecodeBody(YAMLCodec, v) }},
+		{name: "toml", render: RenderTOML, decode: func(v any) Decoder { return DecodeBody(TOMLCodec, v) }},
+		{name: "msgpack", render: RenderMsgPack, decode: func(v any) Decoder { return DecodeBody(MsgPackCodec, v) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := serveRoutes(t, []Route{{Method: http.MethodGet, Path: "/users/:id/greeting", Handler: greetHandler(o, tt.render)}})
+			ctx := context.Background()
+
+			req := NewRequest(http.MethodGet, "/users/:id/greeting")
+			req.SetPathParam("id", 7)
+			req.AddQuery("name", "Ann")
+			if tt.query != "" {
+				req.AddQuery("callback", tt.query)
+			}
+			resp := new(greetResp)
+			if err := client.Do(ctx, req, tt.decode(resp)); err != nil {
+				t.Fatal(err)
+			}
+			want := &greetResp{ID: 7, Greeting: "<héllo & 😀> Ann"}
+			if !reflect.DeepEqual(resp, want) {
+				t.Errorf("Do = %+v, want %+v", resp, want)
+			}
+
+			// the service error reaches the client as it was returned, through problem+json
+			req = NewRequest(http.MethodGet, "/users/:id/greeting")
+			req.SetPathParam("id", 0)
+			var e *Error
+			if err := client.Do(ctx, req, tt.decode(new(greetResp))); !errors.As(err, &e) {
+				t.Fatalf("Do = %v, want an *Error", err)
+			}
+			if e.StatusCode != http.StatusNotFound || e.Code != "user_not_found" || e.Message != "user 0 not found" || len(e.Details) != 1 {
+				t.Errorf("Do = %+v, want the error of the service", e)
+			}
+
+			// so does the binding error
+			req = NewRequest(http.MethodGet, "/users/:id/greeting")
+			req.SetPathParam("id", "x")
+			if err := client.Do(ctx, req, tt.decode(new(greetResp))); !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest || e.Code != "bind_error" {
+				t.Errorf("Do = %v, want a 400 bind_error", err)
+			}
+		})
+	}
+}
+
+func TestClientRoundTripRaw(t *testing.T) {
+	const body = "<héllo & 😀>"
+	client := serveRoutes(t, []Route{
+		{Method: http.MethodPost, Path: "/bytes", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := BindBytes(r)
+			if err != nil {
+				DefaultErrorHandler(w, r, err)
+				return
+			}
+			RenderBytes(w, http.StatusOK, "application/octet-stream", req)
+		})},
+		{Method: http.MethodPost, Path: "/string", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := BindString(r)
+			if err != nil {
+				DefaultErrorHandler(w, r, err)
+				return
+			}
+			RenderString(w, http.StatusOK, "text/plain; charset=utf-8", req)
+		})},
+		{Method: http.MethodPost, Path: "/reader", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req io.Reader = r.Body
+			RenderReader(w, http.StatusOK, "application/octet-stream", req)
+		})},
+	})
+	ctx := context.Background()
+
+	req := NewRequest(http.MethodPost, "/bytes")
+	req.SetRawBody("application/octet-stream", strings.NewReader(body))
+	var data []byte
+	if err := client.Do(ctx, req, DecodeBytes(&data)); err != nil || string(data) != body {
+		t.Errorf("Do bytes = %q, %v, want %q", data, err, body)
+	}
+
+	req = NewRequest(http.MethodPost, "/string")
+	req.SetRawBody("text/plain; charset=utf-8", strings.NewReader(body))
+	var s string
+	if err := client.Do(ctx, req, DecodeString(&s)); err != nil || s != body {
+		t.Errorf("Do string = %q, %v, want %q", s, err, body)
+	}
+
+	req = NewRequest(http.MethodPost, "/reader")
+	req.SetRawBody("application/octet-stream", strings.NewReader(body))
+	var rd io.Reader
+	if err := client.Do(ctx, req, DecodeReader(&rd)); err != nil {
+		t.Fatal(err)
+	}
+	defer rd.(io.Closer).Close()
+	if data, err := io.ReadAll(rd); err != nil || string(data) != body {
+		t.Errorf("Do reader = %q, %v, want %q", data, err, body)
+	}
+}
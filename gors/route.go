@@ -0,0 +1,192 @@
+package gors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Route is a route of a service method, declared by its @GORS annotation.
+type Route struct {
+	Method string
+	// Path is the gin flavoured path of the route, whose parameters are :name or *name.
+	Path    string
+	Handler http.Handler
+}
+
+// PathParams returns the names of the parameters of the path.
+func (r Route) PathParams() []string {
+	var names []string
+	for _, segment := range strings.Split(r.Path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
+
+// ServeMuxPattern returns the http.ServeMux pattern of the route, such as "GET /users/{id}".
+func (r Route) ServeMuxPattern() string {
+	segments := strings.Split(r.Path, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "*"):
+			segments[i] = "{" + segment[1:] + "...}"
+		}
+	}
+	return r.Method + " " + strings.Join(segments, "/")
+}
+
+// ChiPattern returns the chi pattern of the route path, such as /users/{id} or /files/*.
+func (r Route) ChiPattern() string {
+	segments := strings.Split(r.Path, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "*"):
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// EchoPath returns the echo path of the route, such as /users/:id or /files/*.
+func (r Route) EchoPath() string {
+	segments := strings.Split(r.Path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "*") {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Serve serves req with the handler of the route, carrying the path parameters the router matched.
+// param returns the value of the parameter name, wildcard reports whether it is a *name parameter.
+func (r Route) Serve(w http.ResponseWriter, req *http.Request, param func(name string, wildcard bool) string) {
+	params := make(map[string]string)
+	for _, segment := range strings.Split(r.Path, "/") {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			params[segment[1:]] = param(segment[1:], false)
+		case strings.HasPrefix(segment, "*"):
+			params[segment[1:]] = strings.TrimPrefix(param(segment[1:], true), "/")
+		}
+	}
+	r.Handler.ServeHTTP(w, req.WithContext(WithPathParams(req.Context(), params)))
+}
+
+type pathParamsKey struct{}
+
+// WithPathParams returns a copy of ctx carrying the path parameters matched by the router.
+func WithPathParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, pathParamsKey{}, params)
+}
+
+// PathParams returns the path parameters carried by ctx.
+func PathParams(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(pathParamsKey{}).(map[string]string)
+	return params
+}
+
+// Options configures the handlers of a service.
+type Options struct {
+	// ErrorHandler writes the response of a binding or service error. By default, the error is
+	// converted by ToError with ErrorMappers and written as application/problem+json.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	// ErrorMappers convert the errors that are not an Error, in order.
+	ErrorMappers []ErrorMapper
+	// Middlewares are the middlewares the @Middleware annotations name.
+	Middlewares Middlewares
+	// Authorizer authorizes the calls of the @GORS methods declaring @Auth or @Permission.
+	Authorizer Authorizer
+	// MaxBodyBytes limits the bodies of the requests, DefaultMaxBodyBytes by default and unlimited when
+	// negative. The io.Reader params and the @FormMultipartBinding forms are streamed and not limited.
+	MaxBodyBytes int64
+}
+
+type Option func(o *Options)
+
+// WithErrorHandler sets the function writing the response of a binding or service error.
+func WithErrorHandler(h func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(o *Options) {
+		o.ErrorHandler = h
+	}
+}
+
+// WithErrorMapper appends mappers converting errors to an Error, for the default error handler.
+func WithErrorMapper(mappers ...ErrorMapper) Option {
+	return func(o *Options) {
+		o.ErrorMappers = append(o.ErrorMappers, mappers...)
+	}
+}
+
+// WithMaxBodyBytes sets the limit of the bodies of the requests, beyond which they are rejected with
+// 413 Request Entity Too Large, unlimited when n is negative. The streamed bodies are not limited.
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxBodyBytes = n
+	}
+}
+
+// WithMiddleware registers the middleware named by @Middleware annotations.
+func WithMiddleware(name string, m Middleware) Option {
+	return func(o *Options) {
+		if o.Middlewares == nil {
+			o.Middlewares = make(Middlewares)
+		}
+		o.Middlewares[name] = m
+	}
+}
+
+// WithMiddlewares registers the middlewares named by @Middleware annotations.
+func WithMiddlewares(middlewares Middlewares) Option {
+	return func(o *Options) {
+		for name, m := range middlewares {
+			WithMiddleware(name, m)(o)
+		}
+	}
+}
+
+func NewOptions(opts ...Option) *Options {
+	o := &Options{MaxBodyBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			WriteError(w, r, ToError(err, o.ErrorMappers...))
+		}
+	}
+	return o
+}
+
+// LimitBody limits the body of r to MaxBodyBytes, the reads beyond failing with an *http.MaxBytesError.
+func (o *Options) LimitBody(w http.ResponseWriter, r *http.Request) {
+	if o.MaxBodyBytes >= 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, o.MaxBodyBytes)
+	}
+}
+
+// Middleware wraps a handler with cross-cutting behavior.
+type Middleware func(next http.Handler) http.Handler
+
+// Middlewares is a registry of middlewares by name.
+type Middlewares map[string]Middleware
+
+// Wrap wraps h with the middlewares named by names, the first one outermost. It panics when a
+// middleware is not registered, so that a missing one fails at registration rather than being skipped.
+func (o *Options) Wrap(h http.Handler, names ...string) http.Handler {
+	for i := len(names) - 1; i >= 0; i-- {
+		m, ok := o.Middlewares[names[i]]
+		if !ok || m == nil {
+			panic(fmt.Sprintf("gors: middleware %q is not registered, register it with gors.WithMiddleware", names[i]))
+		}
+		h = m(h)
+	}
+	return h
+}
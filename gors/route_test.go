@@ -0,0 +1,59 @@
+package gors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutePatterns(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		serveMux string
+		chi      string
+		echo     string
+	}{
+		{http.MethodGet, "/", "GET /", "/", "/"},
+		{http.MethodGet, "/users", "GET /users", "/users", "/users"},
+		{http.MethodGet, "/users/:id", "GET /users/{id}", "/users/{id}", "/users/:id"},
+		{http.MethodPut, "/users/:id/posts/:post", "PUT /users/{id}/posts/{post}", "/users/{id}/posts/{post}", "/users/:id/posts/:post"},
+		{http.MethodGet, "/files/*path", "GET /files/{path...}", "/files/*", "/files/*"},
+		{http.MethodDelete, "/users/:id/files/*path", "DELETE /users/{id}/files/{path...}", "/users/{id}/files/*", "/users/:id/files/*"},
+	}
+	for _, tt := range tests {
+		r := Route{Method: tt.method, Path: tt.path}
+		if got := r.ServeMuxPattern(); got != tt.serveMux {
+			t.Errorf("ServeMuxPattern(%s %s) = %q, want %q", tt.method, tt.path, got, tt.serveMux)
+		}
+		if got := r.ChiPattern(); got != tt.chi {
+			t.Errorf("ChiPattern(%s) = %q, want %q", tt.path, got, tt.chi)
+		}
+		if got := r.EchoPath(); got != tt.echo {
+			t.Errorf("EchoPath(%s) = %q, want %q", tt.path, got, tt.echo)
+		}
+	}
+}
+
+func TestServeMuxPatternRegisters(t *testing.T) {
+	route := Route{Method: http.MethodGet, Path: "/users/:id/files/*path"}
+	route.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := PathParams(r.Context())
+		_, _ = w.Write([]byte(params["id"] + " " + params["path"]))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc(route.ServeMuxPattern(), func(w http.ResponseWriter, r *http.Request) {
+		route.Serve(w, r, func(name string, wildcard bool) string { return r.PathValue(name) })
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42/files/a/b.txt", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "42 a/b.txt" {
+		t.Errorf("GET = %d %q, want 200 %q", w.Code, w.Body.String(), "42 a/b.txt")
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/42/files/a", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
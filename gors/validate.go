@@ -0,0 +1,65 @@
+package gors
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strings"
+)
+
+// Validator is implemented by requests validating themselves. The handlers of @Validate methods call
+// Validate after binding a request implementing it, instead of checking its validate struct tags.
+type Validator interface {
+	Validate() error
+}
+
+// FieldViolations are the fields of a request failing validation.
+type FieldViolations []FieldViolation
+
+func (v FieldViolations) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, violation := range v {
+		msgs = append(msgs, violation.Field+" "+violation.Description)
+	}
+	return "gors: invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Err returns the violations as a 400 Bad Request Error, or nil when there is none.
+func (v FieldViolations) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return InvalidArgument(v)
+}
+
+// InvalidArgument converts the error returned by the validation of a request to a 400 Bad Request
+// Error. An Error in the chain of err is used as it is, FieldViolations become its details.
+func InvalidArgument(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var violations FieldViolations
+	if errors.As(err, &violations) {
+		details := make([]any, 0, len(violations))
+		for _, violation := range violations {
+			details = append(details, violation)
+		}
+		e := NewError(http.StatusBadRequest, "invalid_argument", "request validation failed")
+		e.Details = details
+		return e.WithCause(err)
+	}
+	return NewError(http.StatusBadRequest, "invalid_argument", err.Error()).WithCause(err)
+}
+
+// IsEmail reports whether s is a bare email address, such as gopher@example.com.
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// IsZero reports whether v is the zero value of its type.
+func IsZero(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
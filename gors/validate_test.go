@@ -0,0 +1,94 @@
+package gors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFieldViolations(t *testing.T) {
+	var none FieldViolations
+	if err := none.Err(); err != nil {
+		t.Errorf("Err of no violation = %v, want nil", err)
+	}
+	violations := FieldViolations{
+		{Field: "name", Source: "json", Description: "is required"},
+		{Field: "page", Source: "query", Description: "must be at most 100"},
+	}
+	const want = "gors: invalid request: name is required; page must be at most 100"
+	if got := violations.Error(); got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+	var e *Error
+	if !errors.As(violations.Err(), &e) || e.StatusCode != http.StatusBadRequest || len(e.Details) != 2 {
+		t.Fatalf("Err = %v, want a 400 Error detailing the violations", violations.Err())
+	}
+	if e.Details[0] != violations[0] || e.Details[1] != violations[1] {
+		t.Errorf("Err details = %+v, want %+v", e.Details, violations)
+	}
+}
+
+func TestInvalidArgument(t *testing.T) {
+	conflict := NewError(http.StatusConflict, "name_taken", "name taken")
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantMessage string
+		wantDetails int
+	}{
+		{name: "error", err: fmt.Errorf("validate: %w", conflict), wantStatus: http.StatusConflict, wantMessage: "name taken"},
+		{name: "violations", err: fmt.Errorf("validate: %w", FieldViolations{{Field: "name", Description: "is required"}}), wantStatus: http.StatusBadRequest, wantMessage: "request validation failed", wantDetails: 1},
+		{name: "other error", err: errors.New("name is required"), wantStatus: http.StatusBadRequest, wantMessage: "name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := InvalidArgument(tt.err)
+			if e.StatusCode != tt.wantStatus || e.Message != tt.wantMessage || len(e.Details) != tt.wantDetails {
+				t.Errorf("InvalidArgument = %d %q %d details, want %d %q %d details", e.StatusCode, e.Message, len(e.Details), tt.wantStatus, tt.wantMessage, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"gopher@example.com", true},
+		{"gopher.go@mail.example.com", true},
+		{"", false},
+		{"gopher", false},
+		{"gopher@", false},
+		{"Gopher <gopher@example.com>", false},
+		{" gopher@example.com", false},
+	}
+	for _, tt := range tests {
+		if got := IsEmail(tt.s); got != tt.want {
+			t.Errorf("IsEmail(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	tests := []struct {
+		v    any
+		want bool
+	}{
+		{nil, true},
+		{time.Time{}, true},
+		{struct{ A int }{}, true},
+		{[2]int{}, true},
+		{time.Unix(1, 0), false},
+		{struct{ A int }{A: 1}, false},
+		{[2]int{0, 1}, false},
+	}
+	for _, tt := range tests {
+		if got := IsZero(tt.v); got != tt.want {
+			t.Errorf("IsZero(%v) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
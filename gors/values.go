@@ -0,0 +1,74 @@
+package gors
+
+import (
+	"encoding"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+// FormatValues formats v as the string values of a path parameter, query parameter, header or
+// form field. Slices and arrays give one value per element and nil pointers give no value.
+func FormatValues(v any) []string {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Type() == fileHeaderType {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		values := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, FormatValues(rv.Index(i).Interface())...)
+		}
+		return values
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		if _, ok := v.(encoding.TextMarshaler); !ok {
+			return FormatValues(rv.Elem().Interface())
+		}
+	}
+	s, ok := formatValue(rv)
+	if !ok {
+		return nil
+	}
+	return []string{s}
+}
+
+func formatValue(rv reflect.Value) (string, bool) {
+	switch x := rv.Interface().(type) {
+	case time.Time:
+		return x.Format(time.RFC3339Nano), true
+	case time.Duration:
+		return x.String(), true
+	case encoding.TextMarshaler:
+		text, err := x.MarshalText()
+		return string(text), err == nil
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32), true
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), true
+	case reflect.Slice:
+		// []byte
+		return string(rv.Bytes()), true
+	}
+	return fmt.Sprint(rv.Interface()), true
+}
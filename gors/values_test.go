@@ -0,0 +1,102 @@
+package gors
+
+import (
+	"context"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatValues(t *testing.T) {
+	name := "x"
+	var nilName *string
+	at := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	tests := []struct {
+		name string
+		v    any
+		want []string
+	}{
+		{"nil", nil, nil},
+		{"string", "a b", []string{"a b"}},
+		{"bool", true, []string{"true"}},
+		{"int", -42, []string{"-42"}},
+		{"uint8", uint8(7), []string{"7"}},
+		{"float32", float32(0.1), []string{"0.1"}},
+		{"float64", 1e21, []string{"1e+21"}},
+		{"bytes", []byte("raw"), []string{"raw"}},
+		{"time", at, []string{"2024-01-02T03:04:05.0000006Z"}},
+		{"duration", 90 * time.Second, []string{"1m30s"}},
+		{"text marshaler", net.ParseIP("127.0.0.1"), []string{"127.0.0.1"}},
+		{"pointer", &name, []string{"x"}},
+		{"nil pointer", nilName, nil},
+		{"pointer to a text marshaler", &at, []string{"2024-01-02T03:04:05.0000006Z"}},
+		{"slice", []int{1, 2}, []string{"1", "2"}},
+		{"array", [2]string{"a", "b"}, []string{"a", "b"}},
+		{"slice of pointers", []*string{&name, nil, &name}, []string{"x", "x"}},
+		{"empty slice", []string{}, []string{}},
+		{"file", &multipart.FileHeader{Filename: "a.png"}, nil},
+	}
+	for _, tt := range tests {
+		if got := FormatValues(tt.v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FormatValues(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetPathParam(t *testing.T) {
+	tests := []struct {
+		path  string
+		name  string
+		value any
+		want  string
+	}{
+		{"/users/:id", "id", 42, "/users/42"},
+		{"/users/:id/posts", "id", "a/b c", "/users/a%2Fb%20c/posts"},
+		{"/files/*path", "path", "/img/a b.png", "/files/img/a%20b.png"},
+		{"/users/:id", "other", 1, "/users/:id"},
+	}
+	for _, tt := range tests {
+		r := NewRequest(http.MethodGet, tt.path)
+		r.SetPathParam(tt.name, tt.value)
+		if r.path != tt.want {
+			t.Errorf("SetPathParam(%s, %s, %v) = %s, want %s", tt.path, tt.name, tt.value, r.path, tt.want)
+		}
+	}
+}
+
+// TestFormatValuesBind checks that the values formatted by the client are bound back by the handlers.
+func TestFormatValuesBind(t *testing.T) {
+	type query struct {
+		Name  string        `form:"name"`
+		Age   int           `form:"age"`
+		Tags  []string      `form:"tags"`
+		At    time.Time     `form:"at"`
+		Wait  time.Duration `form:"wait"`
+		Ok    *bool         `form:"ok"`
+		Score float64       `form:"score"`
+	}
+	ok := true
+	want := query{Name: "a&b=c", Age: 30, Tags: []string{"x", "y z"}, At: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), Wait: time.Minute, Ok: &ok, Score: 0.5}
+	r := NewRequest(http.MethodGet, "/users")
+	r.AddQuery("name", want.Name)
+	r.AddQuery("age", want.Age)
+	r.AddQuery("tags", want.Tags)
+	r.AddQuery("at", want.At)
+	r.AddQuery("wait", want.Wait)
+	r.AddQuery("ok", want.Ok)
+	r.AddQuery("score", want.Score)
+	req, err := r.build(context.Background(), "http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got query
+	if err := BindQuery(req, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) || !got.At.Equal(want.At) {
+		t.Errorf("BindQuery(%s) = %+v, want %+v", req.URL.RawQuery, got, want)
+	}
+}
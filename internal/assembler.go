@@ -25,12 +25,12 @@ func NewAssemblerCore(isQuery bool, funcName string, ToParamsIdent *Param, ToRes
 }
 
 const templateAssemblerTo = `
-func %sTo(in *%s) *%s {
+func %sTo(in %s) %s {
 	panic("to implemented")
 }
 `
 const templateAssemblerFrom = `
-func %sFrom(in *%s) *%s {
+func %sFrom(in %s) %s {
 	panic("to implemented")
 }
 `
@@ -45,23 +45,29 @@ func (c *AssemblerCore) Gen() string {
 }
 
 func (c *AssemblerCore) GenTextTo() string {
-	reqObj := c.ToParamsIdent.ObjectArgs
-	respObj := c.ToResultIdent.ObjectArgs
-	return fmt.Sprintf(templateAssemblerTo,
-		c.FuncName,
-		reqObj.GoImportPath.Ident(reqObj.Name).Qualify(),
-		respObj.GoImportPath.Ident(respObj.Name).Qualify(),
-	)
+	reqType, _ := c.ToParamsIdent.GoType()
+	respType, _ := c.ToResultIdent.GoType()
+	return fmt.Sprintf(templateAssemblerTo, c.FuncName, reqType, respType)
 }
 
 func (c *AssemblerCore) GenTextFrom() string {
-	reqObj := c.FromParamsIdent.ObjectArgs
-	respObj := c.FromResultIdent.ObjectArgs
-	return fmt.Sprintf(templateAssemblerFrom,
-		c.FuncName,
-		reqObj.GoImportPath.Ident(reqObj.Name).Qualify(),
-		respObj.GoImportPath.Ident(respObj.Name).Qualify(),
-	)
+	reqType, _ := c.FromParamsIdent.GoType()
+	respType, _ := c.FromResultIdent.GoType()
+	return fmt.Sprintf(templateAssemblerFrom, c.FuncName, reqType, respType)
+}
+
+// ImportsTo returns the imports referenced by the generated To function.
+func (c *AssemblerCore) ImportsTo() []*GoImport {
+	_, paramImports := c.ToParamsIdent.GoType()
+	_, resultImports := c.ToResultIdent.GoType()
+	return append(paramImports, resultImports...)
+}
+
+// ImportsFrom returns the imports referenced by the generated From function.
+func (c *AssemblerCore) ImportsFrom() []*GoImport {
+	_, paramImports := c.FromParamsIdent.GoType()
+	_, resultImports := c.FromResultIdent.GoType()
+	return append(paramImports, resultImports...)
 }
 
 func (c *AssemblerCore) GetFuncNameTo() string {
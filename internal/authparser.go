@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/samber/lo"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Auth requires the caller of an endpoint to be authenticated, with any of the roles it names such as
+	// @Auth(roles=admin,editor). On the service, it is the default of every endpoint.
+	Auth annotation = "@Auth"
+	// Permission requires the caller of an endpoint to have every permission it names, such as
+	// @Permission(keyword:delete). On the service, it is the default of every endpoint.
+	Permission annotation = "@Permission"
+	// Public exempts an endpoint from the @Auth and @Permission of the service.
+	Public annotation = "@Public"
+)
+
+var (
+	authRegexp       = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Auth)) + `(?:\(([^)]*)\))?(?:\s|$)`)
+	permissionRegexp = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Permission)) + `\(([^)]*)\)`)
+)
+
+// AuthAnnotation is the authorization of an endpoint, declared by @Auth(roles=admin,editor) and
+// @Permission(keyword:delete) on a @GORS or @CQRS line. The impl body of a @CQRS method checks it,
+// the handler of a @GORS method without @CQRS does otherwise.
+type AuthAnnotation struct {
+	// Roles are the roles of which the caller needs any, none when empty.
+	Roles []string
+	// Permissions are the permissions the caller needs all of.
+	Permissions []string
+}
+
+// NewAuthAnnotation parses the @Auth and @Permission annotations of the service named name, the
+// defaults of its endpoints. It returns nil when the service declares none.
+func NewAuthAnnotation(name string, comments []string) *AuthAnnotation {
+	if isPublic(comments) {
+		log.Fatalf("error: service %s %s is only allowed for methods", name, Public)
+	}
+	auth, _, _ := parseAuth("service "+name, comments)
+	return auth
+}
+
+// Override returns the authorization of the endpoint named name, whose own @Auth and @Permission
+// replace the ones of the service, a. It returns nil when the endpoint is @Public or neither declares any.
+func (a *AuthAnnotation) Override(name string, comments []string) *AuthAnnotation {
+	auth, hasRoles, hasPermissions := parseAuth("func "+name, comments)
+	if isPublic(comments) {
+		if auth != nil {
+			log.Fatalf("error: func %s %s conflicts with %s and %s", name, Public, Auth, Permission)
+		}
+		return nil
+	}
+	if a == nil {
+		return auth
+	}
+	if auth == nil {
+		auth = &AuthAnnotation{}
+	}
+	if !hasRoles {
+		auth.Roles = a.Roles
+	}
+	if !hasPermissions {
+		auth.Permissions = a.Permissions
+	}
+	return auth
+}
+
+// Literal returns the literal of the requirement of type typ, such as gors.AuthRequirement, of the
+// authorization of endpoint.
+func (a *AuthAnnotation) Literal(typ string, endpoint string) string {
+	literal := fmt.Sprintf("&%s{Endpoint: %q", typ, endpoint)
+	if len(a.Roles) > 0 {
+		literal += ", Roles: " + stringSlice(a.Roles)
+	}
+	if len(a.Permissions) > 0 {
+		literal += ", Permissions: " + stringSlice(a.Permissions)
+	}
+	return literal + "}"
+}
+
+// stringSlice returns the []string literal of values.
+func stringSlice(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// isPublic reports whether a @GORS or @CQRS line of comments carries @Public.
+func isPublic(comments []string) bool {
+	return hasAnnotation(comments, GORS, Public) || hasAnnotation(comments, CQRS, Public)
+}
+
+// parseAuth parses the @Auth and @Permission annotations of the @GORS and @CQRS lines of comments,
+// reporting which ones are declared.
+func parseAuth(owner string, comments []string) (auth *AuthAnnotation, hasRoles bool, hasPermissions bool) {
+	for _, args := range annotationArgs(comments, authRegexp, GORS, CQRS) {
+		if hasRoles {
+			log.Fatalf("error: %s declares %s more than once", owner, Auth)
+		}
+		hasRoles = true
+		auth = &AuthAnnotation{}
+		if args = strings.TrimSpace(args); args == "" {
+			continue
+		}
+		key, roles, ok := strings.Cut(args, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "roles") {
+			log.Fatalf("error: %s %s(%s) invalid, want @Auth(roles=admin,editor)", owner, Auth, args)
+		}
+		for _, role := range strings.Split(roles, ",") {
+			if role = strings.TrimSpace(role); role != "" && !lo.Contains(auth.Roles, role) {
+				auth.Roles = append(auth.Roles, role)
+			}
+		}
+	}
+	for _, args := range annotationArgs(comments, permissionRegexp, GORS, CQRS) {
+		positional, named := splitArgs(args)
+		if len(positional) == 0 || len(named) > 0 {
+			log.Fatalf("error: %s %s(%s) invalid, want @Permission(keyword:delete)", owner, Permission, args)
+		}
+		hasPermissions = true
+		if auth == nil {
+			auth = &AuthAnnotation{}
+		}
+		for _, permission := range positional {
+			if !lo.Contains(auth.Permissions, permission) {
+				auth.Permissions = append(auth.Permissions, permission)
+			}
+		}
+	}
+	return auth, hasRoles, hasPermissions
+}
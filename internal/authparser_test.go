@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuthAnnotationOverride(t *testing.T) {
+	service := []string{
+		"// Users",
+		"// @GORS @Path(/users)",
+		"// @CQRS @Auth(roles=admin, editor) @Permission(users:read)",
+	}
+	tests := []struct {
+		name     string
+		service  []string
+		comments []string
+		want     *AuthAnnotation
+	}{
+		{
+			name: "no annotations",
+			comments: []string{
+				"// @GORS @GET @Path(/x)",
+			},
+			want: nil,
+		},
+		{
+			name:     "method only, no comments",
+			comments: nil,
+			want:     nil,
+		},
+		{
+			name: "method roles on @GORS line",
+			comments: []string{
+				"// @GORS @GET @Path(/x) @Auth(roles=admin)",
+			},
+			want: &AuthAnnotation{Roles: []string{"admin"}},
+		},
+		{
+			name: "bare @Auth requires authentication only",
+			comments: []string{
+				"// @CQRS @Command @Auth",
+			},
+			want: &AuthAnnotation{},
+		},
+		{
+			name: "permissions deduplicated across annotations",
+			comments: []string{
+				"// @CQRS @Command @Permission(keyword:delete, keyword:read) @Permission(keyword:delete)",
+			},
+			want: &AuthAnnotation{Permissions: []string{"keyword:delete", "keyword:read"}},
+		},
+		{
+			name: "annotations outside @GORS and @CQRS lines are ignored",
+			comments: []string{
+				"// Delete deletes the keyword, see @Auth(roles=admin).",
+			},
+			want: nil,
+		},
+		{
+			name:     "inherits the service defaults",
+			service:  service,
+			comments: []string{"// @CQRS @Query"},
+			want:     &AuthAnnotation{Roles: []string{"admin", "editor"}, Permissions: []string{"users:read"}},
+		},
+		{
+			name:     "undocumented method inherits the service defaults",
+			service:  service,
+			comments: nil,
+			want:     &AuthAnnotation{Roles: []string{"admin", "editor"}, Permissions: []string{"users:read"}},
+		},
+		{
+			name:     "method roles replace the service roles only",
+			service:  service,
+			comments: []string{"// @CQRS @Command @Auth(roles=owner)"},
+			want:     &AuthAnnotation{Roles: []string{"owner"}, Permissions: []string{"users:read"}},
+		},
+		{
+			name:     "bare method @Auth drops the service roles",
+			service:  service,
+			comments: []string{"// @CQRS @Command @Auth"},
+			want:     &AuthAnnotation{Permissions: []string{"users:read"}},
+		},
+		{
+			name:     "method permissions replace the service permissions only",
+			service:  service,
+			comments: []string{"// @GORS @DELETE @Path(/:id) @Permission(users:delete)"},
+			want:     &AuthAnnotation{Roles: []string{"admin", "editor"}, Permissions: []string{"users:delete"}},
+		},
+		{
+			name:     "@Public on the @CQRS line exempts the method",
+			service:  service,
+			comments: []string{"// @CQRS @Query @Public"},
+			want:     nil,
+		},
+		{
+			name:     "@Public on the @GORS line exempts the method",
+			service:  service,
+			comments: []string{"// @GORS @GET @Path(/health) @public"},
+			want:     nil,
+		},
+		{
+			name:     "@Public without service defaults",
+			comments: []string{"// @GORS @GET @Path(/health) @Public"},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := NewAuthAnnotation("Users", tt.service)
+			got := defaults.Override("Method", tt.comments)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Override() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthAnnotation(t *testing.T) {
+	if got := NewAuthAnnotation("Users", []string{"// @CQRS @QueryPath(./query)"}); got != nil {
+		t.Errorf("NewAuthAnnotation() = %+v, want nil", got)
+	}
+	got := NewAuthAnnotation("Users", []string{"// @GORS @Path(/users) @Auth(roles=admin)", "// @CQRS @Permission(users:read)"})
+	want := &AuthAnnotation{Roles: []string{"admin"}, Permissions: []string{"users:read"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewAuthAnnotation() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAuthAnnotationLiteral(t *testing.T) {
+	tests := []struct {
+		auth *AuthAnnotation
+		want string
+	}{
+		{&AuthAnnotation{}, `&gors.AuthRequirement{Endpoint: "Users.Get"}`},
+		{&AuthAnnotation{Roles: []string{"admin"}}, `&gors.AuthRequirement{Endpoint: "Users.Get", Roles: []string{"admin"}}`},
+		{
+			&AuthAnnotation{Roles: []string{"admin", "editor"}, Permissions: []string{"users:read"}},
+			`&gors.AuthRequirement{Endpoint: "Users.Get", Roles: []string{"admin", "editor"}, Permissions: []string{"users:read"}}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.auth.Literal("gors.AuthRequirement", "Users.Get"); got != tt.want {
+			t.Errorf("Literal() = %s, want %s", got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package internal
 
 import (
+	"github.com/samber/lo"
+	"go/token"
 	"log"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -23,6 +26,23 @@ const (
 	AssemblerPath  annotation = "@AssemblerPath"
 	ServicePath    annotation = "@ServicePath"
 	GOBasePath     annotation = "@GoBasePath"
+	// Transactional runs a command in a transaction.
+	Transactional annotation = "@Transactional"
+	// Cache caches the results of a query, such as @Cache(ttl=30s,key=Field1+Field2).
+	Cache annotation = "@Cache"
+	// Invalidate deletes the cached results of a query once a command succeeds, such as
+	// @Invalidate(Query,key=Field1+Field2).
+	Invalidate annotation = "@Invalidate"
+	// Idempotent replays the outcome of a command for the requests carrying the same idempotency key,
+	// such as @Idempotent(header=Idempotency-Key,ttl=24h).
+	Idempotent annotation = "@Idempotent"
+	// Async enqueues a command, which a worker handles later.
+	Async annotation = "@Async"
+	// Emits publishes the events of a command once it succeeds, such as @Emits(OrderCreated,OrderPaid).
+	Emits annotation = "@Emits"
+	// Log logs the payloads of an endpoint, masking the fields it names in nested structs too, such as
+	// @Log(redact=Password).
+	Log annotation = "@Log"
 )
 
 func (a annotation) String() string {
@@ -131,17 +151,219 @@ func NewFileFromComment(
 		}
 		for _, s := range seg {
 			s = strings.TrimSpace(s)
+			var file *CQRSFile
 			switch {
 			case Query.EqualsIgnoreCase(s):
-				return NewQueryFile(endpoint, queryDir, queryRela, NamePrefix)
+				file = NewQueryFile(endpoint, queryDir, queryRela, NamePrefix)
 			case Command.EqualsIgnoreCase(s):
-				return NewCommandFile(endpoint, commandDir, commandRela, NamePrefix)
+				file = NewCommandFile(endpoint, commandDir, commandRela, NamePrefix)
+			default:
+				continue
 			}
+			file.Log, file.Redact = parseLog(comments)
+			file.Transactional = hasAnnotation(comments, CQRS, Transactional)
+			if file.Transactional && file.IsQuery() {
+				log.Fatalf("error: func %s %s is only allowed for @Command", endpoint, Transactional)
+			}
+			file.Cache = parseCache(endpoint, comments)
+			if file.Cache != nil && file.IsCommand() {
+				log.Fatalf("error: func %s %s is only allowed for @Query", endpoint, Cache)
+			}
+			file.Invalidates = parseInvalidates(endpoint, comments)
+			if len(file.Invalidates) > 0 && file.IsQuery() {
+				log.Fatalf("error: func %s %s is only allowed for @Command", endpoint, Invalidate)
+			}
+			file.Async = hasAnnotation(comments, CQRS, Async)
+			if file.Async && file.IsQuery() {
+				log.Fatalf("error: func %s %s is only allowed for @Command", endpoint, Async)
+			}
+			file.Emits = parseEmits(endpoint, comments)
+			if len(file.Emits) > 0 && file.IsQuery() {
+				log.Fatalf("error: func %s %s is only allowed for @Command", endpoint, Emits)
+			}
+			file.Idempotent = parseIdempotent(endpoint, comments)
+			if file.Idempotent != nil && file.IsQuery() {
+				log.Fatalf("error: func %s %s is only allowed for @Command", endpoint, Idempotent)
+			}
+			if file.Idempotent != nil && file.Async {
+				// the outcome of an enqueued command is not known when it is accepted
+				log.Fatalf("error: func %s %s is not allowed with %s", endpoint, Idempotent, Async)
+			}
+			return file
+		}
+	}
+	return nil
+}
+
+// CacheAnnotation is the caching of the results of a query, declared by @Cache(ttl=30s,key=Field1+Field2).
+type CacheAnnotation struct {
+	TTL time.Duration
+	// Key are the fields of the query the key of a result is derived from, all of them when empty.
+	Key []string
+}
+
+// Invalidation is a query whose cached results a command invalidates, declared by
+// @Invalidate(Query,key=Field1+Field2).
+type Invalidation struct {
+	Endpoint string
+	// Key are the fields of the command the key of the result is derived from, the key fields of the
+	// query when empty.
+	Key []string
+}
+
+// IdempotentAnnotation is the idempotency of a command, declared by
+// @Idempotent(header=Idempotency-Key,ttl=24h).
+type IdempotentAnnotation struct {
+	// Header is the header of the requests carrying the idempotency key.
+	Header string
+	// TTL is how long the outcome of a request is replayed.
+	TTL time.Duration
+}
+
+var (
+	cacheRegexp      = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Cache)) + `\(([^)]*)\)`)
+	invalidateRegexp = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Invalidate)) + `\(([^)]*)\)`)
+	emitsRegexp      = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Emits)) + `\(([^)]*)\)`)
+	idempotentRegexp = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Idempotent)) + `(?:\(([^)]*)\))?(?:\s|$)`)
+)
+
+func parseCache(endpoint string, comments []string) *CacheAnnotation {
+	for _, args := range cqrsAnnotationArgs(comments, cacheRegexp) {
+		positional, named := splitArgs(args)
+		if len(positional) > 0 {
+			log.Fatalf("error: func %s %s(%s) invalid, want @Cache(ttl=30s,key=Field1+Field2)", endpoint, Cache, args)
+		}
+		ttl, err := time.ParseDuration(named["ttl"])
+		if err != nil || ttl <= 0 {
+			log.Fatalf("error: func %s %s(%s) ttl invalid, want a positive duration such as ttl=30s", endpoint, Cache, args)
+		}
+		return &CacheAnnotation{TTL: ttl, Key: splitKey(named["key"])}
+	}
+	return nil
+}
+
+func parseInvalidates(endpoint string, comments []string) []*Invalidation {
+	var invalidations []*Invalidation
+	for _, args := range cqrsAnnotationArgs(comments, invalidateRegexp) {
+		positional, named := splitArgs(args)
+		if len(positional) != 1 {
+			log.Fatalf("error: func %s %s(%s) invalid, want @Invalidate(Query,key=Field1+Field2)", endpoint, Invalidate, args)
+		}
+		invalidations = append(invalidations, &Invalidation{Endpoint: positional[0], Key: splitKey(named["key"])})
+	}
+	return invalidations
+}
+
+func parseEmits(endpoint string, comments []string) []string {
+	var events []string
+	for _, args := range cqrsAnnotationArgs(comments, emitsRegexp) {
+		positional, named := splitArgs(args)
+		if len(positional) == 0 || len(named) > 0 {
+			log.Fatalf("error: func %s %s(%s) invalid, want @Emits(Event1,Event2)", endpoint, Emits, args)
+		}
+		for _, event := range positional {
+			if !token.IsExported(event) || !token.IsIdentifier(event) {
+				log.Fatalf("error: func %s %s(%s) event %s invalid, want an exported type name", endpoint, Emits, args, event)
+			}
+			if !lo.Contains(events, event) {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+func parseIdempotent(endpoint string, comments []string) *IdempotentAnnotation {
+	for _, args := range cqrsAnnotationArgs(comments, idempotentRegexp) {
+		positional, named := splitArgs(args)
+		if len(positional) > 0 {
+			log.Fatalf("error: func %s %s(%s) invalid, want @Idempotent(header=Idempotency-Key,ttl=24h)", endpoint, Idempotent, args)
 		}
+		idempotent := &IdempotentAnnotation{Header: "Idempotency-Key", TTL: 24 * time.Hour}
+		if header, ok := named["header"]; ok {
+			idempotent.Header = header
+		}
+		if ttl, ok := named["ttl"]; ok {
+			d, err := time.ParseDuration(ttl)
+			if err != nil || d <= 0 {
+				log.Fatalf("error: func %s %s(%s) ttl invalid, want a positive duration such as ttl=24h", endpoint, Idempotent, args)
+			}
+			idempotent.TTL = d
+		}
+		if idempotent.Header == "" {
+			log.Fatalf("error: func %s %s(%s) header invalid, want a header such as header=Idempotency-Key", endpoint, Idempotent, args)
+		}
+		return idempotent
 	}
 	return nil
 }
 
+// cqrsAnnotationArgs returns the arguments of the annotations matched by re on the @CQRS lines of comments.
+func cqrsAnnotationArgs(comments []string, re *regexp.Regexp) []string {
+	return annotationArgs(comments, re, CQRS)
+}
+
+// splitArgs splits the comma separated arguments of an annotation into the positional ones and the
+// named ones, name=value, whose names are lower cased.
+func splitArgs(args string) ([]string, map[string]string) {
+	var positional []string
+	named := make(map[string]string)
+	for _, arg := range strings.Split(args, ",") {
+		arg = strings.TrimSpace(arg)
+		if arg == "" {
+			continue
+		}
+		if name, value, ok := strings.Cut(arg, "="); ok {
+			named[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	return positional, named
+}
+
+// splitKey splits the fields of a key, such as Field1+Field2.
+func splitKey(key string) []string {
+	var fields []string
+	for _, field := range strings.Split(key, "+") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+var logRegexp = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Log)) + `(\(([^)]*)\))?(\s|$)`)
+
+// parseLog reports whether a @CQRS line of comments carries @Log, and returns the fields it redacts.
+func parseLog(comments []string) (bool, []string) {
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "//"))
+		seg := strings.Fields(text)
+		if len(seg) == 0 || !CQRS.EqualsIgnoreCase(seg[0]) {
+			continue
+		}
+		match := logRegexp.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		var redact []string
+		if args := strings.TrimSpace(match[2]); args != "" {
+			key, fields, ok := strings.Cut(args, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), "redact") {
+				log.Fatalf("error: %s invalid, want @Log(redact=field1,field2)", match[0])
+			}
+			for _, field := range strings.Split(fields, ",") {
+				if field = strings.TrimSpace(field); field != "" {
+					redact = append(redact, field)
+				}
+			}
+		}
+		return true, redact
+	}
+	return false, nil
+}
+
 func NewQueryFile(endpoint string, queryDir, relaPath string, prefix string) *CQRSFile {
 	fn := strings.ToLower(addUnderscore(endpoint)) + ".go"
 	if prefix != "" {
@@ -1,9 +1,14 @@
 package internal
 
 import (
+	"bytes"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"errors"
+	"go/format"
 	"os"
+	"path"
 	"text/template"
 )
 
@@ -14,12 +19,42 @@ var commandContent string
 var queryContent string
 
 type CQRSFile struct {
+	// Service is the service declaring the endpoint.
+	Service       string
 	Type          string
 	RelaPath      string
 	AbsFilename   string
 	Package       string
 	Endpoint      string
 	LowerEndpoint string
+	// Log reports whether the payloads of the endpoint are logged, declared by @Log.
+	Log bool
+	// Redact names the fields of the payloads masked in the logs, declared by @Log(redact=...).
+	Redact []string
+	// Transactional reports whether the command runs in a transaction, declared by @Transactional.
+	Transactional bool
+	// Cache is the caching of the results of the query, declared by @Cache.
+	Cache *CacheAnnotation
+	// Invalidates are the queries whose cached results the command invalidates, declared by @Invalidate.
+	Invalidates []*Invalidation
+	// Idempotent is the idempotency of the command, declared by @Idempotent.
+	Idempotent *IdempotentAnnotation
+	// Async reports whether the command is enqueued and handled later by a worker, declared by @Async.
+	Async bool
+	// Emits are the events the command publishes once it succeeds, declared by @Emits.
+	Emits []string
+	// KeyFields are the fields of the query or the command the keys of @Cache and @Invalidate are
+	// derived from, declared in its generated struct.
+	KeyFields []*KeyField
+	// Imports are the import paths the types of KeyFields require.
+	Imports []string
+}
+
+// KeyField is a field of a query or a command a cache key is derived from.
+type KeyField struct {
+	Name string
+	// Type is the type expression of the field, such as string or time.Time.
+	Type string
 }
 
 func (v CQRSFile) GetReqName() string {
@@ -29,6 +64,11 @@ func (v CQRSFile) GetReqName() string {
 	return v.Endpoint + "Query"
 }
 
+// JobName returns the name of the jobs of the @Async command, such as Service.Endpoint.
+func (v CQRSFile) JobName() string {
+	return v.Service + "." + v.Endpoint
+}
+
 func (v CQRSFile) IsQuery() bool {
 	return v.Type == "query"
 }
@@ -44,6 +84,11 @@ func (v CQRSFile) GetRespName() string {
 	return v.Endpoint + "Result"
 }
 
+// GoImportPath returns the import path of the handler package, resolved against the base import path.
+func (v CQRSFile) GoImportPath(base string) GoImportPath {
+	return GoImportPath(path.Join(base, v.RelaPath))
+}
+
 func (v CQRSFile) Gen() error {
 	if v.RelaPath == "" {
 		return errors.New("@QueryPath or @CommandPath is empty")
@@ -56,18 +101,53 @@ func (v CQRSFile) Gen() error {
 	return errors.New("unknown endpoint type")
 }
 
-func (v CQRSFile) genQuery() error {
-	tmpl, err := template.New("query").Parse(queryContent)
+// checksumPrefix starts the first line of a handler file, followed by the checksum of the rest of the
+// file as it is generated.
+const checksumPrefix = "// gorsx: checksum "
+
+// Content renders the handler file of the endpoint, headed by its checksum.
+func (v CQRSFile) Content() ([]byte, error) {
+	tmpl, err := v.template()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &v); err != nil {
+		return nil, err
+	}
+	body, err := format.Source(buf.Bytes())
+	if err != nil {
+		// written as is, for the compiler to report the error
+		body = buf.Bytes()
 	}
-	_, err = os.Stat(v.AbsFilename)
+	body = append([]byte("\n"), body...)
+	return append([]byte(checksumPrefix+checksum(body)+"\n"), body...), nil
+}
+
+// Untouched reports whether src, the source of a handler file, is unchanged since it was generated, as
+// the checksum heading it says. The files generated without a checksum are reported as changed.
+func Untouched(src []byte) bool {
+	line, body, _ := bytes.Cut(src, []byte("\n"))
+	sum, ok := bytes.CutPrefix(line, []byte(checksumPrefix))
+	return ok && string(sum) == checksum(body)
+}
+
+func checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func (v CQRSFile) template() (*template.Template, error) {
+	if v.IsCommand() {
+		return template.New("command").Parse(commandContent)
+	}
+	return template.New("query").Parse(queryContent)
+}
+
+func (v CQRSFile) genQuery() error {
+	_, err := os.Stat(v.AbsFilename)
 	if os.IsNotExist(err) {
-		file, err := os.Create(v.AbsFilename)
-		if err != nil {
-			return err
-		}
-		return tmpl.Execute(file, &v)
+		return v.create()
 	}
 	if err != nil {
 		return err
@@ -76,20 +156,21 @@ func (v CQRSFile) genQuery() error {
 }
 
 func (v CQRSFile) genCommand() error {
-	tmpl, err := template.New("command").Parse(commandContent)
-	if err != nil {
-		return err
-	}
-	_, err = os.Stat(v.AbsFilename)
+	_, err := os.Stat(v.AbsFilename)
 	if os.IsNotExist(err) {
-		file, err := os.Create(v.AbsFilename)
-		if err != nil {
-			return err
-		}
-		return tmpl.Execute(file, &v)
+		return v.create()
 	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// create writes the handler file.
+func (v CQRSFile) create() error {
+	content, err := v.Content()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(v.AbsFilename, content, 0o644)
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"path"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -44,6 +46,9 @@ type GoImport struct {
 type ObjectArgs struct {
 	Name         string
 	GoImportPath GoImportPath
+	// Type is the resolved type of the object. When it is nil, the object is a pointer to the
+	// struct named Name in GoImportPath.
+	Type types.Type
 }
 
 type Param struct {
@@ -51,6 +56,9 @@ type Param struct {
 	String     bool
 	ObjectArgs *ObjectArgs
 	Reader     bool
+	// ImportNames are the names the packages are imported by in the service file, by their import
+	// paths, which qualify the type instead of the package names.
+	ImportNames map[string]string
 }
 
 type Result struct {
@@ -58,55 +66,235 @@ type Result struct {
 	String     bool
 	ObjectArgs *ObjectArgs
 	Reader     bool
+	// ImportNames are the names the packages are imported by in the service file, by their import
+	// paths, which qualify the type instead of the package names.
+	ImportNames map[string]string
 }
 
-func NewMethodInfo(methodName string, t *ast.FuncType) *FuncInfo {
+// NewParam resolves the transport kind of a param type.
+func NewParam(t types.Type) (*Param, bool) {
+	isBytes, isString, isReader, objectArgs, ok := transportKind(t)
+	if !ok {
+		return nil, false
+	}
+	return &Param{Bytes: isBytes, String: isString, Reader: isReader, ObjectArgs: objectArgs}, true
+}
+
+// NewResult resolves the transport kind of a result type.
+func NewResult(t types.Type) (*Result, bool) {
+	isBytes, isString, isReader, objectArgs, ok := transportKind(t)
+	if !ok {
+		return nil, false
+	}
+	return &Result{Bytes: isBytes, String: isString, Reader: isReader, ObjectArgs: objectArgs}, true
+}
+
+func transportKind(t types.Type) (isBytes, isString, isReader bool, objectArgs *ObjectArgs, ok bool) {
+	switch x := t.(type) {
+	case *types.Basic:
+		return false, x.Kind() == types.String, false, nil, x.Kind() == types.String
+	case *types.Slice:
+		if types.Identical(x.Elem(), types.Typ[types.Byte]) {
+			return true, false, false, nil, true
+		}
+		if _, _, _, _, ok := transportKind(x.Elem()); !ok {
+			return false, false, false, nil, false
+		}
+		return false, false, false, &ObjectArgs{Type: t}, true
+	case *types.Map:
+		return false, false, false, &ObjectArgs{Type: t}, true
+	case *types.Pointer:
+		named, ok := x.Elem().(*types.Named)
+		if !ok {
+			return false, false, false, nil, false
+		}
+		return false, false, false, newObjectArgs(named, t), true
+	case *types.Named:
+		if isNamed(x, "io", "Reader") {
+			return false, false, true, nil, true
+		}
+		switch x.Underlying().(type) {
+		case *types.Interface, *types.Signature, *types.Chan:
+			return false, false, false, nil, false
+		}
+		return false, false, false, newObjectArgs(x, t), true
+	}
+	return false, false, false, nil, false
+}
+
+func newObjectArgs(named *types.Named, t types.Type) *ObjectArgs {
+	obj := named.Obj()
+	objectArgs := &ObjectArgs{Name: obj.Name(), Type: t}
+	if obj.Pkg() != nil {
+		objectArgs.GoImportPath = GoImportPath(obj.Pkg().Path())
+	}
+	return objectArgs
+}
+
+func isNamed(t types.Type, pkgPath string, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
+
+// GoType returns the type expression of the param and the imports it requires.
+func (p *Param) GoType() (string, []*GoImport) {
+	return goType(p.Bytes, p.String, p.Reader, p.ObjectArgs, p.ImportNames)
+}
+
+// GoType returns the type expression of the result and the imports it requires.
+func (r *Result) GoType() (string, []*GoImport) {
+	return goType(r.Bytes, r.String, r.Reader, r.ObjectArgs, r.ImportNames)
+}
+
+func goType(isBytes, isString, isReader bool, objectArgs *ObjectArgs, importNames map[string]string) (string, []*GoImport) {
+	switch {
+	case isBytes:
+		return "[]byte", nil
+	case isString:
+		return "string", nil
+	case isReader:
+		ident := GoImportPath("io").Ident("Reader")
+		if name, ok := importNames["io"]; ok {
+			ident.GoImport.PackageName = name
+		}
+		return ident.Qualify(), []*GoImport{ident.GoImport}
+	case objectArgs != nil && objectArgs.Type != nil:
+		var imports []*GoImport
+		expr := types.TypeString(objectArgs.Type, func(pkg *types.Package) string {
+			name, ok := importNames[pkg.Path()]
+			if !ok {
+				name = pkg.Name()
+			}
+			imports = append(imports, &GoImport{PackageName: name, ImportPath: pkg.Path()})
+			return name
+		})
+		return expr, imports
+	case objectArgs != nil:
+		ident := objectArgs.GoImportPath.Ident(objectArgs.Name)
+		if ident.GoImport.ImportPath == "" {
+			return "*" + ident.Qualify(), nil
+		}
+		return "*" + ident.Qualify(), []*GoImport{ident.GoImport}
+	}
+	return "", nil
+}
+
+func NewMethodInfo(methodName string, t *ast.FuncType, sig *types.Signature) *FuncInfo {
 	return &FuncInfo{
-		FuncName: methodName,
-		FuncType: t,
+		FuncName:  methodName,
+		FuncType:  t,
+		Signature: sig,
+		CtxName:   "ctx",
+		ReqName:   "req",
+		ResName:   "res",
+		ErrName:   "err",
 	}
 }
 
 func NewRPCMethodInfo(methodName string) *FuncInfo {
-	return &FuncInfo{FuncName: methodName}
+	return &FuncInfo{
+		FuncName: methodName,
+		CtxName:  "ctx",
+		ReqName:  "req",
+		ResName:  "res",
+		ErrName:  "err",
+	}
 }
 
 type FuncInfo struct {
 	FuncName  string
 	FuncType  *ast.FuncType
-	Param2    *Param
+	Signature *types.Signature
+	// ImportNames are the names the packages are imported by in the service file, by their import
+	// paths, which the param and result types are qualified with.
+	ImportNames map[string]string
+	CtxName     string
+	ReqName     string
+	ResName     string
+	ErrName     string
+	Param2      *Param
+	// Result1 is nil when the method only returns an error.
 	Result1   *Result
 	CQRS      *CQRSFile
 	Assembler *AssemblerCore
+	Route     *Route
+	// Auth is the authorization of the method, declared by @Auth and @Permission on the method or the
+	// service, nil for the @Public methods. The handler of the @GORS method checks it before the
+	// request reaches the implementation.
+	Auth *AuthAnnotation
+	// Comments is the doc comment of the method.
+	Comments []string
 }
 
-const bodyQuery = `resp, err := provider.%s.Handle(ctx, assembler.%s(req))
-	if err != nil {
+const bodyQuery = `%s, %s := %s.%s.Handle(%s, %s.%s(%s))
+	if %s != nil {
 		return 
 	}
-	return assembler.%s(resp), nil`
+	return %s.%s(%s), nil`
 
-const bodyCommand = `err = provider.%s.Handle(ctx, assembler.%s(req))
-	if err != nil {
+const bodyCommand = `%s = %s.%s.Handle(%s, %s.%s(%s))
+	if %s != nil {
 		return 
 	}
 	return `
 
-func (f *FuncInfo) GenBody() string {
+const bodyErrorCommand = `return %s.%s.Handle(%s, %s.%s(%s))`
+
+const bodyAsyncCommand = `%s = %s.Enqueue(%s, %s.commands.Queue, %q, %s.%s(%s))
+	if %s != nil {
+		return 
+	}
+	return `
+
+const bodyAsyncErrorCommand = `return %s.Enqueue(%s, %s.commands.Queue, %q, %s.%s(%s))`
+
+// GenBody returns the body of the service implementation method, calling the bus through the
+// receiver recv and converting transport objects with the functions of assemblerPkg. The command of an
+// @Async method is enqueued with the Enqueue of cqrsxPkg instead.
+func (f *FuncInfo) GenBody(recv string, assemblerPkg string, cqrsxPkg string) string {
 	if f.CQRS == nil {
 		return "return"
 	}
 	cqrsCall := f.CQRS.Endpoint
-	tmpl := bodyQuery
 	if f.CQRS.IsQuery() {
 		cqrsCall = "queries." + cqrsCall
-		return fmt.Sprintf(tmpl, cqrsCall, f.Assembler.GetFuncNameTo(), f.Assembler.GetFuncNameFrom())
+		resp := f.localName("resp")
+		return fmt.Sprintf(bodyQuery,
+			resp, f.ErrName, recv, cqrsCall, f.CtxName, assemblerPkg, f.Assembler.GetFuncNameTo(), f.ReqName,
+			f.ErrName,
+			assemblerPkg, f.Assembler.GetFuncNameFrom(), resp)
+	}
+	if f.CQRS.Async {
+		if f.Result1 == nil {
+			return fmt.Sprintf(bodyAsyncErrorCommand,
+				cqrsxPkg, f.CtxName, recv, f.CQRS.JobName(), assemblerPkg, f.Assembler.GetFuncNameTo(), f.ReqName)
+		}
+		return fmt.Sprintf(bodyAsyncCommand,
+			f.ErrName, cqrsxPkg, f.CtxName, recv, f.CQRS.JobName(), assemblerPkg, f.Assembler.GetFuncNameTo(), f.ReqName,
+			f.ErrName)
 	}
 	cqrsCall = "commands." + cqrsCall
-	tmpl = bodyCommand
-	return fmt.Sprintf(tmpl, cqrsCall, f.Assembler.GetFuncNameTo())
+	if f.Result1 == nil {
+		return fmt.Sprintf(bodyErrorCommand, recv, cqrsCall, f.CtxName, assemblerPkg, f.Assembler.GetFuncNameTo(), f.ReqName)
+	}
+	return fmt.Sprintf(bodyCommand,
+		f.ErrName, recv, cqrsCall, f.CtxName, assemblerPkg, f.Assembler.GetFuncNameTo(), f.ReqName,
+		f.ErrName)
 }
 
+// localName returns name, or a variant of it, that does not collide with the method's params and results.
+func (f *FuncInfo) localName(name string) string {
+	for name == f.CtxName || name == f.ReqName || name == f.ResName || name == f.ErrName {
+		name = "_" + name
+	}
+	return name
+}
+
+// Check validates the method signature and resolves its transport param and result.
 func (f *FuncInfo) Check() error {
 	err := f.checkParams()
 	if err != nil {
@@ -116,48 +304,87 @@ func (f *FuncInfo) Check() error {
 }
 
 func (f *FuncInfo) checkParams() error {
-	if f.FuncType.Params == nil {
+	params := f.Signature.Params()
+	if params.Len() == 0 {
 		return fmt.Errorf("error: func %s params is empty", f.FuncName)
 	}
-	if len(f.FuncType.Params.List) != 2 {
+	if params.Len() != 2 {
 		return fmt.Errorf("error: func %s params count is not equal 2", f.FuncName)
 	}
-	param1 := f.FuncType.Params.List[0]
-	param0SelectorExpr, ok := param1.Type.(*ast.SelectorExpr)
-	if !ok {
+	if !isNamed(params.At(0).Type(), "context", "Context") {
 		return fmt.Errorf("error: func %s 1th param is not context.Context", f.FuncName)
 	}
-	if param0SelectorExpr.Sel.Name != "Context" {
-		return fmt.Errorf("error: func %s 1th param is not context.Context", f.FuncName)
-	}
-	param0SelectorExprX, ok := param0SelectorExpr.X.(*ast.Ident)
+	param2, ok := NewParam(params.At(1).Type())
 	if !ok {
-		return fmt.Errorf("error: func %s 1th param is not context.Context", f.FuncName)
-	}
-	if param0SelectorExprX.Name != "context" {
-		return fmt.Errorf("error: func %s 1th param is not context.Context", f.FuncName)
+		return fmt.Errorf("error: func %s 2th param is invalid, must be []byte or string or io.Reader or struct{}", f.FuncName)
 	}
+	param2.ImportNames = f.ImportNames
+	f.Param2 = param2
+	f.CtxName = paramName(params.At(0), f.CtxName)
+	f.ReqName = paramName(params.At(1), f.ReqName)
 	return nil
 }
 
 func (f *FuncInfo) checkResults() error {
-	if f.FuncType.Results == nil {
+	results := f.Signature.Results()
+	if results.Len() == 0 {
 		return fmt.Errorf("error: func %s results is empty", f.FuncName)
 	}
-	if len(f.FuncType.Results.List) != 2 {
-		return fmt.Errorf("error: func %s results count is not equal 2", f.FuncName)
+	if results.Len() > 2 {
+		return fmt.Errorf("error: func %s results count is greater than 2", f.FuncName)
+	}
+	last := results.At(results.Len() - 1)
+	if !types.Identical(last.Type(), types.Universe.Lookup("error").Type()) {
+		return fmt.Errorf("error: func %s %dth result is not error", f.FuncName, results.Len())
 	}
-	result2 := f.FuncType.Results.List[1]
-	result2Iden, ok := result2.Type.(*ast.Ident)
+	f.ErrName = paramName(last, f.ErrName)
+	if results.Len() == 1 {
+		return nil
+	}
+	result1, ok := NewResult(results.At(0).Type())
 	if !ok {
-		return fmt.Errorf("error: func %s 2th result is not error", f.FuncName)
+		return fmt.Errorf("error: func %s 1th result is invalid, must be []byte or string or io.Reader or struct{}", f.FuncName)
 	}
-	if result2Iden.Name != "error" {
-		return fmt.Errorf("error: func %s 2th result is not error", f.FuncName)
+	result1.ImportNames = f.ImportNames
+	f.Result1 = result1
+	f.ResName = paramName(results.At(0), f.ResName)
+	return nil
+}
+
+// CheckCQRS validates the method signature against the @Query or @Command annotation of cqrs, only the
+// commands being allowed to only return an error.
+func (f *FuncInfo) CheckCQRS(cqrs *CQRSFile) error {
+	if cqrs.IsQuery() && f.Result1 == nil {
+		return fmt.Errorf("error: func %s only returns error, which is only allowed for @Command", f.FuncName)
 	}
 	return nil
 }
 
+// ImportNames returns the names the packages are imported by in file, by their import paths, the
+// names of known or the ones assumed from the paths for the unnamed imports. The blank and dot
+// imports are skipped.
+func ImportNames(known map[string]string, file *ast.File) map[string]string {
+	names := importNames(known, file.Imports)
+	for _, spec := range file.Imports {
+		if spec.Name == nil {
+			continue
+		}
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || spec.Name.Name == "_" || spec.Name.Name == "." {
+			continue
+		}
+		names[importPath] = spec.Name.Name
+	}
+	return names
+}
+
+func paramName(v *types.Var, defaultName string) string {
+	if v.Name() == "" || v.Name() == "_" {
+		return defaultName
+	}
+	return v.Name()
+}
+
 func CleanPackageName(name string) string {
 	name = strings.Map(func(r rune) rune {
 		if unicode.IsLetter(r) || unicode.IsDigit(r) {
@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"strings"
+	"testing"
+)
+
+// newNamed returns the type named name in the package of path, whose underlying type is underlying.
+func newNamed(path, name string, underlying types.Type) *types.Named {
+	pkg := types.NewPackage(path, path[strings.LastIndex(path, "/")+1:])
+	return types.NewNamed(types.NewTypeName(0, pkg, name, nil), underlying, nil)
+}
+
+func TestNewParamGoType(t *testing.T) {
+	reader := newNamed("io", "Reader", types.NewInterfaceType(nil, nil))
+	req := newNamed("github.com/go-miya/gorsx/example", "CreateReq", types.NewStruct(nil, nil))
+	tests := []struct {
+		name        string
+		typ         types.Type
+		invalid     bool
+		want        string
+		wantImports []string
+	}{
+		{name: "bytes", typ: types.NewSlice(types.Typ[types.Byte]), want: "[]byte"},
+		{name: "string", typ: types.Typ[types.String], want: "string"},
+		{name: "reader", typ: reader, want: "io.Reader", wantImports: []string{"io"}},
+		{name: "struct pointer", typ: types.NewPointer(req), want: "*example.CreateReq", wantImports: []string{"github.com/go-miya/gorsx/example"}},
+		{name: "struct", typ: req, want: "example.CreateReq", wantImports: []string{"github.com/go-miya/gorsx/example"}},
+		{name: "slice of struct pointers", typ: types.NewSlice(types.NewPointer(req)), want: "[]*example.CreateReq", wantImports: []string{"github.com/go-miya/gorsx/example"}},
+		{name: "map", typ: types.NewMap(types.Typ[types.String], types.Typ[types.Int]), want: "map[string]int"},
+		{name: "int", typ: types.Typ[types.Int], invalid: true},
+		{name: "pointer to a basic type", typ: types.NewPointer(types.Typ[types.String]), invalid: true},
+		{name: "slice of ints", typ: types.NewSlice(types.Typ[types.Int]), invalid: true},
+		{name: "interface", typ: newNamed("github.com/go-miya/gorsx/example", "Keyword", types.NewInterfaceType(nil, nil)), invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param, ok := NewParam(tt.typ)
+			result, resultOk := NewResult(tt.typ)
+			if ok != resultOk {
+				t.Fatalf("NewParam ok = %v, NewResult ok = %v, want the same transport kinds", ok, resultOk)
+			}
+			if ok == tt.invalid {
+				t.Fatalf("NewParam ok = %v, want %v", ok, !tt.invalid)
+			}
+			if tt.invalid {
+				return
+			}
+			for _, goType := range []func() (string, []*GoImport){param.GoType, result.GoType} {
+				got, imports := goType()
+				if got != tt.want {
+					t.Errorf("GoType = %s, want %s", got, tt.want)
+				}
+				var paths []string
+				for _, imp := range imports {
+					paths = append(paths, imp.ImportPath)
+				}
+				if strings.Join(paths, ",") != strings.Join(tt.wantImports, ",") {
+					t.Errorf("GoType imports = %v, want %v", paths, tt.wantImports)
+				}
+			}
+		})
+	}
+}
+
+// loadFuncs returns the FuncInfos of the methods of the Service interface of the testdata package
+// checkfunc, by their names, qualified with the imports of its file.
+func loadFuncs(t *testing.T) map[string]*FuncInfo {
+	t.Helper()
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax}
+	pkgs, err := packages.Load(cfg, "./testdata/checkfunc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].Errors) > 0 || len(pkgs[0].Syntax) != 1 {
+		t.Fatalf("loading checkfunc = %v", pkgs)
+	}
+	pkg := pkgs[0]
+	importNames := ImportNames(PackageNames(pkg), pkg.Syntax[0])
+	iface := pkg.Types.Scope().Lookup("Service").Type().Underlying().(*types.Interface)
+	funcs := make(map[string]*FuncInfo)
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		info := NewMethodInfo(method.Name(), nil, method.Type().(*types.Signature))
+		info.ImportNames = importNames
+		funcs[method.Name()] = info
+	}
+	return funcs
+}
+
+func TestFuncInfoCheck(t *testing.T) {
+	funcs := loadFuncs(t)
+	tests := []struct {
+		method string
+		// err is the start of the error of Check, or of CheckCQRS of a query when query is set
+		err   string
+		query bool
+		req   string
+		resp  string
+		// imports are the imports of the param type
+		imports []string
+		names   [4]string
+	}{
+		{
+			method:  "Aliased",
+			req:     "*model.Req",
+			resp:    "*model.Resp",
+			imports: []string{"model github.com/go-miya/gorsx/internal/testdata/checkfunc/model"},
+			names:   [4]string{"ctx", "req", "res", "err"},
+		},
+		{method: "Value", req: "model.Req", resp: "model.Resp", names: [4]string{"ctx", "req", "res", "err"}},
+		{method: "List", req: "*model.Req", resp: "[]*model.Resp", names: [4]string{"ctx", "req", "res", "err"}},
+		{method: "Stream", req: "stdio.Reader", resp: "stdio.Reader", imports: []string{"stdio io"}, names: [4]string{"ctx", "req", "res", "err"}},
+		{method: "ErrorOnly", req: "*model.Req", names: [4]string{"ctx", "req", "res", "err"}},
+		{method: "ErrorOnly", query: true, err: "error: func ErrorOnly only returns error, which is only allowed for @Command"},
+		{method: "Named", req: "*model.Req", resp: "*othermodel.Resp", names: [4]string{"c", "in", "out", "e"}},
+		{
+			method:  "Collide",
+			req:     "*othermodel.Req",
+			resp:    "*model.Resp",
+			imports: []string{"othermodel github.com/go-miya/gorsx/internal/testdata/checkfunc/other/model"},
+			names:   [4]string{"ctx", "req", "res", "err"},
+		},
+		{method: "NoContext", err: "error: func NoContext 1th param is not context.Context"},
+		{method: "IntParam", err: "error: func IntParam 2th param is invalid"},
+		{method: "NoError", err: "error: func NoError 1th result is not error"},
+		{method: "Chan", err: "error: func Chan 1th result is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			info := funcs[tt.method]
+			err := info.Check()
+			if err == nil && tt.query {
+				err = info.CheckCQRS(&CQRSFile{Type: "query"})
+			}
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("Check() = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check() = %v", err)
+			}
+			if err := info.CheckCQRS(&CQRSFile{Type: "command"}); err != nil {
+				t.Errorf("CheckCQRS(command) = %v", err)
+			}
+			req, reqImports := info.Param2.GoType()
+			if req != tt.req {
+				t.Errorf("param GoType = %s, want %s", req, tt.req)
+			}
+			resp := ""
+			if info.Result1 != nil {
+				resp, _ = info.Result1.GoType()
+			}
+			if resp != tt.resp {
+				t.Errorf("result GoType = %s, want %s", resp, tt.resp)
+			}
+			if tt.imports != nil {
+				var got []string
+				for _, imp := range reqImports {
+					got = append(got, imp.PackageName+" "+imp.ImportPath)
+				}
+				if strings.Join(got, ",") != strings.Join(tt.imports, ",") {
+					t.Errorf("GoType imports = %v, want %v", got, tt.imports)
+				}
+			}
+			// the names of the params and results are kept for the implementation
+			if got := [4]string{info.CtxName, info.ReqName, info.ResName, info.ErrName}; got != tt.names {
+				t.Errorf("names = %v, want %v", got, tt.names)
+			}
+		})
+	}
+}
+
+func TestAssemblerCoreGen(t *testing.T) {
+	req := newNamed("github.com/go-miya/gorsx/example", "CreateReq", types.NewStruct(nil, nil))
+	resp := newNamed("github.com/go-miya/gorsx/example", "CreateResp", types.NewStruct(nil, nil))
+	param := func(typ types.Type) *Param {
+		p, _ := NewParam(typ)
+		return p
+	}
+	result := func(typ types.Type) *Result {
+		r, _ := NewResult(typ)
+		return r
+	}
+	query := &Result{ObjectArgs: &ObjectArgs{Name: "CreateQuery", GoImportPath: "github.com/go-miya/gorsx/example/app/query"}}
+	queryResp := &Param{ObjectArgs: &ObjectArgs{Name: "CreateResult", GoImportPath: "github.com/go-miya/gorsx/example/app/query"}}
+	cmd := &Result{ObjectArgs: &ObjectArgs{Name: "CreateCmd", GoImportPath: "github.com/go-miya/gorsx/example/app/command"}}
+	tests := []struct {
+		name string
+		core *AssemblerCore
+		want []string
+	}{
+		{
+			name: "query of structs",
+			core: NewAssemblerCore(true, "Create", param(types.NewPointer(req)), query, queryResp, result(types.NewPointer(resp))),
+			want: []string{
+				"func CreateTo(in *example.CreateReq) *query.CreateQuery {",
+				"func CreateFrom(in *query.CreateResult) *example.CreateResp {",
+			},
+		},
+		{
+			name: "query of bytes and string",
+			core: NewAssemblerCore(true, "Create", param(types.NewSlice(types.Typ[types.Byte])), query, queryResp, result(types.Typ[types.String])),
+			want: []string{
+				"func CreateTo(in []byte) *query.CreateQuery {",
+				"func CreateFrom(in *query.CreateResult) string {",
+			},
+		},
+		{
+			name: "query of a reader",
+			core: NewAssemblerCore(true, "Create", param(newNamed("io", "Reader", types.NewInterfaceType(nil, nil))), query, queryResp, result(newNamed("io", "Reader", types.NewInterfaceType(nil, nil)))),
+			want: []string{
+				"func CreateTo(in io.Reader) *query.CreateQuery {",
+				"func CreateFrom(in *query.CreateResult) io.Reader {",
+			},
+		},
+		{
+			name: "command only returning an error",
+			core: NewAssemblerCore(false, "Create", param(types.NewPointer(req)), cmd, nil, nil),
+			want: []string{
+				"func CreateTo(in *example.CreateReq) *command.CreateCmd {",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, line := range strings.Split(tt.core.Gen(), "\n") {
+				if strings.HasPrefix(line, "func ") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Gen funcs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/samber/lo"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"golang.org/x/tools/go/packages"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GoEditor edits an existing Go file by inserting and deleting byte ranges, so every byte it
+// does not touch, including comments, declaration order and blank lines, is preserved.
+type GoEditor struct {
+	Fset  *token.FileSet
+	File  *ast.File
+	src   []byte
+	edits []edit
+	// packages referenced by deleted nodes, whose imports are removed when left unused
+	deletedPkgs map[string]bool
+	// PackageNames are the names of the packages by their import paths, such as the ones PackageNames
+	// returns, which the unnamed imports are resolved with. The names of the other packages are
+	// assumed from their import paths.
+	PackageNames map[string]string
+}
+
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+func NewGoEditor(filename string) (*GoEditor, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newGoEditor(filename, src)
+}
+
+func newGoEditor(filename string, src []byte) (*GoEditor, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return &GoEditor{Fset: fset, File: f, src: src}, nil
+}
+
+// Offset returns the byte offset of pos in the file.
+func (e *GoEditor) Offset(pos token.Pos) int {
+	return e.Fset.Position(pos).Offset
+}
+
+// Insert inserts text before pos.
+func (e *GoEditor) Insert(pos token.Pos, text string) {
+	offset := e.Offset(pos)
+	e.edits = append(e.edits, edit{start: offset, end: offset, text: text})
+}
+
+// InsertLines inserts lines of text before pos, starting a new line first when pos is not at the
+// beginning of a line.
+func (e *GoEditor) InsertLines(pos token.Pos, text string) {
+	offset := e.Offset(pos)
+	lineStart := bytes.LastIndexByte(e.src[:offset], '\n') + 1
+	if len(bytes.TrimSpace(e.src[lineStart:offset])) > 0 {
+		text = "\n" + text
+	}
+	e.Insert(pos, text)
+}
+
+// Append appends text to the end of the file.
+func (e *GoEditor) Append(text string) {
+	e.edits = append(e.edits, edit{start: len(e.src), end: len(e.src), text: text})
+}
+
+// DeleteLines deletes the lines spanned by pos and end, together with one blank line preceding them.
+func (e *GoEditor) DeleteLines(pos token.Pos, end token.Pos) {
+	start := bytes.LastIndexByte(e.src[:e.Offset(pos)], '\n') + 1
+	stop := e.Offset(end)
+	if i := bytes.IndexByte(e.src[stop:], '\n'); i >= 0 {
+		stop += i + 1
+	} else {
+		stop = len(e.src)
+	}
+	if start > 0 {
+		prev := bytes.LastIndexByte(e.src[:start-1], '\n') + 1
+		if len(bytes.TrimSpace(e.src[prev:start])) == 0 {
+			start = prev
+		}
+	}
+	e.edits = append(e.edits, edit{start: start, end: stop, text: ""})
+}
+
+// DeleteNode deletes the lines of node and its doc comment. Imports only used by node are removed too.
+func (e *GoEditor) DeleteNode(node ast.Node, doc *ast.CommentGroup) {
+	pos := node.Pos()
+	if doc != nil {
+		pos = doc.Pos()
+	}
+	e.DeleteLines(pos, node.End())
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				if e.deletedPkgs == nil {
+					e.deletedPkgs = make(map[string]bool)
+				}
+				e.deletedPkgs[ident.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// Changed reports whether any edit has been made.
+func (e *GoEditor) Changed() bool {
+	return len(e.edits) > 0
+}
+
+// Bytes returns the source with all edits applied. The ranges of overlapping edits are merged, the
+// source they span is deleted and their texts are inserted in the order of their starts.
+func (e *GoEditor) Bytes() []byte {
+	edits := make([]edit, len(e.edits))
+	copy(edits, e.edits)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var buf bytes.Buffer
+	last := 0
+	for _, ed := range edits {
+		buf.Write(e.src[last:max(ed.start, last)])
+		buf.WriteString(ed.text)
+		last = max(ed.end, last)
+	}
+	buf.Write(e.src[last:])
+	if len(e.deletedPkgs) == 0 {
+		return buf.Bytes()
+	}
+	return e.removeUnusedImports(buf.Bytes())
+}
+
+// removeUnusedImports removes the imports of the packages referenced by the deleted nodes that src, the
+// edited source, no longer uses.
+func (e *GoEditor) removeUnusedImports(src []byte) []byte {
+	names := e.deletedPkgs
+	editor, err := newGoEditor(e.Fset.File(e.File.Pos()).Name(), src)
+	if err != nil {
+		return src
+	}
+	used := make(map[string]bool)
+	for _, decl := range editor.File.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			continue
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if ident, ok := sel.X.(*ast.Ident); ok {
+					used[ident.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	if !lo.SomeBy(lo.Keys(names), func(name string) bool { return !used[name] }) {
+		return src
+	}
+	pkgNames := importNames(e.PackageNames, editor.File.Imports)
+	for _, decl := range editor.File.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		var unused []ast.Spec
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			importPath, err := strconv.Unquote(importSpec.Path.Value)
+			if err != nil {
+				continue
+			}
+			name := pkgNames[importPath]
+			if importSpec.Name != nil {
+				name = importSpec.Name.Name
+			}
+			if names[name] && !used[name] {
+				unused = append(unused, spec)
+			}
+		}
+		if len(unused) > 0 && len(unused) == len(genDecl.Specs) {
+			editor.DeleteLines(genDecl.Pos(), genDecl.End())
+			continue
+		}
+		for _, spec := range unused {
+			editor.DeleteLines(spec.Pos(), spec.End())
+		}
+	}
+	return editor.Bytes()
+}
+
+// importNames returns the package names of the unnamed imports, the names of known or the names assumed
+// from their import paths.
+func importNames(known map[string]string, imports []*ast.ImportSpec) map[string]string {
+	names := make(map[string]string)
+	for _, importSpec := range imports {
+		importPath, err := strconv.Unquote(importSpec.Path.Value)
+		if err != nil || importSpec.Name != nil {
+			continue
+		}
+		if name, ok := known[importPath]; ok && name != "" {
+			names[importPath] = name
+			continue
+		}
+		names[importPath] = assumedPackageName(importPath)
+	}
+	return names
+}
+
+// PackageNames returns the names of pkg and of the packages it depends on, by their import paths.
+func PackageNames(pkg *packages.Package) map[string]string {
+	names := make(map[string]string)
+	packages.Visit([]*packages.Package{pkg}, nil, func(p *packages.Package) {
+		names[p.PkgPath] = p.Name
+	})
+	return names
+}
+
+// assumedPackageName returns the package name assumed from importPath, skipping the major version
+// suffix of modules, such as msgpack of github.com/vmihailenco/msgpack/v5 and yaml of gopkg.in/yaml.v3.
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if major, ok := strings.CutPrefix(base, "v"); ok && path.Dir(importPath) != "." {
+		if _, err := strconv.Atoi(major); err == nil {
+			base = path.Base(path.Dir(importPath))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }); i > 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// HasFunc reports whether the file declares a function, or a method when recv is not empty,
+// with the given name.
+func (e *GoEditor) HasFunc(recv string, name string) bool {
+	return e.FindFunc(recv, name) != nil
+}
+
+// FindFunc returns the function, or the method of recv when recv is not empty, with the given name.
+func (e *GoEditor) FindFunc(recv string, name string) *ast.FuncDecl {
+	for _, decl := range e.File.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Name.Name != name {
+			continue
+		}
+		if RecvTypeName(funcDecl) == recv {
+			return funcDecl
+		}
+	}
+	return nil
+}
+
+// RecvTypeName returns the receiver type name of a method, or an empty string for a function.
+func RecvTypeName(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return ""
+	}
+	expr := funcDecl.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// FindStruct returns the struct type declared with the given name.
+func (e *GoEditor) FindStruct(name string) *ast.StructType {
+	for _, decl := range e.File.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.Name.Name != name {
+				continue
+			}
+			if st, ok := typeSpec.Type.(*ast.StructType); ok {
+				return st
+			}
+		}
+	}
+	return nil
+}
+
+// Text returns the source between pos and end.
+func (e *GoEditor) Text(pos token.Pos, end token.Pos) string {
+	return string(e.src[e.Offset(pos):e.Offset(end)])
+}
+
+// HasImport reports whether the file imports importPath.
+func (e *GoEditor) HasImport(importPath string) bool {
+	for _, spec := range e.File.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == importPath {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportName returns the name the file refers to the package importPath by, or an empty string when
+// it does not import it.
+func (e *GoEditor) ImportName(importPath string) string {
+	for _, spec := range e.File.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return importNames(e.PackageNames, []*ast.ImportSpec{spec})[importPath]
+	}
+	return ""
+}
+
+// AddImports adds the imports that the file does not import yet, merging them into the first
+// grouped import declaration when there is one.
+func (e *GoEditor) AddImports(imports []*GoImport) {
+	var specs []string
+	for _, imp := range imports {
+		if imp.ImportPath == "" || e.HasImport(imp.ImportPath) {
+			continue
+		}
+		spec := strconv.Quote(imp.ImportPath)
+		if imp.PackageName != "" {
+			spec = imp.PackageName + " " + spec
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return
+	}
+	sort.Strings(specs)
+	for _, decl := range e.File.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || !genDecl.Lparen.IsValid() {
+			continue
+		}
+		e.InsertLines(genDecl.Rparen, "\t"+strings.Join(specs, "\n\t")+"\n")
+		return
+	}
+	text := "\n\nimport (\n\t" + strings.Join(specs, "\n\t") + "\n)"
+	pos := e.File.Name.End()
+	for _, decl := range e.File.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			pos = genDecl.End()
+		}
+	}
+	e.Insert(pos, text)
+}
+
+// FormatDecls formats generated declarations that are not preceded by a package clause.
+func FormatDecls(src []byte) ([]byte, error) {
+	const header = "package _\n"
+	formatted, err := format.Source(append([]byte(header), src...))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimPrefix(formatted, []byte(header)), nil
+}
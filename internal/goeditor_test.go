@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"testing"
+)
+
+const versionedImportsSrc = `package fixture
+
+import (
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+func Encode(v any) ([]byte, []byte) {
+	y, _ := yaml.Marshal(v)
+	m, _ := msgpack.Marshal(v)
+	return y, m
+}
+
+func Print(v any) {
+	fmt.Println(v)
+}
+`
+
+const versionedImportsWant = `package fixture
+
+import (
+	"fmt"
+)
+
+func Print(v any) {
+	fmt.Println(v)
+}
+`
+
+func TestDeleteNodeRemovesVersionedImports(t *testing.T) {
+	// the fixture is resolved in this directory, whose module requires both versioned packages
+	editor, err := newGoEditor("fixture.go", []byte(versionedImportsSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := editor.FindFunc("", "Encode")
+	editor.DeleteNode(fn, fn.Doc)
+	if got := string(editor.Bytes()); got != versionedImportsWant {
+		t.Errorf("Bytes() =\n%s\nwant\n%s", got, versionedImportsWant)
+	}
+}
+
+func TestDeleteNodeRemovesImportsOfKnownNames(t *testing.T) {
+	const src = `package fixture
+
+import (
+	"example.com/go-kit/v2/transport"
+	"fmt"
+)
+
+func Serve() {
+	kit.Serve()
+}
+
+func Print(v any) {
+	fmt.Println(v)
+}
+`
+	editor, err := newGoEditor("fixture.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the name of the package differs from the one assumed from its import path
+	editor.PackageNames = map[string]string{"example.com/go-kit/v2/transport": "kit"}
+	fn := editor.FindFunc("", "Serve")
+	editor.DeleteNode(fn, fn.Doc)
+	want := "package fixture\n\nimport (\n\t\"fmt\"\n)\n\nfunc Print(v any) {\n\tfmt.Println(v)\n}\n"
+	if got := string(editor.Bytes()); got != want {
+		t.Errorf("Bytes() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAssumedPackageName(t *testing.T) {
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"fmt", "fmt"},
+		{"net/http", "http"},
+		{"gopkg.in/yaml.v3", "yaml"},
+		{"github.com/vmihailenco/msgpack/v5", "msgpack"},
+		{"github.com/go-leo/design-pattern/cqrs", "cqrs"},
+		{"github.com/go-sql-driver/mysql", "mysql"},
+		{"github.com/mattn/go-sqlite3", "sqlite3"},
+		{"github.com/samber/lo", "lo"},
+	}
+	for _, tt := range tests {
+		if got := assumedPackageName(tt.importPath); got != tt.want {
+			t.Errorf("assumedPackageName(%s) = %s, want %s", tt.importPath, got, tt.want)
+		}
+	}
+}
+
+func TestBytesMergesOverlappingEdits(t *testing.T) {
+	const src = "package p\n\nfunc A() {}\n\nfunc B() {}\n\nfunc C() {}\n"
+	tests := []struct {
+		name string
+		edit func(e *GoEditor)
+		want string
+	}{
+		{
+			name: "disjoint",
+			edit: func(e *GoEditor) {
+				e.Insert(e.FindFunc("", "A").Pos(), "// A\n")
+				e.Append("\nfunc D() {}\n")
+			},
+			want: "package p\n\n// A\nfunc A() {}\n\nfunc B() {}\n\nfunc C() {}\n\nfunc D() {}\n",
+		},
+		{
+			name: "deletion within a deletion",
+			edit: func(e *GoEditor) {
+				b := e.FindFunc("", "B")
+				e.DeleteLines(e.FindFunc("", "A").Pos(), b.End())
+				e.DeleteLines(b.Pos(), b.End())
+			},
+			want: "package p\n\nfunc C() {}\n",
+		},
+		{
+			name: "deletions overlapping by a blank line",
+			edit: func(e *GoEditor) {
+				// the deletion of C spans the blank line preceding it, which ends the deletion of B
+				b, c := e.FindFunc("", "B"), e.FindFunc("", "C")
+				e.DeleteLines(b.Pos(), b.End()+1)
+				e.DeleteLines(c.Pos(), c.End())
+			},
+			want: "package p\n\nfunc A() {}\n",
+		},
+		{
+			name: "insertion within a deletion",
+			edit: func(e *GoEditor) {
+				b := e.FindFunc("", "B")
+				e.DeleteLines(b.Pos(), b.End())
+				e.Insert(b.Name.Pos(), "X")
+			},
+			want: "package p\n\nfunc A() {}\nX\nfunc C() {}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			editor, err := newGoEditor("p.go", []byte(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(editor)
+			if got := string(editor.Bytes()); got != tt.want {
+				t.Errorf("Bytes() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// editSource returns src edited by edit.
+func editSource(t *testing.T, src string, edit func(e *GoEditor)) string {
+	t.Helper()
+	editor, err := newGoEditor("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(editor)
+	return string(editor.Bytes())
+}
+
+func TestAddImports(t *testing.T) {
+	imports := []*GoImport{
+		{ImportPath: "io"},
+		{PackageName: "query", ImportPath: "example.com/app/query"},
+		{ImportPath: "fmt"},
+	}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "grouped imports",
+			src:  "package p\n\nimport (\n\t// fmt prints\n\t\"fmt\"\n)\n\nfunc A() {}\n",
+			want: "package p\n\nimport (\n\t// fmt prints\n\t\"fmt\"\n\t\"io\"\n\tquery \"example.com/app/query\"\n)\n\nfunc A() {}\n",
+		},
+		{
+			name: "single import",
+			src:  "package p\n\nimport \"fmt\"\n\nfunc A() {}\n",
+			want: "package p\n\nimport \"fmt\"\n\nimport (\n\t\"io\"\n\tquery \"example.com/app/query\"\n)\n\nfunc A() {}\n",
+		},
+		{
+			name: "no imports",
+			src:  "package p\n\n// A is kept as it is.\nfunc A() {}\n",
+			want: "package p\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\tquery \"example.com/app/query\"\n)\n\n// A is kept as it is.\nfunc A() {}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := editSource(t, tt.src, func(e *GoEditor) { e.AddImports(imports) })
+			if got != tt.want {
+				t.Errorf("Bytes() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertKeepsUntouchedBytes(t *testing.T) {
+	const src = "package p\n\n// A  is   odd.\nfunc A() {}\n\n\n\nfunc B() {}\n"
+	got := editSource(t, src, func(e *GoEditor) {
+		b := e.FindFunc("", "B")
+		e.Append("\nfunc D() {}\n")
+		e.Insert(b.Pos(), "func C() {}\n\n")
+		e.Insert(b.Pos(), "// B\n")
+	})
+	const want = "package p\n\n// A  is   odd.\nfunc A() {}\n\n\n\nfunc C() {}\n\n// B\nfunc B() {}\n\nfunc D() {}\n"
+	if got != want {
+		t.Errorf("Bytes() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"github.com/samber/lo"
+	"go/token"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	GET      annotation = "@GET"
+	HEAD     annotation = "@HEAD"
+	POST     annotation = "@POST"
+	PUT      annotation = "@PUT"
+	PATCH    annotation = "@PATCH"
+	DELETE   annotation = "@DELETE"
+	CONNECT  annotation = "@CONNECT"
+	OPTIONS  annotation = "@OPTIONS"
+	TRACE    annotation = "@TRACE"
+	HTTPPath annotation = "@Path"
+)
+
+// Middleware names the middlewares wrapping the handlers of a service or method, such as @Middleware(auth,log).
+const Middleware annotation = "@Middleware"
+
+// Validate opts the handlers of a service or method in to validating the bound request.
+const Validate annotation = "@Validate"
+
+const (
+	UriBinding           annotation = "@UriBinding"
+	QueryBinding         annotation = "@QueryBinding"
+	HeaderBinding        annotation = "@HeaderBinding"
+	JSONBinding          annotation = "@JSONBinding"
+	XMLBinding           annotation = "@XMLBinding"
+	FormBinding          annotation = "@FormBinding"
+	FormPostBinding      annotation = "@FormPostBinding"
+	FormMultipartBinding annotation = "@FormMultipartBinding"
+	ProtoBufBinding      annotation = "@ProtoBufBinding"
+	MsgPackBinding       annotation = "@MsgPackBinding"
+	YAMLBinding          annotation = "@YAMLBinding"
+	TOMLBinding          annotation = "@TOMLBinding"
+)
+
+const (
+	BytesRender        annotation = "@BytesRender"
+	StringRender       annotation = "@StringRender"
+	TextRender         annotation = "@TextRender"
+	HTMLRender         annotation = "@HTMLRender"
+	ReaderRender       annotation = "@ReaderRender"
+	JSONRender         annotation = "@JSONRender"
+	IndentedJSONRender annotation = "@IndentedJSONRender"
+	SecureJSONRender   annotation = "@SecureJSONRender"
+	JsonpJSONRender    annotation = "@JsonpJSONRender"
+	PureJSONRender     annotation = "@PureJSONRender"
+	AsciiJSONRender    annotation = "@AsciiJSONRender"
+	XMLRender          annotation = "@XMLRender"
+	YAMLRender         annotation = "@YAMLRender"
+	TOMLRender         annotation = "@TOMLRender"
+	MsgPackRender      annotation = "@MsgPackRender"
+	ProtoBufRender     annotation = "@ProtoBufRender"
+)
+
+var methods = map[annotation]string{
+	GET:     http.MethodGet,
+	HEAD:    http.MethodHead,
+	POST:    http.MethodPost,
+	PUT:     http.MethodPut,
+	PATCH:   http.MethodPatch,
+	DELETE:  http.MethodDelete,
+	CONNECT: http.MethodConnect,
+	OPTIONS: http.MethodOptions,
+	TRACE:   http.MethodTrace,
+}
+
+var bindings = []annotation{
+	UriBinding, QueryBinding, HeaderBinding, JSONBinding, XMLBinding, FormBinding, FormPostBinding,
+	FormMultipartBinding, ProtoBufBinding, MsgPackBinding, YAMLBinding, TOMLBinding,
+}
+
+var renders = []annotation{
+	BytesRender, StringRender, TextRender, HTMLRender, ReaderRender, JSONRender, IndentedJSONRender,
+	SecureJSONRender, JsonpJSONRender, PureJSONRender, AsciiJSONRender, XMLRender, YAMLRender, TOMLRender,
+	MsgPackRender, ProtoBufRender,
+}
+
+// Route is the HTTP route of a method, declared by its @GORS annotation.
+type Route struct {
+	Method string
+	Path   string
+	// Bindings lists the request binding annotations in declared order.
+	Bindings []annotation
+	Render   annotation
+	// RenderContentType is the content type given to @BytesRender, @StringRender or @ReaderRender.
+	RenderContentType string
+	// Middlewares are the names of the middlewares wrapping the handler, in declared order.
+	Middlewares []string
+	// Validate reports whether the handler validates the bound request.
+	Validate bool
+	// Pos is the position of the method declaring the route.
+	Pos token.Position
+}
+
+// RouteGroup is the routing shared by the methods of a service, declared by the doc comment of the
+// service interface.
+type RouteGroup struct {
+	// Path is the prefix of the paths of every route, the @Path annotations of the service joined in
+	// declared order.
+	Path string
+	// Middlewares are the names of the middlewares wrapping every handler, in declared order.
+	Middlewares []string
+	// Validate reports whether every handler validates the bound request.
+	Validate bool
+}
+
+func NewRouteGroup(comments []string) *RouteGroup {
+	group := &RouteGroup{Middlewares: parseMiddlewares(comments), Validate: hasAnnotation(comments, GORS, Validate)}
+	var prefixes []string
+	for _, comment := range comments {
+		seg := strings.Fields(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "//")))
+		if len(seg) == 0 || !GORS.EqualsIgnoreCase(seg[0]) {
+			continue
+		}
+		for _, s := range seg[1:] {
+			if !HTTPPath.PrefixOf(s) {
+				continue
+			}
+			v, ok := ExtractValue(s, string(HTTPPath))
+			if !ok {
+				log.Fatalf("error: service %s path invalid", s)
+			}
+			prefixes = append(prefixes, v)
+		}
+	}
+	if len(prefixes) > 0 {
+		group.Path = JoinPath(prefixes...)
+	}
+	return group
+}
+
+// FullPath returns the path of a route of the group, prefixed by the path of the group.
+func (g *RouteGroup) FullPath(path string) string {
+	return JoinPath(g.Path, path)
+}
+
+// JoinPath joins the segments of paths into an absolute path. Empty segments, such as the ones of
+// duplicate slashes, are dropped, so that the path has no trailing slash unless it is the root.
+func JoinPath(paths ...string) string {
+	var segments []string
+	for _, path := range paths {
+		for _, segment := range strings.Split(path, "/") {
+			if segment = strings.TrimSpace(segment); segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// HasBinding reports whether the route binds the request with the given annotation.
+func (r *Route) HasBinding(binding annotation) bool {
+	for _, b := range r.Bindings {
+		if b == binding {
+			return true
+		}
+	}
+	return false
+}
+
+// BindsFormBody reports whether the form tags of the request are bound from the body rather than the query.
+func (r *Route) BindsFormBody() bool {
+	return r.HasBinding(FormBinding) || r.HasBinding(FormPostBinding) || r.HasBinding(FormMultipartBinding)
+}
+
+// PathParams returns the names of the :name and *name parameters of the path.
+func (r *Route) PathParams() []string {
+	var names []string
+	for _, segment := range strings.Split(r.Path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
+
+// NewRoute parses the @GORS annotation of a method. It returns nil when the method has none.
+func NewRoute(methodName string, comments []string) *Route {
+	var route *Route
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "//"))
+		seg := strings.Fields(text)
+		// 注释的开始必须以 @GORS 开头
+		if len(seg) == 0 || !GORS.EqualsIgnoreCase(seg[0]) {
+			continue
+		}
+		if route == nil {
+			route = &Route{}
+		}
+		for _, s := range seg[1:] {
+			if method, ok := lookupMethod(s); ok {
+				route.Method = method
+				continue
+			}
+			if HTTPPath.PrefixOf(s) {
+				v, ok := ExtractValue(s, string(HTTPPath))
+				if !ok {
+					log.Fatalf("error: func %s %s path invalid", methodName, s)
+				}
+				route.Path = v
+				continue
+			}
+			if binding, ok := lookupAnnotation(bindings, s); ok {
+				route.Bindings = append(route.Bindings, binding)
+				continue
+			}
+			if render, ok := lookupAnnotation(renders, s); ok {
+				route.Render = render
+				if v, ok := ExtractValue(s, string(render)); ok {
+					route.RenderContentType = v
+				}
+				continue
+			}
+		}
+	}
+	if route == nil {
+		return nil
+	}
+	if route.Method == "" {
+		log.Fatalf("error: func %s method not found, one of @GET, @POST, @PUT, @PATCH, @DELETE, ... is required", methodName)
+	}
+	route.Middlewares = parseMiddlewares(comments)
+	route.Validate = hasAnnotation(comments, GORS, Validate)
+	return route
+}
+
+// hasAnnotation reports whether a line of comments starting with line, @GORS or @CQRS, carries the
+// annotation a.
+func hasAnnotation(comments []string, line annotation, a annotation) bool {
+	for _, comment := range comments {
+		seg := strings.Fields(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "//")))
+		if len(seg) == 0 || !line.EqualsIgnoreCase(seg[0]) {
+			continue
+		}
+		for _, s := range seg[1:] {
+			if a.EqualsIgnoreCase(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var middlewareRegexp = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(string(Middleware)) + `\(([^)]*)\)`)
+
+// parseMiddlewares returns the names of the @Middleware annotations of the @GORS lines of comments, in
+// declared order.
+func parseMiddlewares(comments []string) []string {
+	var names []string
+	for _, args := range annotationArgs(comments, middlewareRegexp, GORS) {
+		for _, name := range strings.Split(args, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// annotationArgs returns the arguments of the annotations matched by re on the lines of comments
+// starting with one of lines, such as @GORS or @CQRS.
+func annotationArgs(comments []string, re *regexp.Regexp, lines ...annotation) []string {
+	var args []string
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "//"))
+		seg := strings.Fields(text)
+		if len(seg) == 0 || !lo.ContainsBy(lines, func(line annotation) bool { return line.EqualsIgnoreCase(seg[0]) }) {
+			continue
+		}
+		for _, match := range re.FindAllStringSubmatch(text, -1) {
+			args = append(args, match[1])
+		}
+	}
+	return args
+}
+
+func lookupMethod(s string) (string, bool) {
+	for a, method := range methods {
+		if a.EqualsIgnoreCase(s) {
+			return method, true
+		}
+	}
+	return "", false
+}
+
+func lookupAnnotation(annotations []annotation, s string) (annotation, bool) {
+	name := s
+	if i := strings.Index(s, "("); i >= 0 {
+		name = s[:i]
+	}
+	for _, a := range annotations {
+		if a.EqualsIgnoreCase(name) {
+			return a, true
+		}
+	}
+	return "", false
+}
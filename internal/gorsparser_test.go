@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMiddlewares(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []string
+		want     []string
+	}{
+		{
+			name:     "none",
+			comments: []string{"// @GORS @GET @Path(/x)"},
+		},
+		{
+			name:     "declared order",
+			comments: []string{"// @GORS @GET @Path(/x) @Middleware(auth, log) @Middleware(trace)"},
+			want:     []string{"auth", "log", "trace"},
+		},
+		{
+			name: "several @GORS lines",
+			comments: []string{
+				"// @GORS @GET @Path(/x) @middleware(auth)",
+				"// @GORS @Middleware(log,)",
+			},
+			want: []string{"auth", "log"},
+		},
+		{
+			name: "annotations outside @GORS lines are ignored",
+			comments: []string{
+				"// Get wraps the handler, see @Middleware(auth).",
+				"// @CQRS @Query @Middleware(log)",
+				"// @GORS @GET @Path(/x) @Middleware(trace)",
+			},
+			want: []string{"trace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMiddlewares(tt.comments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMiddlewares() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteMiddlewares(t *testing.T) {
+	route := NewRoute("Get", []string{
+		"// Get gets the user, authenticated by @Middleware(auth).",
+		"// @GORS @GET @Path(/users/:id) @Middleware(log)",
+	})
+	if !reflect.DeepEqual(route.Middlewares, []string{"log"}) {
+		t.Errorf("Middlewares = %v, want [log]", route.Middlewares)
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{nil, "/"},
+		{[]string{""}, "/"},
+		{[]string{"/"}, "/"},
+		{[]string{"/api", "/users"}, "/api/users"},
+		{[]string{"api/", "users/"}, "/api/users"},
+		{[]string{"/api/", "/v1//", "/users/:id"}, "/api/v1/users/:id"},
+		{[]string{"", "/users"}, "/users"},
+		{[]string{"/api", ""}, "/api"},
+		{[]string{"/files", "/*path"}, "/files/*path"},
+	}
+	for _, tt := range tests {
+		if got := JoinPath(tt.paths...); got != tt.want {
+			t.Errorf("JoinPath(%q) = %s, want %s", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestRouteGroupFullPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []string
+		path     string
+		want     string
+	}{
+		{
+			name:     "no prefix",
+			comments: []string{"// Users manages the users."},
+			path:     "/users/:id",
+			want:     "/users/:id",
+		},
+		{
+			name:     "prefix",
+			comments: []string{"// @GORS @Path(/api/)"},
+			path:     "/users/:id",
+			want:     "/api/users/:id",
+		},
+		{
+			name: "prefixes joined in declared order",
+			comments: []string{
+				"// @GORS @Path(/api) @Path(v1)",
+				"// @GORS @Path(/admin/)",
+			},
+			path: "users",
+			want: "/api/v1/admin/users",
+		},
+		{
+			name: "paths outside @GORS lines are ignored",
+			comments: []string{
+				"// Users serves @Path(/legacy) no more.",
+				"// @GORS @Path(/api)",
+			},
+			path: "/users",
+			want: "/api/users",
+		},
+		{
+			name:     "root route",
+			comments: []string{"// @GORS @Path(/api)"},
+			path:     "/",
+			want:     "/api",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRouteGroup(tt.comments).FullPath(tt.path); got != tt.want {
+				t.Errorf("FullPath(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRouteGroup(t *testing.T) {
+	group := NewRouteGroup([]string{"// @GORS @Path(/api) @Middleware(auth) @Validate"})
+	want := &RouteGroup{Path: "/api", Middlewares: []string{"auth"}, Validate: true}
+	if !reflect.DeepEqual(group, want) {
+		t.Errorf("NewRouteGroup() = %+v, want %+v", group, want)
+	}
+}
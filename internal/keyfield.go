@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/samber/lo"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+)
+
+// KeyFieldNames returns the fields of the @Cache query or the @Invalidate command the cache keys are
+// derived from. An @Invalidate without a key derives it from the key fields of the query among queries.
+func (v CQRSFile) KeyFieldNames(queries []*CQRSFile) []string {
+	if v.Cache != nil {
+		return v.Cache.Key
+	}
+	var names []string
+	for _, invalidation := range v.Invalidates {
+		fields := invalidation.Key
+		if len(fields) == 0 {
+			query, ok := lo.Find(queries, func(q *CQRSFile) bool { return q.IsQuery() && q.Endpoint == invalidation.Endpoint })
+			if ok && query.Cache != nil {
+				fields = query.Cache.Key
+			}
+		}
+		for _, field := range fields {
+			if !lo.Contains(names, field) {
+				names = append(names, field)
+			}
+		}
+	}
+	return names
+}
+
+// ResolveKeyFields declares the key fields of the @Cache query or the @Invalidate command of f in its
+// generated struct, typed as the fields of the same name of the request of f. It fails at pos, the
+// position of the method, when the request has no such field. Once the handler file exists, it checks
+// its struct declares the key fields instead.
+func (f *FuncInfo) ResolveKeyFields(queries []*CQRSFile, pos token.Position) error {
+	names := f.CQRS.KeyFieldNames(queries)
+	if len(names) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(f.CQRS.AbsFilename); err == nil {
+		return f.CQRS.checkKeyFields(names)
+	}
+	var reqType types.Type
+	if f.Param2 != nil && f.Param2.ObjectArgs != nil {
+		reqType = f.Param2.ObjectArgs.Type
+	}
+	imports := make(map[string]bool)
+	qualifier := func(pkg *types.Package) string {
+		imports[pkg.Path()] = true
+		return pkg.Name()
+	}
+	f.CQRS.KeyFields = nil
+	for _, name := range names {
+		var field *types.Var
+		if reqType != nil {
+			obj, _, _ := types.LookupFieldOrMethod(reqType, true, nil, name)
+			field, _ = obj.(*types.Var)
+		}
+		if field == nil || !field.IsField() {
+			return fmt.Errorf("%s: error: func %s key field %s is not a field of its request, which types the field of %s",
+				pos, f.FuncName, name, f.CQRS.GetReqName())
+		}
+		f.CQRS.KeyFields = append(f.CQRS.KeyFields, &KeyField{Name: name, Type: types.TypeString(field.Type(), qualifier)})
+	}
+	f.CQRS.Imports = lo.Keys(imports)
+	sort.Strings(f.CQRS.Imports)
+	return nil
+}
+
+// checkKeyFields checks that the struct of the existing handler file declares the key fields the
+// generated decorators select, and fails at the position of the struct otherwise. The struct is not
+// checked when it embeds another type, which may promote the fields.
+func (v CQRSFile) checkKeyFields(names []string) error {
+	src, err := os.ReadFile(v.AbsFilename)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, v.AbsFilename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return err
+	}
+	spec := findTypeSpec(file, v.GetReqName())
+	if spec == nil {
+		return fmt.Errorf("%s: error: %s not found, which the key fields of %s require", fset.Position(file.Package), v.GetReqName(), v.Endpoint)
+	}
+	structType, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("%s: error: %s is not a struct, which the key fields of %s require", fset.Position(spec.Pos()), v.GetReqName(), v.Endpoint)
+	}
+	declared := make(map[string]bool)
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			return nil
+		}
+		for _, name := range field.Names {
+			declared[name.Name] = true
+		}
+	}
+	for _, name := range names {
+		if !declared[name] {
+			return fmt.Errorf("%s: error: %s has no field %s, which the key of %s is derived from", fset.Position(spec.Pos()), v.GetReqName(), name, v.Endpoint)
+		}
+	}
+	return nil
+}
+
+func findTypeSpec(file *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == name {
+				return typeSpec
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const keyFieldsSrc = `package orders
+
+import "time"
+
+type GetOrderReq struct {
+	ID  string
+	Day time.Time
+}
+`
+
+// newKeyFieldsFunc returns the FuncInfo of a method whose request is *GetOrderReq.
+func newKeyFieldsFunc(t *testing.T, file *CQRSFile) *FuncInfo {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "orders.go", keyFieldsSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/orders", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	param, ok := NewParam(types.NewPointer(pkg.Scope().Lookup("GetOrderReq").Type()))
+	if !ok {
+		t.Fatal("NewParam failed")
+	}
+	return &FuncInfo{FuncName: file.Endpoint, Param2: param, CQRS: file}
+}
+
+func TestResolveKeyFields(t *testing.T) {
+	dir := t.TempDir()
+	query := NewQueryFile("GetOrder", dir, "app/query", "")
+	query.Cache = &CacheAnnotation{Key: []string{"ID", "Day"}}
+	info := newKeyFieldsFunc(t, query)
+	if err := info.ResolveKeyFields(nil, token.Position{}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := query.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\t\"time\"\n", "type GetOrderQuery struct {\n\tID string\n\tDay time.Time\n}"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Content() =\n%s\nwant it to contain %q", content, want)
+		}
+	}
+
+	// the command invalidating the query without a key derives it from the key fields of the query
+	command := NewCommandFile("CancelOrder", dir, "app/command", "")
+	command.Invalidates = []*Invalidation{{Endpoint: "GetOrder"}}
+	if got := strings.Join(command.KeyFieldNames([]*CQRSFile{query}), "+"); got != "ID+Day" {
+		t.Errorf("KeyFieldNames() = %s, want ID+Day", got)
+	}
+
+	query.Cache.Key = []string{"ID", "Missing"}
+	if err := newKeyFieldsFunc(t, query).ResolveKeyFields(nil, token.Position{}); err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("ResolveKeyFields() = %v, want an error naming Missing", err)
+	}
+}
+
+func TestResolveKeyFieldsChecksExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	query := NewQueryFile("GetOrder", dir, "app/query", "")
+	query.Cache = &CacheAnnotation{Key: []string{"ID", "Day"}}
+	src := "package query\n\ntype GetOrderQuery struct {\n\tID string\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "get_order.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := newKeyFieldsFunc(t, query).ResolveKeyFields(nil, token.Position{})
+	if err == nil || !strings.Contains(err.Error(), "get_order.go:3:6") || !strings.Contains(err.Error(), "no field Day") {
+		t.Errorf("ResolveKeyFields() = %v, want the position of GetOrderQuery and the missing Day", err)
+	}
+
+	query.Cache.Key = []string{"ID"}
+	if err := newKeyFieldsFunc(t, query).ResolveKeyFields(nil, token.Position{}); err != nil {
+		t.Errorf("ResolveKeyFields() = %v, want the declared ID accepted", err)
+	}
+}
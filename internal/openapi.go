@@ -0,0 +1,530 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI builds an OpenAPI 3.1 document of a service from the @GORS annotations of its methods.
+type OpenAPI struct {
+	Title       string
+	Description string
+	Funcs       []*FuncInfo
+	schemas     *yamlMap
+	schemaKeys  map[string]string
+}
+
+func NewOpenAPI(title string, comments []string, funcs []*FuncInfo) *OpenAPI {
+	return &OpenAPI{
+		Title:       title,
+		Description: docText(comments),
+		Funcs:       funcs,
+		schemas:     newYAMLMap(),
+		schemaKeys:  make(map[string]string),
+	}
+}
+
+// YAML returns the document in YAML.
+func (o *OpenAPI) YAML() []byte {
+	info := newYAMLMap()
+	info.Set("title", o.Title)
+	if o.Description != "" {
+		info.Set("description", o.Description)
+	}
+	info.Set("version", "1.0.0")
+
+	paths := newYAMLMap()
+	for _, f := range o.Funcs {
+		if f.Route == nil || f.Signature == nil {
+			continue
+		}
+		path := OpenAPIPath(f.Route.Path)
+		item, ok := paths.Get(path)
+		if !ok {
+			item = newYAMLMap()
+			paths.Set(path, item)
+		}
+		item.(*yamlMap).Set(strings.ToLower(f.Route.Method), o.operation(f))
+	}
+
+	doc := newYAMLMap()
+	doc.Set("openapi", "3.1.0")
+	doc.Set("info", info)
+	doc.Set("paths", paths)
+	if len(o.schemas.keys) > 0 {
+		components := newYAMLMap()
+		components.Set("schemas", o.schemas)
+		doc.Set("components", components)
+	}
+	var buf bytes.Buffer
+	writeYAML(&buf, doc, 0)
+	return buf.Bytes()
+}
+
+var pathParamRegexp = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// OpenAPIPath converts the gin flavoured path parameters, :id and *filepath, to {id} and {filepath}.
+func OpenAPIPath(path string) string {
+	return pathParamRegexp.ReplaceAllString(path, "{$1}")
+}
+
+func (o *OpenAPI) operation(f *FuncInfo) *yamlMap {
+	op := newYAMLMap()
+	op.Set("operationId", f.FuncName)
+	if summary := docText(f.Comments); summary != "" {
+		op.Set("summary", summary)
+	}
+	reqType := f.Signature.Params().At(1).Type()
+	if params := o.parameters(f.Route, reqType); len(params) > 0 {
+		op.Set("parameters", params)
+	}
+	if body := o.requestBody(f.Route, reqType); body != nil {
+		op.Set("requestBody", body)
+	}
+
+	ok := newYAMLMap()
+	ok.Set("description", http.StatusText(http.StatusOK))
+	if f.Result1 != nil {
+		mediaType, tagKey := renderMediaType(f.Route, f.Result1)
+		media := newYAMLMap()
+		media.Set("schema", o.schema(f.Signature.Results().At(0).Type(), tagKey))
+		content := newYAMLMap()
+		content.Set(mediaType, media)
+		ok.Set("content", content)
+	}
+	responses := newYAMLMap()
+	responses.Set(strconv.Itoa(http.StatusOK), ok)
+	op.Set("responses", responses)
+	return op
+}
+
+// parameters returns the path, query and header parameters bound from the uri, form and header tags
+// of the request struct.
+func (o *OpenAPI) parameters(route *Route, reqType types.Type) []any {
+	var params []any
+	declared := make(map[string]bool)
+	add := func(in string, name string, t types.Type) {
+		param := newYAMLMap()
+		param.Set("name", name)
+		param.Set("in", in)
+		if in == "path" {
+			param.Set("required", true)
+			declared[name] = true
+		}
+		param.Set("schema", o.schema(t, ""))
+		params = append(params, param)
+	}
+	for _, field := range StructFields(reqType) {
+		if name, ok := field.Tag("uri"); ok && route.HasBinding(UriBinding) {
+			add("path", name, field.Type)
+		}
+		if name, ok := field.Tag("form"); ok && route.HasBinding(QueryBinding) && !route.BindsFormBody() {
+			add("query", name, field.Type)
+		}
+		if name, ok := field.Tag("header"); ok && route.HasBinding(HeaderBinding) {
+			add("header", name, field.Type)
+		}
+	}
+	for _, name := range route.PathParams() {
+		if !declared[name] {
+			add("path", name, types.Typ[types.String])
+		}
+	}
+	return params
+}
+
+var bindingMediaTypes = []struct {
+	binding   annotation
+	mediaType string
+	tagKey    string
+}{
+	{JSONBinding, "application/json", "json"},
+	{XMLBinding, "application/xml", "xml"},
+	{FormBinding, "application/x-www-form-urlencoded", "form"},
+	{FormPostBinding, "application/x-www-form-urlencoded", "form"},
+	{FormMultipartBinding, "multipart/form-data", "form"},
+	{ProtoBufBinding, "application/x-protobuf", "protobuf"},
+	{MsgPackBinding, "application/x-msgpack", "msgpack"},
+	{YAMLBinding, "application/x-yaml", "yaml"},
+	{TOMLBinding, "application/toml", "toml"},
+}
+
+func (o *OpenAPI) requestBody(route *Route, reqType types.Type) *yamlMap {
+	content := newYAMLMap()
+	switch {
+	case isBytesType(reqType), isNamed(reqType, "io", "Reader"):
+		media := newYAMLMap()
+		media.Set("schema", binarySchema())
+		content.Set("application/octet-stream", media)
+	case isStringType(reqType):
+		media := newYAMLMap()
+		media.Set("schema", o.schema(reqType, ""))
+		content.Set("text/plain", media)
+	default:
+		for _, b := range bindingMediaTypes {
+			if !route.HasBinding(b.binding) {
+				continue
+			}
+			media := newYAMLMap()
+			media.Set("schema", o.bodySchema(route, reqType, b.tagKey))
+			content.Set(b.mediaType, media)
+		}
+	}
+	if len(content.keys) == 0 {
+		return nil
+	}
+	body := newYAMLMap()
+	body.Set("required", true)
+	body.Set("content", content)
+	return body
+}
+
+// bodySchema returns the schema of the request fields that are not bound from the path, query or headers.
+func (o *OpenAPI) bodySchema(route *Route, reqType types.Type, tagKey string) *yamlMap {
+	properties := newYAMLMap()
+	for _, field := range StructFields(reqType) {
+		if _, ok := field.Tag("uri"); ok && route.HasBinding(UriBinding) {
+			continue
+		}
+		if _, ok := field.Tag("header"); ok && route.HasBinding(HeaderBinding) {
+			continue
+		}
+		if _, ok := field.Tag("form"); ok && route.HasBinding(QueryBinding) && tagKey != "form" {
+			continue
+		}
+		name, ok := field.Name(tagKey)
+		if !ok {
+			continue
+		}
+		properties.Set(name, o.schema(field.Type, tagKey))
+	}
+	schema := newYAMLMap()
+	schema.Set("type", "object")
+	schema.Set("properties", properties)
+	return schema
+}
+
+func renderMediaType(route *Route, result *Result) (string, string) {
+	if route.RenderContentType != "" {
+		return route.RenderContentType, ""
+	}
+	switch route.Render {
+	case XMLRender:
+		return "application/xml", "xml"
+	case YAMLRender:
+		return "application/x-yaml", "yaml"
+	case TOMLRender:
+		return "application/toml", "toml"
+	case MsgPackRender:
+		return "application/x-msgpack", "msgpack"
+	case ProtoBufRender:
+		return "application/x-protobuf", "protobuf"
+	case JsonpJSONRender:
+		return "application/javascript", "json"
+	case TextRender, StringRender:
+		return "text/plain", ""
+	case HTMLRender:
+		return "text/html", ""
+	case BytesRender, ReaderRender:
+		return "application/octet-stream", ""
+	}
+	switch {
+	case result.Bytes, result.Reader:
+		return "application/octet-stream", ""
+	case result.String:
+		return "text/plain", ""
+	}
+	return "application/json", "json"
+}
+
+// schema returns the JSON schema of t, registering named structs as components. Struct properties
+// are named after the tagKey struct tags.
+func (o *OpenAPI) schema(t types.Type, tagKey string) *yamlMap {
+	schema := newYAMLMap()
+	switch {
+	case isNamed(t, "time", "Time"):
+		schema.Set("type", "string")
+		schema.Set("format", "date-time")
+		return schema
+	case isNamed(t, "mime/multipart", "FileHeader"), isNamed(t, "io", "Reader"):
+		return binarySchema()
+	case isBytesType(t):
+		schema.Set("type", "string")
+		schema.Set("format", "byte")
+		return schema
+	}
+	switch x := t.(type) {
+	case *types.Basic:
+		setBasicSchema(schema, x)
+	case *types.Pointer:
+		return o.schema(x.Elem(), tagKey)
+	case *types.Slice:
+		schema.Set("type", "array")
+		schema.Set("items", o.schema(x.Elem(), tagKey))
+	case *types.Array:
+		schema.Set("type", "array")
+		schema.Set("items", o.schema(x.Elem(), tagKey))
+	case *types.Map:
+		schema.Set("type", "object")
+		schema.Set("additionalProperties", o.schema(x.Elem(), tagKey))
+	case *types.Named:
+		if _, ok := x.Underlying().(*types.Struct); !ok {
+			return o.schema(x.Underlying(), tagKey)
+		}
+		schema.Set("$ref", "#/components/schemas/"+o.component(x, tagKey))
+	case *types.Struct:
+		schema.Set("type", "object")
+		properties := newYAMLMap()
+		for _, field := range StructFields(x) {
+			if name, ok := field.Name(tagKey); ok {
+				properties.Set(name, o.schema(field.Type, tagKey))
+			}
+		}
+		schema.Set("properties", properties)
+	}
+	return schema
+}
+
+// component registers the schema of a named struct and returns its component name.
+func (o *OpenAPI) component(named *types.Named, tagKey string) string {
+	key := types.TypeString(named, nil) + "#" + tagKey
+	if name, ok := o.schemaKeys[key]; ok {
+		return name
+	}
+	// the types named alike in different packages are told apart by the tag key, then by a number
+	base := componentName(named)
+	name := base
+	for i := 1; ; i++ {
+		if _, ok := o.schemas.Get(name); !ok {
+			break
+		}
+		name = base + "_" + tagKey
+		if i > 1 {
+			name += "_" + strconv.Itoa(i)
+		}
+	}
+	o.schemaKeys[key] = name
+	o.schemas.Set(name, nil)
+	o.schemas.Set(name, o.schema(named.Underlying(), tagKey))
+	return name
+}
+
+var componentNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func componentName(named *types.Named) string {
+	name := types.TypeString(named, func(pkg *types.Package) string { return "" })
+	return strings.Trim(componentNameRegexp.ReplaceAllString(name, "_"), "_")
+}
+
+func setBasicSchema(schema *yamlMap, basic *types.Basic) {
+	info := basic.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		schema.Set("type", "boolean")
+	case info&types.IsInteger != 0:
+		schema.Set("type", "integer")
+		switch basic.Kind() {
+		case types.Int32, types.Uint32:
+			schema.Set("format", "int32")
+		case types.Int64, types.Uint64, types.Int, types.Uint:
+			schema.Set("format", "int64")
+		}
+	case info&types.IsFloat != 0:
+		schema.Set("type", "number")
+		if basic.Kind() == types.Float32 {
+			schema.Set("format", "float")
+		} else {
+			schema.Set("format", "double")
+		}
+	case info&types.IsString != 0:
+		schema.Set("type", "string")
+	}
+}
+
+func binarySchema() *yamlMap {
+	schema := newYAMLMap()
+	schema.Set("type", "string")
+	schema.Set("format", "binary")
+	return schema
+}
+
+func isBytesType(t types.Type) bool {
+	slice, ok := t.(*types.Slice)
+	return ok && types.Identical(slice.Elem(), types.Typ[types.Byte])
+}
+
+func isStringType(t types.Type) bool {
+	basic, ok := t.(*types.Basic)
+	return ok && basic.Kind() == types.String
+}
+
+// StructField is an exported field of a struct, with the fields of embedded structs promoted.
+type StructField struct {
+	GoName string
+	Type   types.Type
+	tag    reflect.StructTag
+}
+
+// Tag returns the name in the key struct tag of the field.
+func (f StructField) Tag(key string) (string, bool) {
+	v, ok := f.tag.Lookup(key)
+	if !ok {
+		return "", false
+	}
+	name := strings.Split(v, ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.GoName
+	}
+	return name, true
+}
+
+// Lookup returns the raw value of the key struct tag of the field.
+func (f StructField) Lookup(key string) (string, bool) {
+	return f.tag.Lookup(key)
+}
+
+// Name returns the name of the field for the key struct tag, falling back to the Go name.
+func (f StructField) Name(key string) (string, bool) {
+	if v, ok := f.tag.Lookup(key); ok && strings.Split(v, ",")[0] == "-" {
+		return "", false
+	}
+	if name, ok := f.Tag(key); ok {
+		return name, true
+	}
+	return f.GoName, true
+}
+
+// StructFields returns the exported fields of the struct t is or points to.
+func StructFields(t types.Type) []StructField {
+	if pointer, ok := t.(*types.Pointer); ok {
+		t = pointer.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	var fields []StructField
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		if field.Anonymous() && tag == "" {
+			fields = append(fields, StructFields(field.Type())...)
+			continue
+		}
+		if !field.Exported() {
+			continue
+		}
+		fields = append(fields, StructField{GoName: field.Name(), Type: field.Type(), tag: tag})
+	}
+	return fields
+}
+
+// docText returns the doc comment lines that are not annotations.
+func docText(comments []string) string {
+	var lines []string
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "//"))
+		if text == "" || strings.HasPrefix(text, "@") {
+			continue
+		}
+		lines = append(lines, text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// yamlMap is a map that keeps its keys in insertion order.
+type yamlMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newYAMLMap() *yamlMap {
+	return &yamlMap{values: make(map[string]any)}
+}
+
+func (m *yamlMap) Set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *yamlMap) Get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+func writeYAML(buf *bytes.Buffer, v any, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	switch x := v.(type) {
+	case *yamlMap:
+		for _, key := range x.keys {
+			value := x.values[key]
+			buf.WriteString(prefix + yamlScalar(key) + ":")
+			writeYAMLValue(buf, value, indent)
+		}
+	case []any:
+		for _, item := range x {
+			buf.WriteString(prefix + "-")
+			if m, ok := item.(*yamlMap); ok && len(m.keys) > 0 {
+				// the first key goes on the dash line
+				var inner bytes.Buffer
+				writeYAML(&inner, m, indent+1)
+				buf.WriteString(" " + strings.TrimPrefix(inner.String(), prefix+"  "))
+				continue
+			}
+			writeYAMLValue(buf, item, indent)
+		}
+	}
+}
+
+func writeYAMLValue(buf *bytes.Buffer, value any, indent int) {
+	switch x := value.(type) {
+	case *yamlMap:
+		if len(x.keys) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, x, indent+1)
+	case []any:
+		if len(x) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, x, indent+1)
+	default:
+		buf.WriteString(" " + yamlScalar(x) + "\n")
+	}
+}
+
+var plainScalarRegexp = regexp.MustCompile(`^[A-Za-z_$/][A-Za-z0-9_$./{}#-]*$`)
+
+func yamlScalar(v any) string {
+	switch x := v.(type) {
+	case string:
+		switch x {
+		case "true", "false", "null", "yes", "no", "on", "off", "y", "n", "~":
+			return strconv.Quote(x)
+		}
+		if plainScalarRegexp.MatchString(x) {
+			return x
+		}
+		return strconv.Quote(x)
+	case bool, int:
+		return fmt.Sprint(x)
+	case nil:
+		return "null"
+	}
+	return strconv.Quote(fmt.Sprint(v))
+}
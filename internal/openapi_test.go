@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+const openAPISrc = `package users
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type GetReq struct {
+	ID     string   ` + "`uri:\"id\"`" + `
+	Fields []string ` + "`form:\"fields\"`" + `
+	Token  string   ` + "`header:\"X-Token\"`" + `
+}
+
+type UpdateReq struct {
+	ID   string ` + "`uri:\"id\" json:\"-\"`" + `
+	Name string ` + "`json:\"name\"`" + `
+	Tags []string ` + "`json:\"tags,omitempty\"`" + `
+}
+
+type User struct {
+	ID        string    ` + "`json:\"id\"`" + `
+	Name      string    ` + "`json:\"name\"`" + `
+	Age       int       ` + "`json:\"age\"`" + `
+	CreatedAt time.Time ` + "`json:\"created_at\"`" + `
+	Manager   *User     ` + "`json:\"manager,omitempty\"`" + `
+}
+
+type Users interface {
+	Get(ctx context.Context, req *GetReq) (*User, error)
+	Update(ctx context.Context, req *UpdateReq) (*User, error)
+	Upload(ctx context.Context, req io.Reader) (string, error)
+	Delete(ctx context.Context, req *GetReq) error
+}
+`
+
+// openAPIFuncs returns the FuncInfos of the methods of Users, routed by the @GORS annotations of routes.
+func openAPIFuncs(t *testing.T, routes map[string][]string) []*FuncInfo {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "users.go", openAPISrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/users", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface := pkg.Scope().Lookup("Users").Type().Underlying().(*types.Interface)
+	var funcs []*FuncInfo
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		info := NewMethodInfo(method.Name(), nil, method.Type().(*types.Signature))
+		if err := info.Check(); err != nil {
+			t.Fatal(err)
+		}
+		info.Comments = routes[method.Name()]
+		info.Route = NewRoute(method.Name(), info.Comments)
+		funcs = append(funcs, info)
+	}
+	return funcs
+}
+
+func TestOpenAPIYAML(t *testing.T) {
+	funcs := openAPIFuncs(t, map[string][]string{
+		"Get": {
+			"// Get gets a user.",
+			"// @GORS @GET @Path(/users/:id) @UriBinding @QueryBinding @HeaderBinding @JSONRender",
+		},
+		"Update": {
+			"// @GORS @PUT @Path(/users/:id) @UriBinding @JSONBinding @XMLRender",
+		},
+		"Upload": {
+			"// @GORS @POST @Path(/users/avatars/*path) @ReaderRender(image/png)",
+		},
+		"Delete": {
+			"// Delete has no @GORS route and is not documented.",
+		},
+	})
+	got := NewOpenAPI("Users", []string{"// Users manages the users: their profiles and avatars."}, funcs).YAML()
+	var doc struct {
+		Info  map[string]string
+		Paths map[string]map[string]any
+	}
+	if err := yaml.Unmarshal(got, &doc); err != nil {
+		t.Fatalf("YAML() is not valid YAML: %v", err)
+	}
+	if doc.Info["description"] != "Users manages the users: their profiles and avatars." || len(doc.Paths["/users/{id}"]) != 2 {
+		t.Errorf("YAML() decoded to %+v, want the description and the operations of /users/{id}", doc)
+	}
+	golden := filepath.Join("testdata", "openapi.yaml")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("YAML() differs from %s:\n%s", golden, got)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/users", "/users"},
+		{"/users/:id", "/users/{id}"},
+		{"/users/:id/posts/:post_id", "/users/{id}/posts/{post_id}"},
+		{"/files/*filepath", "/files/{filepath}"},
+	}
+	for _, tt := range tests {
+		if got := OpenAPIPath(tt.path); got != tt.want {
+			t.Errorf("OpenAPIPath(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	o := NewOpenAPI("Users", nil, nil)
+	var got []string
+	for _, path := range []string{"example.com/users", "example.com/admin", "example.com/billing", "example.com/users"} {
+		got = append(got, o.component(newNamed(path, "User", types.NewStruct(nil, nil)), "json"))
+	}
+	// the types named alike get a component each, and the same type the same one
+	want := []string{"User", "User_json", "User_json_2", "User"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("component names = %v, want %v", got, want)
+	}
+}
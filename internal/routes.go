@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckRoutes checks the routes of the methods of a service as a route table, reporting the routes
+// declared twice with the same method and path, and the routes whose paths a request may match both,
+// such as /users/:id and /users/new, which routers reject or resolve ambiguously.
+func CheckRoutes(funcs []*FuncInfo) []error {
+	var errs []error
+	var checked []*FuncInfo
+	for _, f := range funcs {
+		if f.Route == nil {
+			continue
+		}
+		// report a route once, against the first route it conflicts with
+	prevs:
+		for _, prev := range checked {
+			if prev.Route.Method != f.Route.Method {
+				continue
+			}
+			switch routeConflict(prev.Route.Path, f.Route.Path) {
+			case conflictDuplicate:
+				errs = append(errs, fmt.Errorf("%s: duplicate route %s %s of %s, already declared by %s at %s",
+					f.Route.Pos, f.Route.Method, f.Route.Path, f.FuncName, prev.FuncName, prev.Route.Pos))
+				break prevs
+			case conflictWildcard:
+				errs = append(errs, fmt.Errorf("%s: route %s %s of %s conflicts with %s of %s at %s",
+					f.Route.Pos, f.Route.Method, f.Route.Path, f.FuncName, prev.Route.Path, prev.FuncName, prev.Route.Pos))
+				break prevs
+			}
+		}
+		checked = append(checked, f)
+	}
+	return errs
+}
+
+type conflict int
+
+const (
+	conflictNone conflict = iota
+	// conflictDuplicate is a path declared twice, the names of the parameters aside.
+	conflictDuplicate
+	// conflictWildcard is a path whose parameter matches a segment of the other path.
+	conflictWildcard
+)
+
+// routeConflict returns the conflict of the paths a and b, whose parameters are :name or *name.
+func routeConflict(a, b string) conflict {
+	as, bs := strings.Split(strings.Trim(a, "/"), "/"), strings.Split(strings.Trim(b, "/"), "/")
+	result := conflictDuplicate
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		ak, bk := segmentKind(as[i]), segmentKind(bs[i])
+		switch {
+		case ak == '*' || bk == '*':
+			// a catch-all matches the rest of the other path
+			if ak != bk || len(as) != len(bs) {
+				return conflictWildcard
+			}
+			return result
+		case ak == ':' && bk == ':':
+		case ak == ':' || bk == ':':
+			result = conflictWildcard
+		case as[i] != bs[i]:
+			return conflictNone
+		}
+	}
+	if len(as) != len(bs) {
+		return conflictNone
+	}
+	return result
+}
+
+// segmentKind returns ':' for a parameter segment, '*' for a catch-all one and 0 for a literal one.
+func segmentKind(segment string) byte {
+	if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+		return segment[0]
+	}
+	return 0
+}
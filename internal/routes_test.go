@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"go/token"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouteConflict(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want conflict
+	}{
+		{"/users", "/users", conflictDuplicate},
+		{"/users/", "users", conflictDuplicate},
+		{"/users/:id", "/users/:name", conflictDuplicate},
+		{"/users/:id", "/users/new", conflictWildcard},
+		{"/users/new", "/users/:id", conflictWildcard},
+		{"/users/:id/orders", "/users/new/orders", conflictWildcard},
+		{"/users/:id/orders", "/users/new/items", conflictNone},
+		{"/users/:id", "/users/:id/orders", conflictNone},
+		{"/users", "/orders", conflictNone},
+		{"/files/*path", "/files/*name", conflictDuplicate},
+		{"/files/*path", "/files/logo.png", conflictWildcard},
+		{"/files/*path", "/files/:name", conflictWildcard},
+		{"/files/*path", "/files/images/logo.png", conflictWildcard},
+		{"/files/*path", "/images/*path", conflictNone},
+	}
+	for _, tt := range tests {
+		if got := routeConflict(tt.a, tt.b); got != tt.want {
+			t.Errorf("routeConflict(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRoutes(t *testing.T) {
+	route := func(name, method, path string) *FuncInfo {
+		return &FuncInfo{FuncName: name, Route: &Route{Method: method, Path: path, Pos: token.Position{Filename: "keyword.go", Line: len(name)}}}
+	}
+	tests := []struct {
+		name  string
+		funcs []*FuncInfo
+		want  []string
+	}{
+		{
+			name: "distinct routes",
+			funcs: []*FuncInfo{
+				route("List", http.MethodGet, "/keyword"),
+				route("Get", http.MethodGet, "/keyword/:id"),
+				{FuncName: "Internal"},
+			},
+		},
+		{
+			name: "exact duplicates",
+			funcs: []*FuncInfo{
+				route("CreateKocChannelCache", http.MethodPost, "/keyword/create_koc_channel_cache"),
+				route("CreateKocChannelCache2", http.MethodPost, "/keyword/create_koc_channel_cache"),
+				route("CreateKocChannelCache3", http.MethodPost, "/keyword/create_koc_channel_cache"),
+			},
+			want: []string{
+				"duplicate route POST /keyword/create_koc_channel_cache of CreateKocChannelCache2, already declared by CreateKocChannelCache",
+				"duplicate route POST /keyword/create_koc_channel_cache of CreateKocChannelCache3, already declared by CreateKocChannelCache",
+			},
+		},
+		{
+			name: "parameter and literal segments",
+			funcs: []*FuncInfo{
+				route("Get", http.MethodGet, "/keyword/:id"),
+				route("New", http.MethodGet, "/keyword/new"),
+			},
+			want: []string{"route GET /keyword/new of New conflicts with /keyword/:id of Get"},
+		},
+		{
+			name: "catch-all",
+			funcs: []*FuncInfo{
+				route("Download", http.MethodGet, "/files/*path"),
+				route("Logo", http.MethodGet, "/files/logo.png"),
+			},
+			want: []string{"route GET /files/logo.png of Logo conflicts with /files/*path of Download"},
+		},
+		{
+			name: "methods of the same path",
+			funcs: []*FuncInfo{
+				route("Get", http.MethodGet, "/keyword/:id"),
+				route("Update", http.MethodPut, "/keyword/:id"),
+				route("Delete", http.MethodDelete, "/keyword/new"),
+			},
+		},
+		{
+			name: "generated job route",
+			funcs: []*FuncInfo{
+				route("GetJob", http.MethodGet, "/keyword/jobs/:job"),
+				route("KeywordJobRoutes", http.MethodGet, "/keyword/jobs/:id"),
+			},
+			want: []string{"duplicate route GET /keyword/jobs/:id of KeywordJobRoutes, already declared by GetJob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := CheckRoutes(tt.funcs)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("CheckRoutes = %v, want %d errors", errs, len(tt.want))
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.want[i]) {
+					t.Errorf("CheckRoutes[%d] = %q, want %q", i, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
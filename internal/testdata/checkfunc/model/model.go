@@ -0,0 +1,9 @@
+package model
+
+type Req struct {
+	ID int `json:"id"`
+}
+
+type Resp struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,10 @@
+// Package model is named like the model package of checkfunc, which the service imports as othermodel.
+package model
+
+type Req struct {
+	Code string `json:"code"`
+}
+
+type Resp struct {
+	Code string `json:"code"`
+}
@@ -0,0 +1,23 @@
+// Package checkfunc is the fixture of the signature checks of the service methods.
+package checkfunc
+
+import (
+	stdctx "context"
+	"github.com/go-miya/gorsx/internal/testdata/checkfunc/model"
+	othermodel "github.com/go-miya/gorsx/internal/testdata/checkfunc/other/model"
+	stdio "io"
+)
+
+type Service interface {
+	Aliased(ctx stdctx.Context, req *model.Req) (*model.Resp, error)
+	Value(ctx stdctx.Context, req model.Req) (model.Resp, error)
+	List(ctx stdctx.Context, req *model.Req) ([]*model.Resp, error)
+	Stream(ctx stdctx.Context, req stdio.Reader) (stdio.Reader, error)
+	ErrorOnly(ctx stdctx.Context, req *model.Req) error
+	Named(c stdctx.Context, in *model.Req) (out *othermodel.Resp, e error)
+	Collide(ctx stdctx.Context, req *othermodel.Req) (*model.Resp, error)
+	NoContext(req *model.Req, ctx stdctx.Context) error
+	IntParam(ctx stdctx.Context, id int) error
+	NoError(ctx stdctx.Context, req *model.Req) *model.Resp
+	Chan(ctx stdctx.Context, req *model.Req) (chan *model.Resp, error)
+}